@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"crowdfund/backend/models"
+	"crowdfund/backend/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryHandlers struct {
+	categoryService *services.CategoryService
+}
+
+func NewCategoryHandlers(categoryService *services.CategoryService) *CategoryHandlers {
+	return &CategoryHandlers{categoryService: categoryService}
+}
+
+// ListCategories godoc
+// @Summary List categories
+// @Description List the category tree with project counts
+// @Tags categories
+// @Produce json
+// @Success 200 {array} models.Category
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/categories [get]
+func (h *CategoryHandlers) ListCategories(c *gin.Context) {
+	categories, err := h.categoryService.ListCategories()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+// CreateCategory godoc
+// @Summary Create a category
+// @Description Create a category, optionally nested under a parent (admin only)
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param category body models.CreateCategory true "Category details"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.Category
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/admin/categories [post]
+func (h *CategoryHandlers) CreateCategory(c *gin.Context) {
+	var input models.CreateCategory
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category := models.Category{Name: input.Name, Slug: input.Slug, ParentID: input.ParentID}
+	if err := h.categoryService.CreateCategory(&category); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory godoc
+// @Summary Update a category
+// @Description Rename or move a category (admin only)
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param id path int true "Category ID"
+// @Param category body models.CreateCategory true "Category details"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Category
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/admin/categories/{id} [put]
+func (h *CategoryHandlers) UpdateCategory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	var input models.CreateCategory
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category := models.Category{ID: uint(id), Name: input.Name, Slug: input.Slug, ParentID: input.ParentID}
+	if err := h.categoryService.UpdateCategory(&category); err != nil {
+		if errors.Is(err, services.ErrCategoryCycle) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory godoc
+// @Summary Delete a category
+// @Description Delete a category; its projects and subcategories become uncategorised (admin only)
+// @Tags categories
+// @Param id path int true "Category ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]string{"message": "Category deleted successfully"}
+// @Failure 400 {object} map[string]string{"error": "Invalid category ID"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/admin/categories/{id} [delete]
+func (h *CategoryHandlers) DeleteCategory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	if err := h.categoryService.DeleteCategory(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
+
+// ListTags godoc
+// @Summary List tags
+// @Description List all tags
+// @Tags categories
+// @Produce json
+// @Success 200 {array} models.Tag
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/tags [get]
+func (h *CategoryHandlers) ListTags(c *gin.Context) {
+	tags, err := h.categoryService.ListTags()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// MergeTags godoc
+// @Summary Merge tags
+// @Description Move every project from the source tags onto the target tag and delete the sources (admin only)
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param merge body models.MergeTags true "Source and target tag IDs"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]string{"message": "Tags merged successfully"}
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/admin/tags/merge [post]
+func (h *CategoryHandlers) MergeTags(c *gin.Context) {
+	var input models.MergeTags
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.categoryService.MergeTags(input.SourceIDs, input.TargetID); err != nil {
+		if errors.Is(err, services.ErrMergeIntoItself) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tags merged successfully"})
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"crowdfund/backend/models"
+	"crowdfund/backend/services"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CommentHandlers struct {
+	commentService  *services.CommentService
+	projectService  *services.ProjectService
+	updateService   *services.ProjectUpdateService
+	donationService *services.DonationService
+}
+
+func NewCommentHandlers(commentService *services.CommentService, projectService *services.ProjectService, updateService *services.ProjectUpdateService, donationService *services.DonationService) *CommentHandlers {
+	return &CommentHandlers{commentService: commentService, projectService: projectService, updateService: updateService, donationService: donationService}
+}
+
+// ListComments godoc
+// @Summary List comments
+// @Description List a page of top-level comments on a project, or on one of its updates, with their replies. Comments on backers-only updates are only shown to backers, the project's creator and admins.
+// @Tags comments
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param updateID path int false "Update ID"
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Top-level comments per page (default 20, max 100)"
+// @Success 200 {object} models.CommentPage
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 403 {object} map[string]string{"error": "Comments on this update are for backers only"}
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/comments [get]
+// @Router /api/projects/{id}/updates/{updateID}/comments [get]
+func (h *CommentHandlers) ListComments(c *gin.Context) {
+	project, updateID, ok := h.resolveTarget(c)
+	if !ok {
+		return
+	}
+
+	page, perPage := parsePagination(c)
+	comments, err := h.commentService.ListComments(project, updateID, page, perPage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, comments)
+}
+
+// CreateComment godoc
+// @Summary Post a comment
+// @Description Comment on a project or update, or reply to a comment with parent_id
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param updateID path int false "Update ID"
+// @Param comment body models.CreateComment true "Comment"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.Comment
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 403 {object} map[string]string{"error": "Only backers can comment"}
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
+// @Failure 429 {object} map[string]string{"error": "Too many comments"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/comments [post]
+// @Router /api/projects/{id}/updates/{updateID}/comments [post]
+func (h *CommentHandlers) CreateComment(c *gin.Context) {
+	var input models.CreateComment
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project, updateID, ok := h.resolveTarget(c)
+	if !ok {
+		return
+	}
+	userModel, _ := currentUser(c)
+
+	comment := models.Comment{
+		ProjectID: project.ID,
+		UpdateID:  updateID,
+		ParentID:  input.ParentID,
+		UserID:    userModel.ID,
+		Body:      input.Body,
+		Username:  userModel.Username,
+	}
+	if err := h.commentService.CreateComment(project, &comment); err != nil {
+		switch {
+		case errors.Is(err, services.ErrCommentRateLimited):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrCommentsBackersOnly):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidParent), errors.Is(err, services.ErrCommentDeleted):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+// EditComment godoc
+// @Summary Edit a comment
+// @Description Edit the body of your own comment
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param commentID path int true "Comment ID"
+// @Param comment body models.EditComment true "New body"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Comment
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 403 {object} map[string]string{"error": "Forbidden"}
+// @Failure 404 {object} map[string]string{"error": "Comment not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/comments/{commentID} [put]
+func (h *CommentHandlers) EditComment(c *gin.Context) {
+	commentID, err := strconv.ParseUint(c.Param("commentID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+
+	var input models.EditComment
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment, err := h.commentService.GetComment(commentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	userModel, _ := currentUser(c)
+	if comment.UserID != userModel.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if err := h.commentService.EditComment(&comment, input.Body); err != nil {
+		if errors.Is(err, services.ErrCommentDeleted) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	comment.Username = userModel.Username
+	comment.Replies = []models.Comment{}
+
+	c.JSON(http.StatusOK, comment)
+}
+
+// DeleteComment godoc
+// @Summary Delete a comment
+// @Description Soft-delete a comment. Its author, the project creator and admins may delete it; replies remain visible.
+// @Tags comments
+// @Param commentID path int true "Comment ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]string{"message": "Comment deleted successfully"}
+// @Failure 400 {object} map[string]string{"error": "Invalid comment ID"}
+// @Failure 403 {object} map[string]string{"error": "Forbidden"}
+// @Failure 404 {object} map[string]string{"error": "Comment not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/comments/{commentID} [delete]
+func (h *CommentHandlers) DeleteComment(c *gin.Context) {
+	commentID, err := strconv.ParseUint(c.Param("commentID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+
+	comment, err := h.commentService.GetComment(commentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	userModel, _ := currentUser(c)
+	if comment.UserID != userModel.ID {
+		if _, ok := authorizeProjectOwner(c, h.projectService, uint64(comment.ProjectID)); !ok {
+			return
+		}
+	}
+
+	if err := h.commentService.DeleteComment(&comment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
+
+// resolveTarget loads the project from the :id parameter and, on update
+// routes, checks that :updateID belongs to it and that the viewer may see
+// it: scheduled updates only to the project's managers, backers-only
+// updates only to backers.
+func (h *CommentHandlers) resolveTarget(c *gin.Context) (models.Project, *uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return models.Project{}, nil, false
+	}
+	project, err := h.projectService.GetProject(id)
+	if err != nil || !canViewProject(c, project) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return models.Project{}, nil, false
+	}
+
+	if c.Param("updateID") == "" {
+		return project, nil, true
+	}
+	updateID, err := strconv.ParseUint(c.Param("updateID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid update ID"})
+		return models.Project{}, nil, false
+	}
+	update, err := h.updateService.GetUpdate(id, updateID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Update not found"})
+		return models.Project{}, nil, false
+	}
+	manager, backer, err := viewerAccess(c, h.donationService, project)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return models.Project{}, nil, false
+	}
+	if update.PublishAt.After(time.Now()) && !manager {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Update not found"})
+		return models.Project{}, nil, false
+	}
+	if update.BackersOnly && !backer {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Comments on this update are for backers only"})
+		return models.Project{}, nil, false
+	}
+	return project, &update.ID, true
+}
@@ -3,31 +3,41 @@ package handlers
 import (
         "crowdfund/backend/models"
         "crowdfund/backend/services"
+        "errors"
         "net/http"
         "strconv"
+        "strings"
 
         "github.com/gin-gonic/gin"
+        "gorm.io/gorm"
 )
 
 type DonationHandlers struct {
         donationService *services.DonationService
+        projectService  *services.ProjectService
 }
 
-func NewDonationHandlers(donationService *services.DonationService) *DonationHandlers {
-        return &DonationHandlers{donationService: donationService}
+func NewDonationHandlers(donationService *services.DonationService, projectService *services.ProjectService) *DonationHandlers {
+        return &DonationHandlers{donationService: donationService, projectService: projectService}
 }
 
 // CreateDonation godoc
 // @Summary Create a new donation for a project
-// @Description Create a new donation for a project
+// @Description Donate to a running project in any supported currency, paying with a card token from the payment provider. Amounts in another currency are converted to the project's base currency at the current exchange rate, which is stored with the donation. The donation is charged in the background; poll GET /api/donations/{ref} with the returned reference for the outcome. Donors may leave a public message, show a display name instead of their username, or give anonymously so only the project's creator and admins see who they are.
 // @Tags donations
 // @Accept json
 // @Produce json
 // @Param id path int true "Project ID"
-// @Param donation body models.Donation true "Donation details"
+// @Param donation body models.CreateDonation true "Donation details"
+// @Param Idempotency-Key header string false "Unique key; retries with the same key and body replay the first response for 24 hours"
 // @Security ApiKeyAuth
-// @Success 201 {object} map[string]string{"message": "Donation created successfully"}
+// @Success 201 {object} map[string]string{"message": "Donation created successfully", "reference": "don_...", "status": "queued"}
 // @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
+// @Failure 409 {object} map[string]string{"error": "a request with this idempotency key is still in progress"}
+// @Failure 422 {object} map[string]interface{}{"error": "Invalid input", "fields": map[string]string}
+// @Failure 422 {object} map[string]string{"error": "idempotency key was already used for a different request"}
+// @Failure 503 {object} map[string]string{"error": "Exchange rates are unavailable"}
 // @Failure 500 {object} map[string]string{"error": "Internal server error"}
 // @Router /api/projects/{id}/donations [post]
 func (h *DonationHandlers) CreateDonation(c *gin.Context) {
@@ -38,9 +48,8 @@ func (h *DonationHandlers) CreateDonation(c *gin.Context) {
                 return
         }
 
-        var donation models.Donation
-        if err := c.ShouldBindJSON(&donation); err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        var input models.CreateDonation
+        if !bindJSON(c, &input) {
                 return
         }
 
@@ -52,26 +61,80 @@ func (h *DonationHandlers) CreateDonation(c *gin.Context) {
 
         userModel := user.(models.User)
 
-        donation.ProjectID = uint(projectID)
-        donation.UserID = userModel.ID
+        donation := models.Donation{
+                ProjectID:   uint(projectID),
+                UserID:      userModel.ID,
+                Amount:      input.Amount,
+                Anonymous:   input.Anonymous,
+                DisplayName: strings.TrimSpace(input.DisplayName),
+                Message:     strings.TrimSpace(input.Message),
+        }
+
+        donation, err = h.donationService.CreateDonation(c.Request.Context(), donation, input.PaymentMethod)
+        if err != nil {
+                switch {
+                case errors.Is(err, gorm.ErrRecordNotFound):
+                        c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+                case errors.Is(err, services.ErrProjectNotAcceptingDonations):
+                        c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid input", "fields": services.FieldErrors{"project_id": err.Error()}})
+                case errors.Is(err, services.ErrNoExchangeRate):
+                        c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid input", "fields": services.FieldErrors{"amount": "currency cannot be converted to the project's currency"}})
+                case errors.Is(err, services.ErrRatesUnavailable):
+                        c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+                default:
+                        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                }
+                return
+        }
+
+        c.Header("Location", "/api/donations/"+donation.Reference)
+        c.JSON(http.StatusCreated, gin.H{"message": "Donation created successfully", "reference": donation.Reference, "status": donation.Status})
+}
 
-        if err := h.donationService.CreateDonation(donation); err != nil {
+// GetDonation godoc
+// @Summary Get a donation by reference
+// @Description Get one of your donations by the reference returned when it was made. Poll this until the status is succeeded, authorized or failed; failed donations include a failure_reason. Pledges to all-or-nothing projects stay authorized, with the donor's card saved but not charged, until the campaign closes, then succeed if it reached its goal and the card is charged, and fail otherwise.
+// @Tags donations
+// @Produce json
+// @Param ref path string true "Donation reference"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Donation
+// @Failure 404 {object} map[string]string{"error": "Donation not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/donations/{ref} [get]
+func (h *DonationHandlers) GetDonation(c *gin.Context) {
+        donation, err := h.donationService.GetDonationByReference(c.Param("ref"))
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+                c.JSON(http.StatusNotFound, gin.H{"error": "Donation not found"})
+                return
+        }
+        if err != nil {
                 c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
                 return
         }
 
-        c.JSON(http.StatusCreated, gin.H{"message": "Donation created successfully"})
+        // Other people's donations look the same as missing ones.
+        user, _ := currentUser(c)
+        if donation.UserID != user.ID && !user.IsAdmin {
+                c.JSON(http.StatusNotFound, gin.H{"error": "Donation not found"})
+                return
+        }
+
+        c.Header("Cache-Control", "no-store")
+        c.JSON(http.StatusOK, donation)
 }
 
 // GetDonationsByProjectID godoc
 // @Summary Get donations for a project
-// @Description Get donations for a project
+// @Description List a project's successful donations and pledges, newest first, with donors' names and messages. Anonymous donors are listed without their name or user ID, and messages hidden by moderators are left out. The project's creator and admins get every donation in full, whatever its status, including who gave anonymously.
 // @Tags donations
 // @Produce json
 // @Param id path int true "Project ID"
-// @Security ApiKeyAuth
-// @Success 200 {array} models.Donation
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Items per page (default 20, max 100)"
+// @Success 200 {array} models.PublicDonation
 // @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
 // @Failure 500 {object} map[string]string{"error": "Internal server error"}
 // @Router /api/projects/{id}/donations [get]
 func (h *DonationHandlers) GetDonationsByProjectID(c *gin.Context) {
@@ -82,11 +145,40 @@ func (h *DonationHandlers) GetDonationsByProjectID(c *gin.Context) {
                 return
         }
 
-        donations, err := h.donationService.GetDonationsByProjectID(projectID)
+        project, err := h.projectService.GetProject(projectID)
+        if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !canViewProject(c, project)) {
+                c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+                return
+        }
         if err != nil {
                 c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
                 return
         }
+        page, perPage := parsePagination(c)
+
+        user, _ := currentUser(c)
+        if !canManageProject(user, project) {
+                donations, err := h.donationService.ListPublicDonations(projectID, page, perPage)
+                if err != nil {
+                        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                        return
+                }
+                c.JSON(http.StatusOK, donations)
+                return
+        }
 
+        donations, err := h.donationService.GetDonationsByProjectID(projectID, page, perPage)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                return
+        }
+        // Moderators' decisions apply to the creator too.
+        for i := range donations {
+                if donations[i].ModerationStatus == models.ModerationHidden && !user.IsAdmin {
+                        donations[i].Message = ""
+                        donations[i].DisplayName = ""
+                }
+        }
+        c.Header("Cache-Control", "private, no-store")
         c.JSON(http.StatusOK, donations)
-}
\ No newline at end of file
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"crowdfund/backend/models"
+	"crowdfund/backend/services"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// donationListFixture is a project with a donation of each kind the
+// listing treats differently.
+type donationListFixture struct {
+	router                             *gin.Engine
+	owner, admin, stranger             models.User
+	named, anonymous, hidden, declined models.Donation
+}
+
+func newDonationListFixture(t *testing.T) donationListFixture {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Project{}, &models.Tag{}, &models.ProjectMedia{}, &models.Donation{}))
+
+	var f donationListFixture
+	f.owner = models.User{Username: "owner", Email: "owner@example.com"}
+	f.admin = models.User{Username: "admin", Email: "admin@example.com", IsAdmin: true}
+	f.stranger = models.User{Username: "stranger", Email: "stranger@example.com"}
+	donor := models.User{Username: "donor", Email: "donor@example.com"}
+	for _, user := range []*models.User{&f.owner, &f.admin, &f.stranger, &donor} {
+		require.NoError(t, db.Create(user).Error)
+	}
+	project := models.Project{
+		Title:            "Solar kettle",
+		Slug:             "solar-kettle",
+		Goal:             models.Money{Amount: 100000, Currency: "EUR"},
+		StartDate:        time.Now().Add(-24 * time.Hour),
+		EndDate:          time.Now().Add(7 * 24 * time.Hour),
+		UserID:           f.owner.ID,
+		ModerationStatus: models.ModerationActive,
+	}
+	require.NoError(t, db.Create(&project).Error)
+
+	donation := func(status, message string, anonymous bool, moderation string) models.Donation {
+		d := models.Donation{
+			Reference:        "don_" + status + message,
+			ProjectID:        project.ID,
+			UserID:           donor.ID,
+			Amount:           models.Money{Amount: 500, Currency: "EUR"},
+			BaseAmount:       models.Money{Amount: 500, Currency: "EUR"},
+			Status:           status,
+			Anonymous:        anonymous,
+			DisplayName:      "Kettle fan",
+			Message:          message,
+			ModerationStatus: moderation,
+		}
+		require.NoError(t, db.Create(&d).Error)
+		return d
+	}
+	f.named = donation(models.DonationSucceeded, "Good luck!", false, models.ModerationActive)
+	f.anonymous = donation(models.DonationAuthorized, "From a friend", true, models.ModerationActive)
+	f.hidden = donation(models.DonationSucceeded, "Buy cheap watches", false, models.ModerationHidden)
+	f.declined = donation(models.DonationFailed, "Try again", false, models.ModerationActive)
+
+	gin.SetMode(gin.TestMode)
+	handlers := NewDonationHandlers(services.NewDonationService(db, nil, nil, nil, nil), services.NewProjectService(db))
+	f.router = gin.New()
+	f.router.GET("/api/projects/:id/donations", func(c *gin.Context) {
+		var user models.User
+		if err := db.Where("username = ?", c.GetHeader("X-Test-User")).First(&user).Error; err == nil {
+			c.Set("user", user)
+		}
+	}, handlers.GetDonationsByProjectID)
+	return f
+}
+
+// list fetches the project's donations as the named user, or anonymously
+// when username is empty.
+func (f donationListFixture) list(t *testing.T, username string, into interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/projects/%d/donations", f.named.ProjectID), nil)
+	req.Header.Set("X-Test-User", username)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), into))
+	return w
+}
+
+// TestGetDonationsByProjectID_Public tests that the public only sees listed donations, with anonymous donors and hidden messages masked
+func TestGetDonationsByProjectID_Public(t *testing.T) {
+	f := newDonationListFixture(t)
+
+	for _, username := range []string{"", f.stranger.Username} {
+		var donations []map[string]interface{}
+		f.list(t, username, &donations)
+		require.Len(t, donations, 3, username)
+		byID := map[float64]map[string]interface{}{}
+		for _, d := range donations {
+			byID[d["id"].(float64)] = d
+		}
+		assert.NotContains(t, byID, float64(f.declined.ID))
+
+		named := byID[float64(f.named.ID)]
+		assert.Equal(t, "donor", named["username"])
+		assert.Equal(t, "Kettle fan", named["display_name"])
+		assert.Equal(t, "Good luck!", named["message"])
+
+		anonymous := byID[float64(f.anonymous.ID)]
+		assert.NotContains(t, anonymous, "user_id")
+		assert.NotContains(t, anonymous, "username")
+		assert.NotContains(t, anonymous, "display_name")
+		assert.Equal(t, "From a friend", anonymous["message"])
+
+		hidden := byID[float64(f.hidden.ID)]
+		assert.Equal(t, "donor", hidden["username"])
+		assert.NotContains(t, hidden, "message")
+		assert.NotContains(t, hidden, "display_name")
+	}
+}
+
+// TestGetDonationsByProjectID_Manager tests that the creator and admins see every donation in full, except that only admins see hidden messages
+func TestGetDonationsByProjectID_Manager(t *testing.T) {
+	f := newDonationListFixture(t)
+
+	var donations []models.Donation
+	w := f.list(t, f.owner.Username, &donations)
+	assert.Equal(t, "private, no-store", w.Header().Get("Cache-Control"))
+	require.Len(t, donations, 4)
+	byID := map[uint]models.Donation{}
+	for _, d := range donations {
+		byID[d.ID] = d
+	}
+	assert.Equal(t, models.DonationFailed, byID[f.declined.ID].Status)
+	assert.Equal(t, f.anonymous.UserID, byID[f.anonymous.ID].UserID)
+	assert.True(t, byID[f.anonymous.ID].Anonymous)
+	assert.Empty(t, byID[f.hidden.ID].Message)
+	assert.Empty(t, byID[f.hidden.ID].DisplayName)
+
+	donations = nil
+	f.list(t, f.admin.Username, &donations)
+	require.Len(t, donations, 4)
+	for _, d := range donations {
+		if d.ID == f.hidden.ID {
+			assert.Equal(t, "Buy cheap watches", d.Message)
+			assert.Equal(t, "Kettle fan", d.DisplayName)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"crowdfund/backend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FollowHandlers struct {
+	followService  *services.FollowService
+	projectService *services.ProjectService
+}
+
+func NewFollowHandlers(followService *services.FollowService, projectService *services.ProjectService) *FollowHandlers {
+	return &FollowHandlers{followService: followService, projectService: projectService}
+}
+
+// FollowProject godoc
+// @Summary Follow a project
+// @Description Add a project to your watchlist. Followers are emailed 48 hours before the campaign ends and when it reaches its goal.
+// @Tags follows
+// @Produce json
+// @Param id path int true "Project ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]string{"message": "Project followed"}
+// @Failure 400 {object} map[string]string{"error": "Invalid project ID"}
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/follow [post]
+func (h *FollowHandlers) FollowProject(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	user, _ := currentUser(c)
+	project, err := h.projectService.GetProject(id)
+	if err != nil || !canViewProject(c, project) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	if err := h.followService.Follow(user.ID, project); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project followed"})
+}
+
+// UnfollowProject godoc
+// @Summary Unfollow a project
+// @Description Remove a project from your watchlist
+// @Tags follows
+// @Produce json
+// @Param id path int true "Project ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]string{"message": "Project unfollowed"}
+// @Failure 400 {object} map[string]string{"error": "Invalid project ID"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/follow [delete]
+func (h *FollowHandlers) UnfollowProject(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	user, _ := currentUser(c)
+
+	if err := h.followService.Unfollow(user.ID, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project unfollowed"})
+}
+
+// ListFollowing godoc
+// @Summary List followed projects
+// @Description List the projects on your watchlist, most recently followed first
+// @Tags follows
+// @Produce json
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Items per page (default 20, max 100)"
+// @Security ApiKeyAuth
+// @Success 200 {array} models.FollowedProject
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/users/following [get]
+func (h *FollowHandlers) ListFollowing(c *gin.Context) {
+	user, _ := currentUser(c)
+	page, perPage := parsePagination(c)
+
+	projects, err := h.followService.Following(user.ID, page, perPage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, projects)
+}
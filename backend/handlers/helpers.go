@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"crowdfund/backend/models"
+	"crowdfund/backend/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentUser returns the authenticated user, if any. It works behind both
+// AuthMiddleware and OptionalAuthMiddleware.
+func currentUser(c *gin.Context) (models.User, bool) {
+	user, exists := c.Get("user")
+	if !exists {
+		return models.User{}, false
+	}
+	return user.(models.User), true
+}
+
+// canManageProject reports whether the user may edit the project: its
+// creator or an admin.
+func canManageProject(user models.User, project models.Project) bool {
+	return project.UserID == user.ID || user.IsAdmin
+}
+
+// canViewProject reports whether the viewer may see the project and what
+// hangs off it: revisions, updates, comments and donations. Projects that
+// have not launched yet or were taken down by moderators are only shown to
+// their creator and admins.
+func canViewProject(c *gin.Context, project models.Project) bool {
+	if project.ModerationStatus == models.ModerationActive && !project.IsDraft(time.Now()) {
+		return true
+	}
+	user, ok := currentUser(c)
+	return ok && canManageProject(user, project)
+}
+
+// authorizeProjectOwner loads the project and checks that the authenticated
+// user may manage it. On failure it writes the error response and returns
+// false.
+func authorizeProjectOwner(c *gin.Context, projectService *services.ProjectService, projectID uint64) (models.Project, bool) {
+	project, err := projectService.GetProject(projectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return models.Project{}, false
+	}
+	user, _ := currentUser(c)
+	if !canManageProject(user, project) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return models.Project{}, false
+	}
+	return project, true
+}
+
+// parsePagination reads the page and per_page query parameters, defaulting
+// to the first page of 20 and capping per_page at 100.
+func parsePagination(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if err != nil || perPage < 1 {
+		perPage = 20
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+	return page, perPage
+}
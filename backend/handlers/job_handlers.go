@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"crowdfund/backend/jobs"
+	"crowdfund/backend/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandlers struct {
+	jobService *services.JobService
+}
+
+func NewJobHandlers(jobService *services.JobService) *JobHandlers {
+	return &JobHandlers{jobService: jobService}
+}
+
+// ListPending godoc
+// @Summary List queued background jobs
+// @Description List jobs waiting to run on a queue, including failed jobs waiting for their next retry (admin only)
+// @Tags jobs
+// @Produce json
+// @Param queue query string true "Queue name: donations, emails, payment_events, refunds or settlements"
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Items per page (default 20, max 100)"
+// @Security ApiKeyAuth
+// @Success 200 {array} jobs.Entry
+// @Failure 400 {object} map[string]string{"error": "queue is required"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/admin/jobs [get]
+func (h *JobHandlers) ListPending(c *gin.Context) {
+	queue := c.Query("queue")
+	if queue == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "queue is required"})
+		return
+	}
+	page, perPage := parsePagination(c)
+	entries, err := h.jobService.Pending(c.Request.Context(), queue, page, perPage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// ListDead godoc
+// @Summary List dead jobs
+// @Description List background jobs that failed on every attempt, most recent first (admin only)
+// @Tags jobs
+// @Produce json
+// @Param queue query string false "Only jobs on this queue, e.g. donations"
+// @Param include_replayed query bool false "Include jobs that have already been replayed"
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Items per page (default 20, max 100)"
+// @Security ApiKeyAuth
+// @Success 200 {array} models.DeadJob
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/admin/jobs/dead [get]
+func (h *JobHandlers) ListDead(c *gin.Context) {
+	page, perPage := parsePagination(c)
+	includeReplayed := c.Query("include_replayed") == "true"
+	deadJobs, err := h.jobService.Dead(c.Query("queue"), includeReplayed, page, perPage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, deadJobs)
+}
+
+// ReplayDead godoc
+// @Summary Replay a dead job
+// @Description Put a copy of a dead job back on its queue to run straight away. A donation failed by the job is queued again (admin only).
+// @Tags jobs
+// @Produce json
+// @Param id path int true "Dead job ID"
+// @Security ApiKeyAuth
+// @Success 201 {object} map[string]string{"message": "Job replayed"}
+// @Failure 400 {object} map[string]string{"error": "Invalid job ID"}
+// @Failure 404 {object} map[string]string{"error": "job not found"}
+// @Failure 409 {object} map[string]string{"error": "job has already been replayed"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/admin/jobs/dead/{id}/replay [post]
+func (h *JobHandlers) ReplayDead(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	if err := h.jobService.Replay(c.Request.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, jobs.ErrJobNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, jobs.ErrJobAlreadyReplayed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Job replayed"})
+}
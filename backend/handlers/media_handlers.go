@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"crowdfund/backend/services"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MediaHandlers struct {
+	mediaService   *services.MediaService
+	projectService *services.ProjectService
+	cacheService   *services.CacheService
+}
+
+func NewMediaHandlers(mediaService *services.MediaService, projectService *services.ProjectService, cacheService *services.CacheService) *MediaHandlers {
+	return &MediaHandlers{mediaService: mediaService, projectService: projectService, cacheService: cacheService}
+}
+
+// UploadMedia godoc
+// @Summary Upload project media
+// @Description Attach an image or video to a project. The type is sniffed from the file contents; images get a thumbnail. Images over 40 megapixels are rejected.
+// @Tags media
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param file formData file true "Image (jpeg, png, gif, webp) or video (mp4, webm)"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.ProjectMedia
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 403 {object} map[string]string{"error": "Forbidden"}
+// @Failure 413 {object} map[string]string{"error": "File too large"}
+// @Failure 415 {object} map[string]string{"error": "Unsupported media type"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/media [post]
+func (h *MediaHandlers) UploadMedia(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	if _, ok := authorizeProjectOwner(c, h.projectService, id); !ok {
+		return
+	}
+
+	// Leave a little room for the multipart envelope around the file itself.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxVideoUploadSize+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": services.ErrMediaTooLarge.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	media, err := h.mediaService.Upload(c.Request.Context(), id, file, fileHeader.Size)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnsupportedMediaType):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrMediaTooLarge), errors.Is(err, services.ErrImageTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	h.cacheService.InvalidateProjectCache(id)
+
+	c.JSON(http.StatusCreated, media)
+}
+
+// DeleteMedia godoc
+// @Summary Delete project media
+// @Description Remove an image or video from a project
+// @Tags media
+// @Param id path int true "Project ID"
+// @Param mediaID path int true "Media ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]string{"message": "Media deleted successfully"}
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 403 {object} map[string]string{"error": "Forbidden"}
+// @Failure 404 {object} map[string]string{"error": "Media not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/media/{mediaID} [delete]
+func (h *MediaHandlers) DeleteMedia(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	mediaID, err := strconv.ParseUint(c.Param("mediaID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media ID"})
+		return
+	}
+
+	if _, ok := authorizeProjectOwner(c, h.projectService, id); !ok {
+		return
+	}
+
+	media, err := h.mediaService.GetMedia(id, mediaID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+		return
+	}
+	if err := h.mediaService.DeleteMedia(c.Request.Context(), media); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.cacheService.InvalidateProjectCache(id)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Media deleted successfully"})
+}
+
+// ServeLocalMedia serves files from a LocalBlobStore behind the signed URLs
+// it generates. It is only mounted when the local storage driver is in use.
+func ServeLocalMedia(store *services.LocalBlobStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimPrefix(c.Param("key"), "/")
+		f, err := store.Open(key, c.Query("expires"), c.Query("signature"))
+		if err != nil {
+			if errors.Is(err, services.ErrBlobNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+				return
+			}
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Cache-Control", "private, max-age=3600")
+		http.ServeContent(c.Writer, c.Request, info.Name(), info.ModTime(), f)
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"crowdfund/backend/models"
+	"crowdfund/backend/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ModerationHandlers struct {
+	moderationService *services.ModerationService
+	cacheService      *services.CacheService
+}
+
+func NewModerationHandlers(moderationService *services.ModerationService, cacheService *services.CacheService) *ModerationHandlers {
+	return &ModerationHandlers{moderationService: moderationService, cacheService: cacheService}
+}
+
+// CreateReport godoc
+// @Summary Report a project, comment or donation message
+// @Description Flag content for moderator review. Content reported by enough users is hidden until reviewed.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param report body models.CreateReport true "Report details"
+// @Security ApiKeyAuth
+// @Success 201 {object} map[string]string{"message": "Report submitted"}
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 404 {object} map[string]string{"error": "Reported content not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/reports [post]
+func (h *ModerationHandlers) CreateReport(c *gin.Context) {
+	var input models.CreateReport
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userModel, _ := currentUser(c)
+
+	report := models.Report{
+		TargetType: input.TargetType,
+		TargetID:   input.TargetID,
+		ReporterID: userModel.ID,
+		Reason:     input.Reason,
+		Details:    input.Details,
+	}
+	changed, err := h.moderationService.CreateReport(&report)
+	if err != nil {
+		if errors.Is(err, services.ErrReportTargetNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.invalidate(changed)
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Report submitted"})
+}
+
+// Queue godoc
+// @Summary Moderation queue
+// @Description List reported projects and comments with open reports, most reported first (admin only)
+// @Tags moderation
+// @Produce json
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Items per page (default 20, max 100)"
+// @Security ApiKeyAuth
+// @Success 200 {array} models.ModerationQueueItem
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/admin/moderation/queue [get]
+func (h *ModerationHandlers) Queue(c *gin.Context) {
+	page, perPage := parsePagination(c)
+	items, err := h.moderationService.Queue(page, perPage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+// TargetDetails godoc
+// @Summary Reports and history for a target
+// @Description List every report filed against a project, comment or donation message and the moderation actions taken on it (admin only)
+// @Tags moderation
+// @Produce json
+// @Param type path string true "project, comment or donation"
+// @Param id path int true "Target ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}{"reports": []models.Report, "history": []models.ModerationAction}
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/admin/moderation/{type}/{id} [get]
+func (h *ModerationHandlers) TargetDetails(c *gin.Context) {
+	targetType := c.Param("type")
+	if targetType != models.ReportTargetProject && targetType != models.ReportTargetComment && targetType != models.ReportTargetDonation {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target type"})
+		return
+	}
+	targetID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target ID"})
+		return
+	}
+
+	reports, err := h.moderationService.ReportsFor(targetType, uint(targetID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	history, err := h.moderationService.History(targetType, uint(targetID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reports": reports, "history": history})
+}
+
+// TakeAction godoc
+// @Summary Act on reported content
+// @Description Hide, suspend, restore or dismiss reports on a target, or ban its creator (admin only)
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param action body models.TakeModerationAction true "Moderation decision"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]string{"message": "Moderation action applied"}
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 404 {object} map[string]string{"error": "Reported content not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/admin/moderation/actions [post]
+func (h *ModerationHandlers) TakeAction(c *gin.Context) {
+	var input models.TakeModerationAction
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	admin, _ := currentUser(c)
+
+	changed, err := h.moderationService.TakeAction(admin.ID, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrReportTargetNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidModeration):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	h.invalidate(changed)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Moderation action applied"})
+}
+
+func (h *ModerationHandlers) invalidate(projectIDs []uint) {
+	for _, id := range projectIDs {
+		h.cacheService.InvalidateProjectCache(uint64(id))
+	}
+}
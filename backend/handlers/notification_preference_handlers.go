@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"crowdfund/backend/models"
+	"crowdfund/backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationPreferenceHandlers struct {
+	preferenceService *services.NotificationPreferenceService
+}
+
+func NewNotificationPreferenceHandlers(preferenceService *services.NotificationPreferenceService) *NotificationPreferenceHandlers {
+	return &NotificationPreferenceHandlers{preferenceService: preferenceService}
+}
+
+// GetPreferences godoc
+// @Summary Get notification preferences
+// @Description Show which optional emails you receive
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.NotificationPreferences
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/users/notification-preferences [get]
+func (h *NotificationPreferenceHandlers) GetPreferences(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	prefs, err := h.preferenceService.Get(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// UpdatePreferences godoc
+// @Summary Update notification preferences
+// @Description Turn optional emails on or off. Omitted fields keep their current value.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param preferences body models.UpdateNotificationPreferences true "Preferences to change"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.NotificationPreferences
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/users/notification-preferences [put]
+func (h *NotificationPreferenceHandlers) UpdatePreferences(c *gin.Context) {
+	var input models.UpdateNotificationPreferences
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, _ := currentUser(c)
+
+	prefs, err := h.preferenceService.Update(user.ID, input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"crowdfund/backend/services"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookBody caps webhook payloads; provider events are a few KB.
+const maxWebhookBody = 1 << 20
+
+type PaymentWebhookHandlers struct {
+	paymentEventService *services.PaymentEventService
+}
+
+func NewPaymentWebhookHandlers(paymentEventService *services.PaymentEventService) *PaymentWebhookHandlers {
+	return &PaymentWebhookHandlers{paymentEventService: paymentEventService}
+}
+
+// ReceiveWebhook godoc
+// @Summary Receive a payment provider webhook
+// @Description Verify the provider's signature and queue the event. Events are applied to donations in the background and redeliveries of the same event are acknowledged without being applied again.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param provider path string true "Payment provider, e.g. stripe"
+// @Success 200 {object} map[string]string{"message": "Event received"}
+// @Failure 400 {object} map[string]string{"error": "Invalid webhook signature"}
+// @Failure 400 {object} map[string]string{"error": "Invalid webhook payload"}
+// @Failure 404 {object} map[string]string{"error": "Unknown payment provider"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /webhooks/payments/{provider} [post]
+func (h *PaymentWebhookHandlers) ReceiveWebhook(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook body"})
+		return
+	}
+
+	duplicate, err := h.paymentEventService.Receive(c.Request.Context(), c.Param("provider"), c.Request.Header, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownPaymentProvider):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown payment provider"})
+		case errors.Is(err, services.ErrInvalidWebhookSignature):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook signature"})
+		case errors.Is(err, services.ErrInvalidWebhookPayload):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook payload"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if duplicate {
+		c.JSON(http.StatusOK, gin.H{"message": "Event already received"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Event received"})
+}
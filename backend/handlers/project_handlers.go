@@ -4,38 +4,54 @@ import (
 	"context"
 	"crowdfund/backend/models"
 	"crowdfund/backend/services"
+	"crowdfund/backend/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type ProjectHandlers struct {
-	projectService *services.ProjectService
-	cacheService   *services.CacheService
+	projectService  *services.ProjectService
+	categoryService *services.CategoryService
+	mediaService    *services.MediaService
+	revisionService *services.ProjectRevisionService
+	previewService  *services.ProjectPreviewService
+	cacheService    *services.CacheService
 }
 
-func NewProjectHandlers(projectService *services.ProjectService, cacheService *services.CacheService) *ProjectHandlers {
-	return &ProjectHandlers{projectService: projectService, cacheService: cacheService}
+func NewProjectHandlers(projectService *services.ProjectService, categoryService *services.CategoryService, mediaService *services.MediaService, revisionService *services.ProjectRevisionService, previewService *services.ProjectPreviewService, cacheService *services.CacheService) *ProjectHandlers {
+	return &ProjectHandlers{projectService: projectService, categoryService: categoryService, mediaService: mediaService, revisionService: revisionService, previewService: previewService, cacheService: cacheService}
 }
 
 // CreateProject godoc
 // @Summary Create a new project
-// @Description Create a new project
+// @Description Create a new project. Flexible projects (the default) charge donations straight away; all-or-nothing projects save the donor's card with each pledge and charge it when the campaign closes if it reached its goal.
 // @Tags projects
 // @Accept json
 // @Produce json
-// @Param project body models.Project true "Project details"
+// @Param project body models.CreateProject true "Project details"
+// @Param Idempotency-Key header string false "Unique key; retries with the same key and body replay the first response for 24 hours"
 // @Security ApiKeyAuth
 // @Success 201 {object} map[string]string{"message": "Project created successfully"}
 // @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 409 {object} map[string]string{"error": "Slug is already in use"}
+// @Failure 409 {object} map[string]string{"error": "a request with this idempotency key is still in progress"}
+// @Failure 422 {object} map[string]interface{}{"error": "Invalid input", "fields": map[string]string}
+// @Failure 422 {object} map[string]string{"error": "idempotency key was already used for a different request"}
 // @Failure 500 {object} map[string]string{"error": "Internal server error"}
 // @Router /api/projects [post]
 func (h *ProjectHandlers) CreateProject(c *gin.Context) {
-	var project models.Project
-	if err := c.ShouldBindJSON(&project); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var input models.CreateProject
+	if !bindJSON(c, &input) {
 		return
 	}
 
@@ -46,10 +62,20 @@ func (h *ProjectHandlers) CreateProject(c *gin.Context) {
 	}
 
 	userModel := user.(models.User)
-	project.UserID = userModel.ID
+	project := models.Project{
+		Title:       input.Title,
+		Slug:        input.Slug,
+		Description: input.Description,
+		Goal:        input.Goal,
+		StartDate:   input.StartDate,
+		EndDate:     input.EndDate,
+		UserID:      userModel.ID,
+		CategoryID:  input.CategoryID,
+		FundingMode: input.FundingMode,
+	}
 
 	if err := h.projectService.CreateProject(&project); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeProjectUpdateError(c, err)
 		return
 	}
 
@@ -57,55 +83,140 @@ func (h *ProjectHandlers) CreateProject(c *gin.Context) {
 }
 
 // GetProject godoc
-// @Summary Get a project by ID
-// @Description Get a project by ID
+// @Summary Get a project by ID or slug
+// @Description Get a project by numeric ID or slug. Slugs the project used to have redirect to its current slug. Projects that have not launched yet are only shown to their creator, admins, and holders of a preview link; projects taken down by moderators only to their creator and admins.
 // @Tags projects
 // @Produce json
-// @Param id path int true "Project ID"
+// @Param id path string true "Project ID or slug"
+// @Param preview query string false "Preview token for a draft project"
 // @Success 200 {object} models.Project
-// @Failure 400 {object} map[string]string{"error": "Invalid project ID"}
+// @Success 301 "Moved to the project's current slug"
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
 // @Failure 500 {object} map[string]string{"error": "Internal server error"}
 // @Router /api/projects/{id} [get]
 func (h *ProjectHandlers) GetProject(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
-		return
+		var current string
+		id, current, err = h.projectService.ResolveSlug(c.Param("id"))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if current != c.Param("id") {
+			location := "/api/projects/" + current
+			if c.Request.URL.RawQuery != "" {
+				location += "?" + c.Request.URL.RawQuery
+			}
+			c.Redirect(http.StatusMovedPermanently, location)
+			return
+		}
 	}
 
+	// Preview requests bypass the cache in both directions so a revoked
+	// link stops working immediately and drafts are never cached.
+	previewToken := c.Query("preview")
+
 	ctx := context.Background()
 	var project models.Project
 	cacheKey := "project:" + strconv.FormatUint(id, 10)
 
-	if err := h.cacheService.Get(ctx, cacheKey, &project); err == nil {
-		c.JSON(http.StatusOK, project)
-		return
+	if previewToken == "" {
+		if err := h.cacheService.Get(ctx, cacheKey, &project); err == nil && !project.IsDraft(time.Now()) {
+			h.writeProject(c, project)
+			return
+		}
 	}
 
 	project, err = h.projectService.GetProject(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Drafts are checked below, since a preview link may also show them.
+	if user, _ := currentUser(c); project.ModerationStatus != models.ModerationActive && !canManageProject(user, project) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	if project.IsDraft(time.Now()) || previewToken != "" {
+		if !h.canViewDraft(c, project, previewToken) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return
+		}
+		c.Header("Cache-Control", "private, no-store")
+		h.writeProject(c, project)
+		return
+	}
+	// Projects taken down by moderators are only shown to their managers
+	// and must not reach the shared cache.
+	if project.ModerationStatus != models.ModerationActive {
+		c.Header("Cache-Control", "private, no-store")
+		h.writeProject(c, project)
+		return
+	}
+
 	if err := h.cacheService.Set(ctx, cacheKey, project, 1*time.Hour); err != nil {
 		// Log error but don't fail request
 	}
 
+	h.writeProject(c, project)
+}
+
+// canViewDraft reports whether the request may see a project before launch:
+// the creator, an admin, or anyone with a valid preview token.
+func (h *ProjectHandlers) canViewDraft(c *gin.Context, project models.Project, previewToken string) bool {
+	if user, ok := currentUser(c); ok && canManageProject(user, project) {
+		return true
+	}
+	if previewToken == "" {
+		return false
+	}
+	err := h.previewService.Verify(uint64(project.ID), previewToken)
+	if err != nil && !errors.Is(err, services.ErrInvalidPreviewToken) {
+		log.Printf("Error checking preview token for project %d: %v", project.ID, err)
+	}
+	return err == nil
+}
+
+// writeProject sends the project with its ETag, or 304 if the client's copy
+// is current.
+func (h *ProjectHandlers) writeProject(c *gin.Context, project models.Project) {
+	etag := projectETag(project)
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	h.mediaService.SignProject(&project)
 	c.JSON(http.StatusOK, project)
 }
 
 // UpdateProject godoc
 // @Summary Update a project
-// @Description Update a project
+// @Description Replace a project's details. The category and the comment setting are kept when left out.
 // @Tags projects
 // @Accept json
 // @Produce json
 // @Param id path int true "Project ID"
-// @Param project body models.Project true "Updated project details"
+// @Param project body models.UpdateProject true "Updated project details"
+// @Param If-Match header string false "ETag from a previous GET"
 // @Security ApiKeyAuth
 // @Success 200 {object} map[string]string{"message": "Project updated successfully"}
 // @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 403 {object} map[string]string{"error": "Forbidden"}
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
+// @Failure 412 {object} map[string]string{"error": "Project was modified by someone else"}
+// @Failure 422 {object} map[string]interface{}{"error": "Invalid input", "fields": map[string]string}
+// @Failure 422 {object} map[string]interface{}{"error": "Invalid changes", "fields": map[string]string}
 // @Failure 500 {object} map[string]string{"error": "Internal server error"}
 // @Router /api/projects/{id} [put]
 func (h *ProjectHandlers) UpdateProject(c *gin.Context) {
@@ -115,32 +226,198 @@ func (h *ProjectHandlers) UpdateProject(c *gin.Context) {
 		return
 	}
 
-	var project models.Project
-	if err := c.ShouldBindJSON(&project); err != nil {
+	var input models.UpdateProject
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	existing, ok := authorizeProjectOwner(c, h.projectService, id)
+	if !ok {
+		return
+	}
+	if !ifMatch(c, existing) {
+		return
+	}
+
+	project := applyProjectUpdate(existing, input)
+	editor, _ := currentUser(c)
+	changes, err := h.projectService.UpdateProject(existing, &project, editor.ID)
+	if err != nil {
+		writeProjectUpdateError(c, err)
+		return
+	}
+
+	h.cacheService.InvalidateProjectCache(id)
+	go h.revisionService.NotifyMaterialChanges(project, changes)
+
+	c.Header("ETag", projectETag(project))
+	c.JSON(http.StatusOK, gin.H{"message": "Project updated successfully"})
+}
+
+// applyProjectUpdate returns project with the fields input declares
+// replaced. Everything else, including the category and comment setting
+// when left out, is kept.
+func applyProjectUpdate(project models.Project, input models.UpdateProject) models.Project {
+	project.Title = input.Title
+	project.Slug = input.Slug
+	project.Description = input.Description
+	project.Goal = input.Goal
+	project.StartDate = input.StartDate
+	project.EndDate = input.EndDate
+	project.FundingMode = input.FundingMode
+	if input.CategoryID != nil {
+		project.CategoryID = input.CategoryID
+	}
+	if input.CommentsBackersOnly != nil {
+		project.CommentsBackersOnly = *input.CommentsBackersOnly
+	}
+	return project
+}
+
+// PatchProject godoc
+// @Summary Partially update a project
+// @Description Apply a JSON Merge Patch (RFC 7396) to a project. Only fields present in the patch change.
+// @Tags projects
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param patch body object true "Fields to change; null clears optional fields"
+// @Param If-Match header string false "ETag from a previous GET"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Project
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 403 {object} map[string]string{"error": "Forbidden"}
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
+// @Failure 412 {object} map[string]string{"error": "Project was modified by someone else"}
+// @Failure 422 {object} map[string]interface{}{"error": "Invalid changes", "fields": map[string]string}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id} [patch]
+func (h *ProjectHandlers) PatchProject(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Patch must be a JSON object"})
+		return
+	}
+	readOnly := readOnlyFieldErrors(fields)
+	if len(readOnly) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid changes", "fields": readOnly})
+		return
+	}
 
-	project.ID = uint(id)
+	existing, ok := authorizeProjectOwner(c, h.projectService, id)
+	if !ok {
+		return
+	}
+	if !ifMatch(c, existing) {
+		return
+	}
 
-	if err := h.projectService.UpdateProject(&project); err != nil {
+	current, err := json.Marshal(existing)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	merged, err := utils.MergePatch(current, patch)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var project models.Project
+	if err := json.Unmarshal(merged, &project); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
+	editor, _ := currentUser(c)
+	changes, err := h.projectService.UpdateProject(existing, &project, editor.ID)
+	if err != nil {
+		writeProjectUpdateError(c, err)
+		return
+	}
 	h.cacheService.InvalidateProjectCache(id)
+	go h.revisionService.NotifyMaterialChanges(project, changes)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Project updated successfully"})
+	updated, err := h.projectService.GetProject(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.writeProject(c, updated)
+}
+
+// readOnlyFieldErrors reports patch keys that are not editable.
+func readOnlyFieldErrors(fields map[string]json.RawMessage) services.FieldErrors {
+	errs := services.FieldErrors{}
+	for field := range fields {
+		if !services.EditableProjectFields[field] {
+			errs[field] = "cannot be changed"
+		}
+	}
+	return errs
+}
+
+// projectETag identifies a version of a project's representation.
+func projectETag(project models.Project) string {
+	return fmt.Sprintf(`"%d-%d"`, project.ID, project.Version)
+}
+
+// ifMatch enforces an If-Match precondition if the client sent one. It
+// writes a 412 and returns false when the client's copy is stale.
+func ifMatch(c *gin.Context, project models.Project) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return true
+	}
+	etag := projectETag(project)
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	c.Header("ETag", etag)
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": services.ErrVersionConflict.Error()})
+	return false
+}
+
+func writeProjectUpdateError(c *gin.Context, err error) {
+	var fieldErrs services.FieldErrors
+	switch {
+	case errors.As(err, &fieldErrs):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid changes", "fields": fieldErrs})
+	case errors.Is(err, services.ErrVersionConflict):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSlug):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid changes", "fields": services.FieldErrors{"slug": err.Error()}})
+	case errors.Is(err, services.ErrSlugTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
 }
 
 // DeleteProject godoc
 // @Summary Delete a project
-// @Description Delete a project
+// @Description Soft-delete a project. Projects holding donations cannot be deleted until every donation has been refunded; see POST /api/projects/{id}/cancel. Legacy donations, made before payments went through a provider, are refunded by hand; once they are, an admin can delete the project with DELETE /api/admin/projects/{id}.
 // @Tags projects
 // @Param id path int true "Project ID"
 // @Security ApiKeyAuth
 // @Success 200 {object} map[string]string{"message": "Project deleted successfully"}
 // @Failure 400 {object} map[string]string{"error": "Invalid project ID"}
+// @Failure 403 {object} map[string]string{"error": "Forbidden"}
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
+// @Failure 409 {object} map[string]string{"error": "project holds donations that have not been refunded"}
 // @Failure 500 {object} map[string]string{"error": "Internal server error"}
 // @Router /api/projects/{id} [delete]
 func (h *ProjectHandlers) DeleteProject(c *gin.Context) {
@@ -150,8 +427,44 @@ func (h *ProjectHandlers) DeleteProject(c *gin.Context) {
 		return
 	}
 
-	if err := h.projectService.DeleteProject(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if _, ok := authorizeProjectOwner(c, h.projectService, id); !ok {
+		return
+	}
+
+	h.deleteProject(c, id, false)
+}
+
+// AdminDeleteProject godoc
+// @Summary Delete a project holding legacy donations
+// @Description Soft-delete a project whose remaining donations are all legacy ones, made before payments went through a provider. The platform cannot refund them, so only call this once they have been settled by hand (admin only). Any other donation still blocks deletion.
+// @Tags projects
+// @Param id path int true "Project ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]string{"message": "Project deleted successfully"}
+// @Failure 400 {object} map[string]string{"error": "Invalid project ID"}
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
+// @Failure 409 {object} map[string]string{"error": "project holds donations that have not been refunded"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/admin/projects/{id} [delete]
+func (h *ProjectHandlers) AdminDeleteProject(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	h.deleteProject(c, id, true)
+}
+
+func (h *ProjectHandlers) deleteProject(c *gin.Context, id uint64, allowLegacy bool) {
+	if err := h.projectService.DeleteProject(id, allowLegacy); err != nil {
+		switch {
+		case errors.Is(err, services.ErrProjectHasDonations):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 	h.cacheService.InvalidateProjectCache(id)
@@ -159,20 +472,124 @@ func (h *ProjectHandlers) DeleteProject(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Project deleted successfully"})
 }
 
+// RestoreProject godoc
+// @Summary Restore a deleted project
+// @Description Undo a soft delete (admin only)
+// @Tags projects
+// @Param id path int true "Project ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]string{"message": "Project restored successfully"}
+// @Failure 400 {object} map[string]string{"error": "Invalid project ID"}
+// @Failure 404 {object} map[string]string{"error": "Deleted project not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/admin/projects/{id}/restore [post]
+func (h *ProjectHandlers) RestoreProject(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	if err := h.projectService.RestoreProject(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Deleted project not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.cacheService.InvalidateProjectCache(id)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project restored successfully"})
+}
+
 // ListProjects godoc
 // @Summary List all projects
-// @Description List all projects
+// @Description List launched projects, optionally filtered by category (including subcategories) or tag. Drafts are not listed.
 // @Tags projects
 // @Produce json
+// @Param category query int false "Category ID"
+// @Param tag query string false "Tag name"
 // @Success 200 {array} models.Project
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
 // @Failure 500 {object} map[string]string{"error": "Internal server error"}
 // @Router /api/projects [get]
 func (h *ProjectHandlers) ListProjects(c *gin.Context) {
-	projects, err := h.projectService.ListProjects()
+	var filter models.ProjectFilter
+	if category := c.Query("category"); category != "" {
+		categoryID, err := strconv.ParseUint(category, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+			return
+		}
+		filter.CategoryIDs, err = h.categoryService.DescendantIDs(uint(categoryID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if tag := c.Query("tag"); tag != "" {
+		normalized, err := services.NormalizeTag(tag)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter.Tag = normalized
+	}
+
+	projects, err := h.projectService.ListProjects(filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	for i := range projects {
+		h.mediaService.SignProject(&projects[i])
+	}
 
 	c.JSON(http.StatusOK, projects)
 }
+
+// SetProjectTags godoc
+// @Summary Set project tags
+// @Description Replace the tags on a project. Tags are normalized to lowercase hyphenated form.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param tags body models.SetProjectTags true "Tag names"
+// @Security ApiKeyAuth
+// @Success 200 {array} models.Tag
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 403 {object} map[string]string{"error": "Forbidden"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/tags [put]
+func (h *ProjectHandlers) SetProjectTags(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var input models.SetProjectTags
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := authorizeProjectOwner(c, h.projectService, id); !ok {
+		return
+	}
+
+	tags, err := h.categoryService.SetProjectTags(id, input.Tags)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidTag) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.cacheService.InvalidateProjectCache(id)
+
+	c.JSON(http.StatusOK, tags)
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"crowdfund/backend/models"
+	"crowdfund/backend/services"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ProjectPreviewHandlers struct {
+	previewService *services.ProjectPreviewService
+	projectService *services.ProjectService
+}
+
+func NewProjectPreviewHandlers(previewService *services.ProjectPreviewService, projectService *services.ProjectService) *ProjectPreviewHandlers {
+	return &ProjectPreviewHandlers{previewService: previewService, projectService: projectService}
+}
+
+// CreatePreview godoc
+// @Summary Create a preview link
+// @Description Issue a signed, expiring link that lets anyone view the project before it launches. The token is only returned here.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param preview body models.CreateProjectPreview false "Link lifetime"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.ProjectPreview
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 403 {object} map[string]string{"error": "Forbidden"}
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/previews [post]
+func (h *ProjectPreviewHandlers) CreatePreview(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	var input models.CreateProjectPreview
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	ttl := services.DefaultPreviewTTL
+	if input.ExpiresInHours != 0 {
+		ttl = time.Duration(input.ExpiresInHours) * time.Hour
+	}
+	if ttl <= 0 || ttl > services.MaxPreviewTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_hours must be between 1 and 720"})
+		return
+	}
+
+	project, ok := authorizeProjectOwner(c, h.projectService, id)
+	if !ok {
+		return
+	}
+	user, _ := currentUser(c)
+
+	preview, err := h.previewService.Create(project.ID, user.ID, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, preview)
+}
+
+// ListPreviews godoc
+// @Summary List preview links
+// @Description List the project's preview links, including expired and revoked ones
+// @Tags projects
+// @Produce json
+// @Param id path int true "Project ID"
+// @Security ApiKeyAuth
+// @Success 200 {array} models.ProjectPreview
+// @Failure 400 {object} map[string]string{"error": "Invalid project ID"}
+// @Failure 403 {object} map[string]string{"error": "Forbidden"}
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/previews [get]
+func (h *ProjectPreviewHandlers) ListPreviews(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	if _, ok := authorizeProjectOwner(c, h.projectService, id); !ok {
+		return
+	}
+
+	previews, err := h.previewService.List(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, previews)
+}
+
+// RevokePreview godoc
+// @Summary Revoke a preview link
+// @Description Stop a preview link from working before it expires
+// @Tags projects
+// @Param id path int true "Project ID"
+// @Param previewID path int true "Preview ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]string{"message": "Preview link revoked"}
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 403 {object} map[string]string{"error": "Forbidden"}
+// @Failure 404 {object} map[string]string{"error": "Preview link not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/previews/{previewID} [delete]
+func (h *ProjectPreviewHandlers) RevokePreview(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	previewID, err := strconv.ParseUint(c.Param("previewID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid preview ID"})
+		return
+	}
+	if _, ok := authorizeProjectOwner(c, h.projectService, id); !ok {
+		return
+	}
+
+	if err := h.previewService.Revoke(id, previewID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Preview link not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Preview link revoked"})
+}
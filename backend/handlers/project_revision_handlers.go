@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"crowdfund/backend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProjectRevisionHandlers struct {
+	revisionService *services.ProjectRevisionService
+	projectService  *services.ProjectService
+}
+
+func NewProjectRevisionHandlers(revisionService *services.ProjectRevisionService, projectService *services.ProjectService) *ProjectRevisionHandlers {
+	return &ProjectRevisionHandlers{revisionService: revisionService, projectService: projectService}
+}
+
+// ListRevisions godoc
+// @Summary List project revisions
+// @Description List every saved version of a project, newest first, with who changed what. Revisions of projects that have not launched are only shown to their creator and admins.
+// @Tags projects
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 200 {array} models.ProjectRevision
+// @Failure 400 {object} map[string]string{"error": "Invalid project ID"}
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/revisions [get]
+func (h *ProjectRevisionHandlers) ListRevisions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	project, err := h.projectService.GetProject(id)
+	if err != nil || !canViewProject(c, project) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	revisions, err := h.revisionService.ListRevisions(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// DiffRevisions godoc
+// @Summary Diff two project revisions
+// @Description Show the fields that differ between two versions of a project. Projects that have not launched are only shown to their creator and admins.
+// @Tags projects
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param from query int true "Older version"
+// @Param to query int true "Newer version"
+// @Success 200 {object} models.RevisionDiff
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 404 {object} map[string]string{"error": "Revision not found"}
+// @Router /api/projects/{id}/revisions/diff [get]
+func (h *ProjectRevisionHandlers) DiffRevisions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from version"})
+		return
+	}
+	to, err := strconv.Atoi(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to version"})
+		return
+	}
+	project, err := h.projectService.GetProject(id)
+	if err != nil || !canViewProject(c, project) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	diff, err := h.revisionService.Diff(id, from, to)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
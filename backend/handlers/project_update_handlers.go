@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"crowdfund/backend/models"
+	"crowdfund/backend/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProjectUpdateHandlers struct {
+	updateService   *services.ProjectUpdateService
+	projectService  *services.ProjectService
+	donationService *services.DonationService
+}
+
+func NewProjectUpdateHandlers(updateService *services.ProjectUpdateService, projectService *services.ProjectService, donationService *services.DonationService) *ProjectUpdateHandlers {
+	return &ProjectUpdateHandlers{updateService: updateService, projectService: projectService, donationService: donationService}
+}
+
+// CreateUpdate godoc
+// @Summary Post a project update
+// @Description Post a markdown progress update. Backers are emailed when it is published, immediately or at publish_at.
+// @Tags updates
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param update body models.CreateProjectUpdate true "Update details"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.ProjectUpdate
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 403 {object} map[string]string{"error": "Forbidden"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/updates [post]
+func (h *ProjectUpdateHandlers) CreateUpdate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var input models.CreateProjectUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := authorizeProjectOwner(c, h.projectService, id); !ok {
+		return
+	}
+	userModel, _ := currentUser(c)
+
+	update := models.ProjectUpdate{
+		ProjectID:    uint(id),
+		UserID:       userModel.ID,
+		Title:        input.Title,
+		BodyMarkdown: input.BodyMarkdown,
+		BackersOnly:  input.BackersOnly,
+	}
+	if input.PublishAt != nil {
+		update.PublishAt = *input.PublishAt
+	}
+
+	if err := h.updateService.CreateUpdate(&update); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, update)
+}
+
+// ListUpdates godoc
+// @Summary List project updates
+// @Description List published updates, newest first. Backers-only updates are locked for non-backers; the creator also sees scheduled updates.
+// @Tags updates
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 200 {array} models.ProjectUpdate
+// @Failure 400 {object} map[string]string{"error": "Invalid project ID"}
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/updates [get]
+func (h *ProjectUpdateHandlers) ListUpdates(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	project, err := h.projectService.GetProject(id)
+	if err != nil || !canViewProject(c, project) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	manager, canSeeBackersOnly, err := viewerAccess(c, h.donationService, project)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates, err := h.updateService.ListUpdates(id, manager)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range updates {
+		if updates[i].BackersOnly && !canSeeBackersOnly {
+			lockUpdate(&updates[i])
+		}
+	}
+
+	c.JSON(http.StatusOK, updates)
+}
+
+// GetUpdate godoc
+// @Summary Get a project update
+// @Description Get a single update; backers-only content is locked for non-backers
+// @Tags updates
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param updateID path int true "Update ID"
+// @Success 200 {object} models.ProjectUpdate
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 404 {object} map[string]string{"error": "Update not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/updates/{updateID} [get]
+func (h *ProjectUpdateHandlers) GetUpdate(c *gin.Context) {
+	id, updateID, ok := parseUpdateParams(c)
+	if !ok {
+		return
+	}
+
+	project, err := h.projectService.GetProject(id)
+	if err != nil || !canViewProject(c, project) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	update, err := h.updateService.GetUpdate(id, updateID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Update not found"})
+		return
+	}
+
+	manager, canSeeBackersOnly, err := viewerAccess(c, h.donationService, project)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if update.PublishAt.After(time.Now()) && !manager {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Update not found"})
+		return
+	}
+	if update.BackersOnly && !canSeeBackersOnly {
+		lockUpdate(&update)
+	}
+
+	c.JSON(http.StatusOK, update)
+}
+
+// EditUpdate godoc
+// @Summary Edit a project update
+// @Description Edit an update's content, visibility or publish time
+// @Tags updates
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param updateID path int true "Update ID"
+// @Param update body models.CreateProjectUpdate true "Update details"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.ProjectUpdate
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 403 {object} map[string]string{"error": "Forbidden"}
+// @Failure 404 {object} map[string]string{"error": "Update not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/updates/{updateID} [put]
+func (h *ProjectUpdateHandlers) EditUpdate(c *gin.Context) {
+	id, updateID, ok := parseUpdateParams(c)
+	if !ok {
+		return
+	}
+
+	var input models.CreateProjectUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := authorizeProjectOwner(c, h.projectService, id); !ok {
+		return
+	}
+	update, err := h.updateService.GetUpdate(id, updateID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Update not found"})
+		return
+	}
+
+	update.Title = input.Title
+	update.BodyMarkdown = input.BodyMarkdown
+	update.BackersOnly = input.BackersOnly
+	if input.PublishAt != nil {
+		update.PublishAt = *input.PublishAt
+	}
+	if err := h.updateService.EditUpdate(&update); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, update)
+}
+
+// DeleteUpdate godoc
+// @Summary Delete a project update
+// @Description Delete a project update
+// @Tags updates
+// @Param id path int true "Project ID"
+// @Param updateID path int true "Update ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]string{"message": "Update deleted successfully"}
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 403 {object} map[string]string{"error": "Forbidden"}
+// @Failure 404 {object} map[string]string{"error": "Update not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/updates/{updateID} [delete]
+func (h *ProjectUpdateHandlers) DeleteUpdate(c *gin.Context) {
+	id, updateID, ok := parseUpdateParams(c)
+	if !ok {
+		return
+	}
+
+	if _, ok := authorizeProjectOwner(c, h.projectService, id); !ok {
+		return
+	}
+	if _, err := h.updateService.GetUpdate(id, updateID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Update not found"})
+		return
+	}
+	if err := h.updateService.DeleteUpdate(updateID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Update deleted successfully"})
+}
+
+// viewerAccess works out what the (possibly anonymous) viewer may see:
+// managers see everything including scheduled updates, backers additionally
+// see backers-only content.
+func viewerAccess(c *gin.Context, donationService *services.DonationService, project models.Project) (manager bool, backer bool, err error) {
+	user, ok := currentUser(c)
+	if !ok {
+		return false, false, nil
+	}
+	if canManageProject(user, project) {
+		return true, true, nil
+	}
+	backer, err = donationService.IsBacker(uint64(project.ID), user.ID)
+	return false, backer, err
+}
+
+func lockUpdate(update *models.ProjectUpdate) {
+	update.BodyMarkdown = ""
+	update.BodyHTML = ""
+	update.Locked = true
+}
+
+func parseUpdateParams(c *gin.Context) (uint64, uint64, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return 0, 0, false
+	}
+	updateID, err := strconv.ParseUint(c.Param("updateID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid update ID"})
+		return 0, 0, false
+	}
+	return id, updateID, true
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"crowdfund/backend/models"
+	"crowdfund/backend/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RankingHandlers struct {
+	rankingService *services.RankingService
+}
+
+func NewRankingHandlers(rankingService *services.RankingService) *RankingHandlers {
+	return &RankingHandlers{rankingService: rankingService}
+}
+
+// Trending godoc
+// @Summary Trending projects
+// @Description List running projects ranked by recent donation velocity, new backers and new follows. The ranking is refreshed every few minutes.
+// @Tags projects
+// @Produce json
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Items per page (default 20, max 100)"
+// @Success 200 {array} models.Project
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/trending [get]
+func (h *RankingHandlers) Trending(c *gin.Context) {
+	page, perPage := parsePagination(c)
+	projects, err := h.rankingService.Trending(c.Request.Context(), page, perPage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, projects)
+}
+
+// Featured godoc
+// @Summary Featured projects
+// @Description List the projects admins have placed in featured slots, in slot order
+// @Tags projects
+// @Produce json
+// @Success 200 {array} models.Project
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/featured [get]
+func (h *RankingHandlers) Featured(c *gin.Context) {
+	projects, err := h.rankingService.Featured(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, projects)
+}
+
+// ListFeaturedSlots godoc
+// @Summary List featured slots
+// @Description List every featured slot, including expired ones (admin only)
+// @Tags projects
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.FeaturedProject
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/admin/featured [get]
+func (h *RankingHandlers) ListFeaturedSlots(c *gin.Context) {
+	slots, err := h.rankingService.FeaturedSlots()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, slots)
+}
+
+// SetFeaturedSlot godoc
+// @Summary Feature a project
+// @Description Put a project in a featured slot, replacing the slot's current project. A project already featured elsewhere is moved (admin only).
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param slot path int true "Slot number, starting at 1"
+// @Param featured body models.SetFeaturedProject true "Project to feature"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.FeaturedProject
+// @Failure 400 {object} map[string]string{"error": "Invalid request"}
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/admin/featured/{slot} [put]
+func (h *RankingHandlers) SetFeaturedSlot(c *gin.Context) {
+	slot, err := strconv.Atoi(c.Param("slot"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slot"})
+		return
+	}
+	var input models.SetFeaturedProject
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	admin, _ := currentUser(c)
+
+	featured, err := h.rankingService.SetFeatured(c.Request.Context(), slot, admin.ID, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidFeaturedSlot):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, featured)
+}
+
+// ClearFeaturedSlot godoc
+// @Summary Empty a featured slot
+// @Description Remove the project from a featured slot (admin only)
+// @Tags projects
+// @Param slot path int true "Slot number"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]string{"message": "Featured slot cleared"}
+// @Failure 400 {object} map[string]string{"error": "Invalid slot"}
+// @Failure 404 {object} map[string]string{"error": "Featured slot is empty"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/admin/featured/{slot} [delete]
+func (h *RankingHandlers) ClearFeaturedSlot(c *gin.Context) {
+	slot, err := strconv.Atoi(c.Param("slot"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slot"})
+		return
+	}
+
+	if err := h.rankingService.ClearFeatured(c.Request.Context(), slot); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Featured slot is empty"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Featured slot cleared"})
+}
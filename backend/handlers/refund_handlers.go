@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"crowdfund/backend/models"
+	"crowdfund/backend/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RefundHandlers struct {
+	refundService   *services.RefundService
+	donationService *services.DonationService
+	projectService  *services.ProjectService
+	cacheService    *services.CacheService
+}
+
+func NewRefundHandlers(refundService *services.RefundService, donationService *services.DonationService, projectService *services.ProjectService, cacheService *services.CacheService) *RefundHandlers {
+	return &RefundHandlers{refundService: refundService, donationService: donationService, projectService: projectService, cacheService: cacheService}
+}
+
+// CreateRefund godoc
+// @Summary Refund a donation
+// @Description Refund all or part of a successful donation to the donor's original payment method. The project's creator may refund donations made within the refund window; admins may refund at any time. Without an amount, everything not yet refunded is refunded. The refund is sent to the payment provider in the background and the donor is emailed once it goes through.
+// @Tags donations
+// @Accept json
+// @Produce json
+// @Param ref path string true "Donation reference"
+// @Param refund body models.CreateRefund true "Refund details"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.Refund
+// @Failure 403 {object} map[string]string{"error": "the refund window for this donation has closed"}
+// @Failure 404 {object} map[string]string{"error": "Donation not found"}
+// @Failure 409 {object} map[string]string{"error": "only successful donations can be refunded"}
+// @Failure 422 {object} map[string]interface{}{"error": "Invalid input", "fields": map[string]string}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/donations/{ref}/refunds [post]
+func (h *RefundHandlers) CreateRefund(c *gin.Context) {
+	var input models.CreateRefund
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	user, _ := currentUser(c)
+	refund, err := h.refundService.RequestRefund(c.Request.Context(), user, c.Param("ref"), input)
+	if err != nil {
+		var fieldErrs services.FieldErrors
+		switch {
+		case errors.As(err, &fieldErrs):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid input", "fields": fieldErrs})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Donation not found"})
+		case errors.Is(err, services.ErrRefundForbidden), errors.Is(err, services.ErrRefundWindowClosed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrDonationNotRefundable):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, refund)
+}
+
+// ListRefunds godoc
+// @Summary List a donation's refunds
+// @Description List the refunds of a donation, oldest first. Visible to the donor, the project's creator and admins.
+// @Tags donations
+// @Produce json
+// @Param ref path string true "Donation reference"
+// @Security ApiKeyAuth
+// @Success 200 {array} models.Refund
+// @Failure 404 {object} map[string]string{"error": "Donation not found"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/donations/{ref}/refunds [get]
+func (h *RefundHandlers) ListRefunds(c *gin.Context) {
+	donation, err := h.donationService.GetDonationByReference(c.Param("ref"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Donation not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Other people's donations look the same as missing ones.
+	user, _ := currentUser(c)
+	if donation.UserID != user.ID && !user.IsAdmin {
+		project, err := h.projectService.GetProject(uint64(donation.ProjectID))
+		if err != nil || !canManageProject(user, project) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Donation not found"})
+			return
+		}
+	}
+
+	refunds, err := h.refundService.ListRefunds(donation.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, refunds)
+}
+
+// CancelProject godoc
+// @Summary Cancel a project
+// @Description Call off a project's campaign before it closes. It stops accepting donations, every successful donation is refunded in full and all-or-nothing pledges are released; donors are emailed as their refunds go through. Donations still being charged fail or are refunded once charged. The project can be deleted after all refunds have gone through.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param cancellation body models.CancelProject false "Reason given to donors"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}{"message": "Project cancelled", "refunds": 0}
+// @Failure 400 {object} map[string]string{"error": "Invalid project ID"}
+// @Failure 403 {object} map[string]string{"error": "Forbidden"}
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
+// @Failure 409 {object} map[string]string{"error": "project has been cancelled"}
+// @Failure 409 {object} map[string]string{"error": "project's campaign has already closed"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/cancel [post]
+func (h *RefundHandlers) CancelProject(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var input models.CancelProject
+	if c.Request.ContentLength != 0 && !bindJSON(c, &input) {
+		return
+	}
+
+	if _, ok := authorizeProjectOwner(c, h.projectService, id); !ok {
+		return
+	}
+
+	refunds, err := h.refundService.CancelProject(c.Request.Context(), uint(id), input.Reason)
+	if err != nil {
+		if errors.Is(err, services.ErrProjectCancelled) || errors.Is(err, services.ErrProjectClosed) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.cacheService.InvalidateProjectCache(id)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project cancelled", "refunds": refunds})
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"crowdfund/backend/models"
+	"crowdfund/backend/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SubscriptionHandlers struct {
+	subscriptionService *services.SubscriptionService
+}
+
+func NewSubscriptionHandlers(subscriptionService *services.SubscriptionService) *SubscriptionHandlers {
+	return &SubscriptionHandlers{subscriptionService: subscriptionService}
+}
+
+// CreateSubscription godoc
+// @Summary Start a recurring donation
+// @Description Donate to a running flexible project every month or year, paying with a card token from the payment provider. The card is saved with the provider and charged without the donor present, so cards that need the donor to approve each payment are refused. The first charge is made at the start date (now if omitted) and later ones on the same day of each cycle. Failed charges are retried after 1, 3 and 7 days, with an email each time, before the plan is cancelled.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param subscription body models.CreateSubscription true "Plan details"
+// @Param Idempotency-Key header string false "Unique key; retries with the same key and body replay the first response for 24 hours"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.Subscription
+// @Failure 400 {object} map[string]string{"error": "Invalid project ID"}
+// @Failure 404 {object} map[string]string{"error": "Project not found"}
+// @Failure 422 {object} map[string]interface{}{"error": "Invalid input", "fields": map[string]string}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/projects/{id}/subscriptions [post]
+func (h *SubscriptionHandlers) CreateSubscription(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var input models.CreateSubscription
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	user, _ := currentUser(c)
+	subscription, err := h.subscriptionService.CreateSubscription(c.Request.Context(), user.ID, uint(projectID), input)
+	if err != nil {
+		var fieldErrs services.FieldErrors
+		switch {
+		case errors.As(err, &fieldErrs):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid input", "fields": fieldErrs})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		case errors.Is(err, services.ErrProjectNotAcceptingDonations), errors.Is(err, services.ErrRecurringNotSupported):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid input", "fields": services.FieldErrors{"project_id": err.Error()}})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// ListSubscriptions godoc
+// @Summary List your recurring donations
+// @Description List your recurring donation plans, newest first, including paused and cancelled ones
+// @Tags subscriptions
+// @Produce json
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Items per page (default 20, max 100)"
+// @Security ApiKeyAuth
+// @Success 200 {array} models.Subscription
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/users/subscriptions [get]
+func (h *SubscriptionHandlers) ListSubscriptions(c *gin.Context) {
+	user, _ := currentUser(c)
+	page, perPage := parsePagination(c)
+
+	subscriptions, err := h.subscriptionService.ListSubscriptions(user.ID, page, perPage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// PauseSubscription godoc
+// @Summary Pause a recurring donation
+// @Description Stop charging an active or past due plan until it is resumed. Retries of a failed charge are dropped.
+// @Tags subscriptions
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Subscription
+// @Failure 404 {object} map[string]string{"error": "Subscription not found"}
+// @Failure 409 {object} map[string]string{"error": "subscription cannot be changed from its current status"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/users/subscriptions/{id}/pause [post]
+func (h *SubscriptionHandlers) PauseSubscription(c *gin.Context) {
+	h.change(c, h.subscriptionService.PauseSubscription)
+}
+
+// ResumeSubscription godoc
+// @Summary Resume a recurring donation
+// @Description Restart a paused plan. Billing picks up at the next cycle date; cycles that fell while it was paused are not charged.
+// @Tags subscriptions
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Subscription
+// @Failure 404 {object} map[string]string{"error": "Subscription not found"}
+// @Failure 409 {object} map[string]string{"error": "subscription cannot be changed from its current status"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/users/subscriptions/{id}/resume [post]
+func (h *SubscriptionHandlers) ResumeSubscription(c *gin.Context) {
+	h.change(c, h.subscriptionService.ResumeSubscription)
+}
+
+// CancelSubscription godoc
+// @Summary Cancel a recurring donation
+// @Description Stop a plan for good. A charge already under way still completes.
+// @Tags subscriptions
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Subscription
+// @Failure 404 {object} map[string]string{"error": "Subscription not found"}
+// @Failure 409 {object} map[string]string{"error": "subscription cannot be changed from its current status"}
+// @Failure 500 {object} map[string]string{"error": "Internal server error"}
+// @Router /api/users/subscriptions/{id}/cancel [post]
+func (h *SubscriptionHandlers) CancelSubscription(c *gin.Context) {
+	h.change(c, h.subscriptionService.CancelSubscription)
+}
+
+// change runs one of the plan actions on the authenticated user's plan.
+// Other people's plans look the same as missing ones.
+func (h *SubscriptionHandlers) change(c *gin.Context, action func(userID, id uint) (models.Subscription, error)) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	user, _ := currentUser(c)
+	subscription, err := action(user.ID, uint(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		case errors.Is(err, services.ErrSubscriptionState):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"crowdfund/backend/models"
+	"crowdfund/backend/services"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		// Report fields by their JSON names so errors match the request body.
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+		v.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+			return strings.TrimSpace(fl.Field().String()) != ""
+		})
+		// Rules such as gt=0 on a Money field apply to its amount.
+		v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+			return field.Interface().(models.Money).Amount
+		}, models.Money{})
+	}
+}
+
+// bindJSON binds the request body into input and runs its binding rules.
+// Malformed JSON is a 400; rule violations are a 422 listing each field.
+// On failure it writes the response and returns false.
+func bindJSON(c *gin.Context, input interface{}) bool {
+	err := c.ShouldBindJSON(input)
+	if err == nil {
+		return true
+	}
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid input", "fields": fieldErrors(validationErrs)})
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	return false
+}
+
+// fieldErrors turns validator errors into messages keyed by JSON field name.
+// The messages match the ones services.ValidateProjectChange uses.
+func fieldErrors(errs validator.ValidationErrors) services.FieldErrors {
+	fields := services.FieldErrors{}
+	for _, fe := range errs {
+		if _, seen := fields[fe.Field()]; seen {
+			continue
+		}
+		fields[fe.Field()] = fieldMessage(fe)
+	}
+	return fields
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "notblank":
+		return "must not be empty"
+	case "gt":
+		if fe.Param() == "0" {
+			return "must be greater than zero"
+		}
+		return "must be greater than " + fe.Param()
+	case "gtfield":
+		return "must be after " + jsonFieldName(fe)
+	case "max":
+		return fmt.Sprintf("must be at most %s characters", fe.Param())
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	}
+	return "is invalid"
+}
+
+// jsonFieldName returns the JSON name of the field a cross-field rule
+// compares against, e.g. "start_date" for gtfield=StartDate.
+func jsonFieldName(fe validator.FieldError) string {
+	param := fe.Param()
+	var name []rune
+	for i, r := range param {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				name = append(name, '_')
+			}
+			r += 'a' - 'A'
+		}
+		name = append(name, r)
+	}
+	return string(name)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bytes"
+	"crowdfund/backend/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func bindRequest(t *testing.T, input interface{}, body string) (*httptest.ResponseRecorder, bool) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	return w, bindJSON(c, input)
+}
+
+// TestBindJSONCreateProject tests that invalid projects are rejected field by field
+func TestBindJSONCreateProject(t *testing.T) {
+	var input models.CreateProject
+	w, ok := bindRequest(t, &input, `{"title": "  ", "goal": {"amount": "-5", "currency": "USD"}, "start_date": "2026-06-01T00:00:00Z", "end_date": "2026-05-01T00:00:00Z"}`)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var response struct {
+		Fields map[string]string `json:"fields"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, map[string]string{
+		"title":    "must not be empty",
+		"goal":     "must be greater than zero",
+		"end_date": "must be after start_date",
+	}, response.Fields)
+
+	input = models.CreateProject{}
+	_, ok = bindRequest(t, &input, `{"title": "Kettle", "goal": {"amount": "500", "currency": "usd"}, "start_date": "2026-05-01T00:00:00Z", "end_date": "2026-06-01T00:00:00Z"}`)
+	assert.True(t, ok)
+	assert.Equal(t, "Kettle", input.Title)
+	assert.Equal(t, models.Money{Amount: 50000, Currency: "USD"}, input.Goal)
+
+	for _, body := range []string{`{"title": `, `{"goal": {"amount": "5.001", "currency": "USD"}}`, `{"goal": {"amount": "5", "currency": "XYZ"}}`} {
+		w, ok = bindRequest(t, &input, body)
+		assert.False(t, ok, body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+// TestBindJSONUpdateProject tests that replacing a project validates the new details and keeps fields left out
+func TestBindJSONUpdateProject(t *testing.T) {
+	var input models.UpdateProject
+	w, ok := bindRequest(t, &input, `{"title": "", "goal": {"amount": "5", "currency": "EUR"}, "start_date": "2026-05-01T00:00:00Z", "end_date": "2026-06-01T00:00:00Z", "funding_mode": "sometimes"}`)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), `"title"`)
+	assert.Contains(t, w.Body.String(), `"funding_mode"`)
+
+	category := uint(4)
+	existing := models.Project{ID: 2, Title: "Kettle", CategoryID: &category, CommentsBackersOnly: true, FundingMode: models.FundingFlexible, Version: 3}
+	input = models.UpdateProject{}
+	_, ok = bindRequest(t, &input, `{"title": "Solar kettle", "goal": {"amount": "500", "currency": "EUR"}, "start_date": "2026-05-01T00:00:00Z", "end_date": "2026-06-01T00:00:00Z"}`)
+	assert.True(t, ok)
+	project := applyProjectUpdate(existing, input)
+	assert.Equal(t, "Solar kettle", project.Title)
+	assert.Equal(t, &category, project.CategoryID)
+	assert.True(t, project.CommentsBackersOnly)
+	assert.Equal(t, existing.ID, project.ID)
+	assert.Equal(t, existing.Version, project.Version)
+
+	input = models.UpdateProject{}
+	_, ok = bindRequest(t, &input, `{"title": "Solar kettle", "goal": {"amount": "500", "currency": "EUR"}, "start_date": "2026-05-01T00:00:00Z", "end_date": "2026-06-01T00:00:00Z", "category_id": 7, "comments_backers_only": false}`)
+	assert.True(t, ok)
+	project = applyProjectUpdate(existing, input)
+	assert.Equal(t, uint(7), *project.CategoryID)
+	assert.False(t, project.CommentsBackersOnly)
+}
+
+// TestBindJSONCreateDonation tests that donations must be positive and cannot pick their donor
+func TestBindJSONCreateDonation(t *testing.T) {
+	for _, body := range []string{`{"amount": {"amount": "0"}}`, `{"amount": {"amount": -10, "currency": "EUR"}}`, `{}`} {
+		var input models.CreateDonation
+		w, ok := bindRequest(t, &input, body)
+		assert.False(t, ok, body)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, body)
+		assert.Contains(t, w.Body.String(), `"amount":"must be greater than zero"`, body)
+		assert.Contains(t, w.Body.String(), `"payment_method":"is required"`, body)
+	}
+
+	var input models.CreateDonation
+	_, ok := bindRequest(t, &input, `{"amount": {"amount": 25.5, "currency": "EUR"}, "payment_method": "pm_card_visa", "user_id": 99}`)
+	assert.True(t, ok)
+	assert.Equal(t, models.Money{Amount: 2550, Currency: "EUR"}, input.Amount)
+	assert.Zero(t, input.UserID)
+}
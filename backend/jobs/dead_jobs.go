@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// bury records a job that ran out of attempts in dead_jobs, running the
+// queue's OnDead hook in the same transaction.
+func bury(tx *gorm.DB, handler RawHandler, dead models.DeadJob, cause error) error {
+	dead.LastError = cause.Error()
+	if err := tx.Create(&dead).Error; err != nil {
+		return err
+	}
+	if handler.OnDead != nil {
+		return handler.OnDead(tx, []byte(dead.Payload), cause)
+	}
+	return nil
+}
+
+// Dead returns a page of jobs that ran out of attempts, most recent first.
+// Replayed jobs are left out unless includeReplayed is set.
+func Dead(db *gorm.DB, queue string, includeReplayed bool, page, perPage int) ([]models.DeadJob, error) {
+	var jobs []models.DeadJob
+	query := db.Order("failed_at DESC").Offset((page - 1) * perPage).Limit(perPage)
+	if queue != "" {
+		query = query.Where("queue = ?", queue)
+	}
+	if !includeReplayed {
+		query = query.Where("replayed_at IS NULL")
+	}
+	err := query.Find(&jobs).Error
+	return jobs, err
+}
+
+// Replay puts a copy of a dead job back on its queue to run straight away,
+// running the queue's OnReplay hook first.
+func Replay(ctx context.Context, db *gorm.DB, q Queue, deadJobID uint64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var dead models.DeadJob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&dead, deadJobID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrJobNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if dead.ReplayedAt != nil {
+			return ErrJobAlreadyReplayed
+		}
+
+		if handler, ok := q.Handler(dead.Queue); ok && handler.OnReplay != nil {
+			if err := handler.OnReplay(tx, []byte(dead.Payload)); err != nil {
+				return err
+			}
+		}
+		if err := q.Enqueue(ctx, tx, dead.Queue, []byte(dead.Payload)); err != nil {
+			return err
+		}
+		return tx.Model(&dead).Update("replayed_at", time.Now()).Error
+	})
+}
@@ -0,0 +1,160 @@
+package jobs
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errLeaseLost is returned when a job ran past its lease and another
+// worker claimed it, so this worker's outcome is dropped.
+var errLeaseLost = errors.New("job lease ran out before it finished")
+
+// PostgresQueue keeps jobs in the jobs table. Workers claim them with
+// SELECT ... FOR UPDATE SKIP LOCKED and push their run_at out by a lease,
+// then run them without holding the row lock, so jobs calling slow
+// services do not keep transactions open. A job whose worker dies
+// mid-job is claimed again once its lease runs out.
+type PostgresQueue struct {
+	registry
+	db           *gorm.DB
+	pollInterval time.Duration
+	// lease is how long a worker has to finish a job it claimed; the job
+	// is cancelled when it runs out.
+	lease time.Duration
+}
+
+// NewPostgresQueue returns a queue whose idle workers look for new jobs
+// every pollInterval.
+func NewPostgresQueue(db *gorm.DB, pollInterval time.Duration) *PostgresQueue {
+	return &PostgresQueue{db: db, pollInterval: pollInterval, lease: 5 * time.Minute}
+}
+
+func (q *PostgresQueue) Enqueue(ctx context.Context, tx *gorm.DB, queue string, payload []byte) error {
+	if tx == nil {
+		tx = q.db
+	}
+	return tx.WithContext(ctx).Create(&models.Job{Queue: queue, Payload: string(payload), RunAt: time.Now()}).Error
+}
+
+func (q *PostgresQueue) Pending(ctx context.Context, queue string, page, perPage int) ([]Entry, error) {
+	var jobs []models.Job
+	err := q.db.WithContext(ctx).Where("queue = ?", queue).Order("created_at").
+		Offset((page - 1) * perPage).Limit(perPage).Find(&jobs).Error
+	entries := make([]Entry, len(jobs))
+	for i, job := range jobs {
+		entries[i] = Entry{
+			ID:        strconv.FormatUint(uint64(job.ID), 10),
+			Queue:     job.Queue,
+			Payload:   job.Payload,
+			Attempts:  job.Attempts,
+			RunAt:     job.RunAt,
+			LastError: job.LastError,
+		}
+	}
+	return entries, err
+}
+
+func (q *PostgresQueue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	q.each(func(queue string, handler RawHandler) {
+		for i := 1; i <= handler.Workers; i++ {
+			wg.Add(1)
+			go q.work(ctx, &wg, i, queue, handler)
+		}
+	})
+	wg.Wait()
+}
+
+func (q *PostgresQueue) work(ctx context.Context, wg *sync.WaitGroup, id int, queue string, handler RawHandler) {
+	defer wg.Done()
+	for {
+		ran, err := q.runNext(queue, handler)
+		if err != nil {
+			log.Printf("Worker %d could not run %s job: %v", id, queue, err)
+		}
+		if ran && err == nil && ctx.Err() == nil {
+			continue
+		}
+		if !sleep(ctx, q.pollInterval) {
+			return
+		}
+	}
+}
+
+// runNext claims the oldest due job on the queue and runs it. It reports
+// whether a job was run.
+func (q *PostgresQueue) runNext(queue string, handler RawHandler) (bool, error) {
+	var job models.Job
+	// Postgres keeps microseconds, so the lease is truncated to match when
+	// it is compared below.
+	leaseEnd := time.Now().Add(q.lease).Truncate(time.Microsecond)
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("queue = ? AND run_at <= ?", queue, time.Now()).
+			Order("run_at").Take(&job).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&job).Update("run_at", leaseEnd).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Jobs run to completion even during shutdown; Run only stops workers
+	// between jobs.
+	ctx, cancel := context.WithDeadline(context.Background(), leaseEnd)
+	jobErr := runJob(ctx, handler, []byte(job.Payload))
+	cancel()
+
+	err = q.db.Transaction(func(tx *gorm.DB) error {
+		// The run_at check makes sure the job is still ours.
+		claimed := tx.Where("id = ? AND run_at = ?", job.ID, leaseEnd)
+		if jobErr == nil {
+			return leaseHeld(claimed.Delete(&models.Job{}))
+		}
+
+		job.Attempts++
+		if job.Attempts < MaxAttempts {
+			return leaseHeld(claimed.Model(&models.Job{}).Updates(map[string]interface{}{
+				"attempts":   job.Attempts,
+				"last_error": jobErr.Error(),
+				"run_at":     time.Now().Add(Backoff(job.Attempts)),
+			}))
+		}
+		if err := leaseHeld(claimed.Delete(&models.Job{})); err != nil {
+			return err
+		}
+		dead := models.DeadJob{
+			JobID:      strconv.FormatUint(uint64(job.ID), 10),
+			Queue:      job.Queue,
+			Payload:    job.Payload,
+			Attempts:   job.Attempts,
+			EnqueuedAt: job.CreatedAt,
+		}
+		return bury(tx, handler, dead, jobErr)
+	})
+	if err != nil {
+		return true, err
+	}
+	return true, jobErr
+}
+
+// leaseHeld turns a write that matched no job into errLeaseLost.
+func leaseHeld(result *gorm.DB) error {
+	if result.Error == nil && result.RowsAffected == 0 {
+		return errLeaseLost
+	}
+	return result.Error
+}
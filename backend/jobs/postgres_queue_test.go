@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestPostgresQueue returns a queue over an in-memory SQLite database,
+// which ignores the row locks.
+func newTestPostgresQueue(t *testing.T) (*PostgresQueue, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Job{}, &models.DeadJob{}))
+	return NewPostgresQueue(db, time.Second), db
+}
+
+// TestPostgresQueue_GivesUp tests that a job failing on every attempt is buried and not run again
+func TestPostgresQueue_GivesUp(t *testing.T) {
+	q, db := newTestPostgresQueue(t)
+
+	runs := 0
+	var buried string
+	handler := RawHandler{
+		Workers: 1,
+		Run: func(ctx context.Context, payload []byte) error {
+			runs++
+			return errors.New("still failing")
+		},
+		OnDead: func(tx *gorm.DB, payload []byte, cause error) error {
+			buried = string(payload)
+			return nil
+		},
+	}
+	require.NoError(t, q.Enqueue(context.Background(), nil, "events", []byte("7")))
+
+	for i := 0; i < MaxAttempts; i++ {
+		// Skip the backoff.
+		require.NoError(t, db.Model(&models.Job{}).Where("1 = 1").Update("run_at", time.Now().Add(-time.Second)).Error)
+		ran, err := q.runNext("events", handler)
+		assert.True(t, ran)
+		assert.EqualError(t, err, "still failing")
+	}
+	ran, err := q.runNext("events", handler)
+	assert.False(t, ran)
+	assert.NoError(t, err)
+	assert.Equal(t, MaxAttempts, runs)
+	assert.Equal(t, "7", buried)
+
+	dead, err := Dead(db, "events", false, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, MaxAttempts, dead[0].Attempts)
+	assert.Equal(t, "still failing", dead[0].LastError)
+}
+
+// TestPostgresQueue_Lease tests that jobs run under a lease and a worker that overran it leaves the job to its new owner
+func TestPostgresQueue_Lease(t *testing.T) {
+	q, db := newTestPostgresQueue(t)
+	require.NoError(t, q.Enqueue(context.Background(), nil, "events", []byte("7")))
+
+	handler := RawHandler{Workers: 1, Run: func(ctx context.Context, payload []byte) error {
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(q.lease), deadline, time.Second)
+		// Meanwhile the lease runs out and another worker claims the job.
+		return db.Model(&models.Job{}).Where("1 = 1").Update("run_at", time.Now().Add(time.Hour)).Error
+	}}
+	ran, err := q.runNext("events", handler)
+	assert.True(t, ran)
+	assert.ErrorIs(t, err, errLeaseLost)
+
+	var left int64
+	require.NoError(t, db.Model(&models.Job{}).Count(&left).Error)
+	assert.Equal(t, int64(1), left)
+}
+
+// TestPostgresQueue_Panic tests that a panicking job counts as a failed attempt instead of killing the worker
+func TestPostgresQueue_Panic(t *testing.T) {
+	q, db := newTestPostgresQueue(t)
+	handler := RawHandler{Workers: 1, Run: func(ctx context.Context, payload []byte) error {
+		panic("nil map")
+	}}
+	require.NoError(t, q.Enqueue(context.Background(), nil, "events", []byte("7")))
+
+	ran, err := q.runNext("events", handler)
+	assert.True(t, ran)
+	assert.EqualError(t, err, "job panicked: nil map")
+	var job models.Job
+	require.NoError(t, db.First(&job).Error)
+	assert.Equal(t, 1, job.Attempts)
+	assert.Equal(t, "job panicked: nil map", job.LastError)
+}
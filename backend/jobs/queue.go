@@ -0,0 +1,205 @@
+// Package jobs runs background work through a queue. Work is enqueued under
+// a named queue and picked up by that queue's workers; failed jobs are
+// retried with backoff and, once out of attempts, moved to the dead_jobs
+// table where admins can inspect and replay them.
+//
+// Two backends implement Queue: PostgresQueue keeps jobs in the database,
+// so they can be enqueued in the same transaction as the change they belong
+// to, and RedisQueue uses Redis Streams consumer groups for throughput.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxAttempts is how many times a job runs before it is moved to
+	// dead_jobs.
+	MaxAttempts = 8
+	backoffBase = 30 * time.Second
+	backoffMax  = 1 * time.Hour
+)
+
+var (
+	ErrJobNotFound        = errors.New("job not found")
+	ErrJobAlreadyReplayed = errors.New("job has already been replayed")
+)
+
+// Queue is a job queue backend.
+type Queue interface {
+	// Enqueue adds a job. When tx is not nil and the backend stores jobs in
+	// Postgres, the job is only visible if tx commits; other backends
+	// enqueue straight away, so their handlers must cope with the job's
+	// subject not being committed yet.
+	Enqueue(ctx context.Context, tx *gorm.DB, queue string, payload []byte) error
+	// Handle registers the handler for a queue. It must be called before
+	// Run.
+	Handle(queue string, handler RawHandler)
+	// Handler returns the handler registered for a queue.
+	Handler(queue string) (RawHandler, bool)
+	// Pending returns a page of jobs waiting to run on a queue, including
+	// those waiting to be retried.
+	Pending(ctx context.Context, queue string, page, perPage int) ([]Entry, error)
+	// Run starts every registered queue's workers and blocks until ctx is
+	// cancelled and they have finished their current jobs.
+	Run(ctx context.Context)
+}
+
+// RawHandler processes the jobs on one queue.
+type RawHandler struct {
+	Workers int
+	// Run processes one job. A nil error completes it; anything else
+	// schedules a retry.
+	Run func(ctx context.Context, payload []byte) error
+	// OnDead, if set, runs in the transaction that moves a job to
+	// dead_jobs, to record on the job's subject that it was given up on.
+	OnDead func(tx *gorm.DB, payload []byte, cause error) error
+	// OnReplay, if set, runs in the transaction that replays a dead job,
+	// to undo OnDead.
+	OnReplay func(tx *gorm.DB, payload []byte) error
+}
+
+// runJob runs one job. A panic in the handler is turned into an error, so
+// the job counts a failed attempt and the worker lives on.
+func runJob(ctx context.Context, handler RawHandler, payload []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job panicked: %v\n%s", r, debug.Stack())
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler.Run(ctx, payload)
+}
+
+// Handler is a RawHandler for jobs whose payload is JSON of type T.
+type Handler[T any] struct {
+	Workers  int
+	Run      func(ctx context.Context, payload T) error
+	OnDead   func(tx *gorm.DB, payload T, cause error) error
+	OnReplay func(tx *gorm.DB, payload T) error
+}
+
+// Kind is a named queue whose jobs carry a payload of type T, so producers
+// and handlers agree on the payload at compile time.
+type Kind[T any] string
+
+// Enqueue adds a job with the given payload. See Queue.Enqueue for tx.
+func (k Kind[T]) Enqueue(ctx context.Context, q Queue, tx *gorm.DB, payload T) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return q.Enqueue(ctx, tx, string(k), data)
+}
+
+// Handle registers h for this kind's queue.
+func (k Kind[T]) Handle(q Queue, h Handler[T]) {
+	raw := RawHandler{
+		Workers: h.Workers,
+		Run: func(ctx context.Context, data []byte) error {
+			var payload T
+			if err := json.Unmarshal(data, &payload); err != nil {
+				return err
+			}
+			return h.Run(ctx, payload)
+		},
+	}
+	if h.OnDead != nil {
+		raw.OnDead = func(tx *gorm.DB, data []byte, cause error) error {
+			var payload T
+			if err := json.Unmarshal(data, &payload); err != nil {
+				return err
+			}
+			return h.OnDead(tx, payload, cause)
+		}
+	}
+	if h.OnReplay != nil {
+		raw.OnReplay = func(tx *gorm.DB, data []byte) error {
+			var payload T
+			if err := json.Unmarshal(data, &payload); err != nil {
+				return err
+			}
+			return h.OnReplay(tx, payload)
+		}
+	}
+	q.Handle(string(k), raw)
+}
+
+// Entry describes a job waiting to run. IDs are backend specific.
+type Entry struct {
+	ID        string    `json:"id"`
+	Queue     string    `json:"queue"`
+	Payload   string    `json:"payload"`
+	Attempts  int       `json:"attempts"`
+	RunAt     time.Time `json:"run_at"` // Not picked up before this time
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Backoff is the wait before a job's next attempt: 30s doubling each time,
+// capped at an hour.
+func Backoff(attempts int) time.Duration {
+	delay := backoffBase
+	for i := 1; i < attempts && delay < backoffMax; i++ {
+		delay *= 2
+	}
+	if delay > backoffMax {
+		delay = backoffMax
+	}
+	return delay
+}
+
+// registry holds the handlers registered with a backend.
+type registry struct {
+	mu       sync.RWMutex
+	handlers map[string]RawHandler
+}
+
+func (r *registry) Handle(queue string, handler RawHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.handlers == nil {
+		r.handlers = map[string]RawHandler{}
+	}
+	if handler.Workers < 1 {
+		handler.Workers = 1
+	}
+	r.handlers[queue] = handler
+}
+
+func (r *registry) Handler(queue string) (RawHandler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	handler, ok := r.handlers[queue]
+	return handler, ok
+}
+
+// each calls fn for every registered queue.
+func (r *registry) each(fn func(queue string, handler RawHandler)) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for queue, handler := range r.handlers {
+		fn(queue, handler)
+	}
+}
+
+// sleep waits for d or until ctx is cancelled, reporting whether to carry
+// on.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// memoryQueue runs jobs as soon as they are enqueued.
+type memoryQueue struct {
+	registry
+}
+
+func (q *memoryQueue) Enqueue(ctx context.Context, tx *gorm.DB, queue string, payload []byte) error {
+	handler, ok := q.Handler(queue)
+	if !ok {
+		return errors.New("no handler")
+	}
+	return handler.Run(ctx, payload)
+}
+
+func (q *memoryQueue) Pending(ctx context.Context, queue string, page, perPage int) ([]Entry, error) {
+	return nil, nil
+}
+
+func (q *memoryQueue) Run(ctx context.Context) {}
+
+// TestKind tests that typed payloads survive the trip through a queue
+func TestKind(t *testing.T) {
+	type greeting struct {
+		To    string `json:"to"`
+		Times int    `json:"times"`
+	}
+	kind := Kind[greeting]("greetings")
+	q := &memoryQueue{}
+
+	var got greeting
+	kind.Handle(q, Handler[greeting]{Run: func(ctx context.Context, payload greeting) error {
+		got = payload
+		return nil
+	}})
+	handler, ok := q.Handler("greetings")
+	assert.True(t, ok)
+	assert.Equal(t, 1, handler.Workers)
+	assert.Nil(t, handler.OnDead)
+
+	assert.NoError(t, kind.Enqueue(context.Background(), q, nil, greeting{To: "backers", Times: 2}))
+	assert.Equal(t, greeting{To: "backers", Times: 2}, got)
+	assert.Error(t, handler.Run(context.Background(), []byte("not json")))
+}
+
+// TestBackoff tests that retries back off exponentially up to the cap
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, 1*time.Minute, Backoff(2))
+	assert.Equal(t, 4*time.Minute, Backoff(4))
+	assert.Equal(t, 1*time.Hour, Backoff(MaxAttempts+10))
+}
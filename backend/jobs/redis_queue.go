@@ -0,0 +1,323 @@
+package jobs
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// RedisQueue keeps jobs in a Redis stream per queue, read through a consumer
+// group so each job goes to one worker across all instances. Jobs waiting
+// for a retry sit in a sorted set scored by when they are due. Dead jobs
+// are still recorded in Postgres so they can be inspected alongside the
+// rest of the data.
+type RedisQueue struct {
+	registry
+	client   *redis.Client
+	db       *gorm.DB
+	group    string
+	consumer string
+	// block is how long a worker waits for new jobs in one read.
+	block time.Duration
+	// claimAfter is how long a job may go unacknowledged before another
+	// worker takes it over, e.g. because its worker crashed.
+	claimAfter time.Duration
+	// runTimeout is how long a job may run. It is shorter than claimAfter
+	// so a slow job is cancelled, and its outcome recorded, before another
+	// worker claims it and runs it a second time.
+	runTimeout time.Duration
+}
+
+// redisJob is a job as stored in a retry set.
+type redisJob struct {
+	ID         string `json:"id"`
+	Payload    string `json:"payload"`
+	Attempts   int    `json:"attempts"`
+	EnqueuedAt int64  `json:"enqueued_at"`
+	LastError  string `json:"last_error"`
+}
+
+// NewRedisQueue returns a queue reading through the named consumer group.
+// consumer must be unique per process, e.g. the hostname.
+func NewRedisQueue(client *redis.Client, db *gorm.DB, group, consumer string) *RedisQueue {
+	return &RedisQueue{
+		client:     client,
+		db:         db,
+		group:      group,
+		consumer:   consumer,
+		block:      5 * time.Second,
+		claimAfter: 5 * time.Minute,
+		runTimeout: 4 * time.Minute,
+	}
+}
+
+func streamKey(queue string) string { return "jobs:" + queue }
+func retryKey(queue string) string  { return "jobs:" + queue + ":retry" }
+
+// Enqueue adds the job to the queue's stream straight away; tx is ignored.
+func (q *RedisQueue) Enqueue(ctx context.Context, tx *gorm.DB, queue string, payload []byte) error {
+	return q.add(ctx, queue, redisJob{Payload: string(payload), EnqueuedAt: time.Now().Unix()})
+}
+
+func (q *RedisQueue) add(ctx context.Context, queue string, job redisJob) error {
+	return q.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamKey(queue),
+		Values: map[string]interface{}{
+			"payload":     job.Payload,
+			"attempts":    job.Attempts,
+			"enqueued_at": job.EnqueuedAt,
+			"last_error":  job.LastError,
+		},
+	}).Err()
+}
+
+func (q *RedisQueue) Pending(ctx context.Context, queue string, page, perPage int) ([]Entry, error) {
+	limit := int64(page * perPage)
+	messages, err := q.client.XRangeN(ctx, streamKey(queue), "-", "+", limit).Result()
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, message := range messages {
+		job := parseMessage(message)
+		entries = append(entries, Entry{
+			ID:        job.ID,
+			Queue:     queue,
+			Payload:   job.Payload,
+			Attempts:  job.Attempts,
+			RunAt:     time.Unix(job.EnqueuedAt, 0),
+			LastError: job.LastError,
+		})
+	}
+	if remaining := limit - int64(len(entries)); remaining > 0 {
+		retries, err := q.client.ZRangeWithScores(ctx, retryKey(queue), 0, remaining-1).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, retry := range retries {
+			var job redisJob
+			if err := json.Unmarshal([]byte(retry.Member.(string)), &job); err != nil {
+				continue
+			}
+			entries = append(entries, Entry{
+				ID:        job.ID,
+				Queue:     queue,
+				Payload:   job.Payload,
+				Attempts:  job.Attempts,
+				RunAt:     time.UnixMilli(int64(retry.Score)),
+				LastError: job.LastError,
+			})
+		}
+	}
+	if start := (page - 1) * perPage; start < len(entries) {
+		return entries[start:], nil
+	}
+	return []Entry{}, nil
+}
+
+func (q *RedisQueue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	q.each(func(queue string, handler RawHandler) {
+		err := q.client.XGroupCreateMkStream(ctx, streamKey(queue), q.group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			log.Printf("Error creating consumer group for %s jobs: %v", queue, err)
+		}
+		for i := 1; i <= handler.Workers; i++ {
+			wg.Add(1)
+			go q.work(ctx, &wg, i, queue, handler)
+		}
+	})
+	wg.Wait()
+}
+
+func (q *RedisQueue) work(ctx context.Context, wg *sync.WaitGroup, id int, queue string, handler RawHandler) {
+	defer wg.Done()
+	consumer := fmt.Sprintf("%s-%d", q.consumer, id)
+	for ctx.Err() == nil {
+		if err := q.promoteRetries(ctx, queue); err != nil && ctx.Err() == nil {
+			log.Printf("Worker %d could not promote %s retries: %v", id, queue, err)
+		}
+		message, err := q.next(ctx, queue, consumer)
+		if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+			continue
+		}
+		if err != nil {
+			log.Printf("Worker %d could not read %s jobs: %v", id, queue, err)
+			sleep(ctx, q.block)
+			continue
+		}
+		if err := q.process(queue, handler, parseMessage(message)); err != nil {
+			log.Printf("Worker %d could not run %s job: %v", id, queue, err)
+		}
+	}
+}
+
+// next returns a job abandoned by another consumer if there is one, and
+// otherwise waits for a new job.
+func (q *RedisQueue) next(ctx context.Context, queue, consumer string) (redis.XMessage, error) {
+	claimed, err := q.autoClaim(ctx, queue, consumer)
+	if err != nil {
+		return redis.XMessage{}, err
+	}
+	if len(claimed) > 0 {
+		return claimed[0], nil
+	}
+
+	streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    q.group,
+		Consumer: consumer,
+		Streams:  []string{streamKey(queue), ">"},
+		Count:    1,
+		Block:    q.block,
+	}).Result()
+	if err != nil {
+		return redis.XMessage{}, err
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return redis.XMessage{}, redis.Nil
+	}
+	return streams[0].Messages[0], nil
+}
+
+// autoClaim takes over at most one job left unacknowledged for claimAfter.
+// It sends XAUTOCLAIM itself because the client library only reads the
+// Redis 6.2 reply; Redis 7 adds a third element listing deleted entries.
+func (q *RedisQueue) autoClaim(ctx context.Context, queue, consumer string) ([]redis.XMessage, error) {
+	reply, err := q.client.Do(ctx, "XAUTOCLAIM", streamKey(queue), q.group, consumer,
+		q.claimAfter.Milliseconds(), "0", "COUNT", 1).Slice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) < 2 {
+		return nil, fmt.Errorf("unexpected XAUTOCLAIM reply with %d elements", len(reply))
+	}
+	entries, _ := reply[1].([]interface{})
+	var messages []redis.XMessage
+	for _, entry := range entries {
+		// Redis 6.2 lists entries deleted while pending as nil.
+		fields, ok := entry.([]interface{})
+		if !ok || len(fields) != 2 {
+			continue
+		}
+		id, _ := fields[0].(string)
+		values, _ := fields[1].([]interface{})
+		message := redis.XMessage{ID: id, Values: make(map[string]interface{}, len(values)/2)}
+		for i := 0; i+1 < len(values); i += 2 {
+			if key, ok := values[i].(string); ok {
+				message.Values[key] = values[i+1]
+			}
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// process runs a job and then acknowledges it, after scheduling a retry or
+// burying it if it failed.
+func (q *RedisQueue) process(queue string, handler RawHandler, job redisJob) error {
+	// Jobs run to completion even during shutdown, but not past
+	// runTimeout.
+	ctx := context.Background()
+	runCtx, cancel := context.WithTimeout(ctx, q.runTimeout)
+	jobErr := runJob(runCtx, handler, []byte(job.Payload))
+	cancel()
+	if jobErr != nil {
+		job.Attempts++
+		job.LastError = jobErr.Error()
+		if job.Attempts < MaxAttempts {
+			if err := q.scheduleRetry(ctx, queue, job); err != nil {
+				return err
+			}
+		} else {
+			dead := models.DeadJob{
+				JobID:      job.ID,
+				Queue:      queue,
+				Payload:    job.Payload,
+				Attempts:   job.Attempts,
+				EnqueuedAt: time.Unix(job.EnqueuedAt, 0),
+			}
+			err := q.db.Transaction(func(tx *gorm.DB) error {
+				return bury(tx, handler, dead, jobErr)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, streamKey(queue), q.group, job.ID)
+		pipe.XDel(ctx, streamKey(queue), job.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return jobErr
+}
+
+func (q *RedisQueue) scheduleRetry(ctx context.Context, queue string, job redisJob) error {
+	member, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	runAt := time.Now().Add(Backoff(job.Attempts))
+	return q.client.ZAdd(ctx, retryKey(queue), &redis.Z{Score: float64(runAt.UnixMilli()), Member: member}).Err()
+}
+
+// promoteRetries moves retries that are due back onto the stream. ZRem
+// decides which worker moves each one, so none is added twice.
+func (q *RedisQueue) promoteRetries(ctx context.Context, queue string) error {
+	due, err := q.client.ZRangeByScore(ctx, retryKey(queue), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Count: 100,
+	}).Result()
+	if err != nil {
+		return err
+	}
+	for _, member := range due {
+		removed, err := q.client.ZRem(ctx, retryKey(queue), member).Result()
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			continue
+		}
+		var job redisJob
+		if err := json.Unmarshal([]byte(member), &job); err != nil {
+			log.Printf("Dropping unreadable %s retry: %v", queue, err)
+			continue
+		}
+		if err := q.add(ctx, queue, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseMessage(message redis.XMessage) redisJob {
+	job := redisJob{ID: message.ID}
+	job.Payload, _ = message.Values["payload"].(string)
+	job.LastError, _ = message.Values["last_error"].(string)
+	if attempts, ok := message.Values["attempts"].(string); ok {
+		job.Attempts, _ = strconv.Atoi(attempts)
+	}
+	if enqueuedAt, ok := message.Values["enqueued_at"].(string); ok {
+		job.EnqueuedAt, _ = strconv.ParseInt(enqueuedAt, 10, 64)
+	}
+	return job
+}
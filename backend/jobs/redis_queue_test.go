@@ -0,0 +1,205 @@
+package jobs
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestRedisQueue returns a queue over an in-process Redis, with dead
+// jobs kept in an in-memory SQLite database. Reads block only briefly.
+func newTestRedisQueue(t *testing.T, queue string) (*RedisQueue, *redis.Client, *gorm.DB) {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { client.Close() })
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.DeadJob{}))
+
+	q := NewRedisQueue(client, db, "workers", "test")
+	q.block = 10 * time.Millisecond
+	require.NoError(t, client.XGroupCreateMkStream(context.Background(), streamKey(queue), q.group, "0").Err())
+	return q, client, db
+}
+
+// runOne reads the next job as consumer and processes it.
+func runOne(t *testing.T, q *RedisQueue, queue, consumer string, handler RawHandler) error {
+	t.Helper()
+	message, err := q.next(context.Background(), queue, consumer)
+	require.NoError(t, err)
+	return q.process(queue, handler, parseMessage(message))
+}
+
+// promoteDue makes every waiting retry due and moves it back onto the
+// stream.
+func promoteDue(t *testing.T, q *RedisQueue, client *redis.Client, queue string) {
+	t.Helper()
+	ctx := context.Background()
+	members, err := client.ZRange(ctx, retryKey(queue), 0, -1).Result()
+	require.NoError(t, err)
+	for _, member := range members {
+		require.NoError(t, client.ZAdd(ctx, retryKey(queue), &redis.Z{Score: 0, Member: member}).Err())
+	}
+	require.NoError(t, q.promoteRetries(ctx, queue))
+}
+
+// TestParseMessage tests reading a job back from a stream entry, where Redis returns every field as a string
+func TestParseMessage(t *testing.T) {
+	job := parseMessage(redis.XMessage{
+		ID: "1760000000000-0",
+		Values: map[string]interface{}{
+			"payload":     `{"donation_id":7}`,
+			"attempts":    "2",
+			"enqueued_at": "1760000000",
+			"last_error":  "payment provider unavailable",
+		},
+	})
+	assert.Equal(t, redisJob{
+		ID:         "1760000000000-0",
+		Payload:    `{"donation_id":7}`,
+		Attempts:   2,
+		EnqueuedAt: 1760000000,
+		LastError:  "payment provider unavailable",
+	}, job)
+}
+
+// TestRedisQueue_Retry tests that a failed job waits out its backoff and then runs again with its attempt recorded
+func TestRedisQueue_Retry(t *testing.T) {
+	ctx := context.Background()
+	q, client, _ := newTestRedisQueue(t, "events")
+	var attempts []int
+	handler := RawHandler{
+		Workers: 1,
+		Run: func(ctx context.Context, payload []byte) error {
+			attempts = append(attempts, len(attempts)+1)
+			if len(attempts) == 1 {
+				return errors.New("provider unavailable")
+			}
+			return nil
+		},
+	}
+	require.NoError(t, q.Enqueue(ctx, nil, "events", []byte("7")))
+
+	assert.EqualError(t, runOne(t, q, "events", "c1", handler), "provider unavailable")
+	assert.Zero(t, client.XLen(ctx, streamKey("events")).Val())
+	assert.Equal(t, int64(1), client.ZCard(ctx, retryKey("events")).Val())
+
+	// Not due yet.
+	require.NoError(t, q.promoteRetries(ctx, "events"))
+	assert.Zero(t, client.XLen(ctx, streamKey("events")).Val())
+	pending, err := q.Pending(ctx, "events", 1, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, "provider unavailable", pending[0].LastError)
+	assert.True(t, pending[0].RunAt.After(time.Now()))
+
+	promoteDue(t, q, client, "events")
+	assert.Zero(t, client.ZCard(ctx, retryKey("events")).Val())
+	assert.NoError(t, runOne(t, q, "events", "c1", handler))
+	assert.Equal(t, []int{1, 2}, attempts)
+	assert.Zero(t, client.XLen(ctx, streamKey("events")).Val())
+	assert.Zero(t, client.ZCard(ctx, retryKey("events")).Val())
+}
+
+// TestRedisQueue_GivesUp tests that a job failing on every attempt is buried and not run again
+func TestRedisQueue_GivesUp(t *testing.T) {
+	ctx := context.Background()
+	q, client, db := newTestRedisQueue(t, "events")
+	runs := 0
+	var buried string
+	handler := RawHandler{
+		Workers: 1,
+		Run: func(ctx context.Context, payload []byte) error {
+			runs++
+			return errors.New("still failing")
+		},
+		OnDead: func(tx *gorm.DB, payload []byte, cause error) error {
+			buried = string(payload)
+			return nil
+		},
+	}
+	require.NoError(t, q.Enqueue(ctx, nil, "events", []byte("7")))
+
+	for i := 0; i < MaxAttempts; i++ {
+		promoteDue(t, q, client, "events")
+		assert.EqualError(t, runOne(t, q, "events", "c1", handler), "still failing")
+	}
+	assert.Equal(t, MaxAttempts, runs)
+	assert.Equal(t, "7", buried)
+	assert.Zero(t, client.XLen(ctx, streamKey("events")).Val())
+	assert.Zero(t, client.ZCard(ctx, retryKey("events")).Val())
+
+	dead, err := Dead(db, "events", false, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, MaxAttempts, dead[0].Attempts)
+	assert.Equal(t, "still failing", dead[0].LastError)
+}
+
+// TestRedisQueue_Claim tests that a job left unacknowledged by a crashed worker is taken over once it has been idle long enough
+func TestRedisQueue_Claim(t *testing.T) {
+	ctx := context.Background()
+	q, client, _ := newTestRedisQueue(t, "events")
+	q.claimAfter = 50 * time.Millisecond
+	require.NoError(t, q.Enqueue(ctx, nil, "events", []byte("7")))
+
+	// c1 reads the job and dies before acknowledging it.
+	abandoned, err := q.next(ctx, "events", "c1")
+	require.NoError(t, err)
+	_, err = q.next(ctx, "events", "c2")
+	assert.ErrorIs(t, err, redis.Nil)
+
+	time.Sleep(2 * q.claimAfter)
+	ran := false
+	handler := RawHandler{Workers: 1, Run: func(ctx context.Context, payload []byte) error {
+		ran = true
+		assert.Equal(t, "7", string(payload))
+		return nil
+	}}
+	message, err := q.next(ctx, "events", "c2")
+	require.NoError(t, err)
+	assert.Equal(t, abandoned.ID, message.ID)
+	require.NoError(t, q.process("events", handler, parseMessage(message)))
+	assert.True(t, ran)
+	assert.Zero(t, client.XLen(ctx, streamKey("events")).Val())
+	pending, err := client.XPending(ctx, streamKey("events"), q.group).Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count)
+}
+
+// TestRedisQueue_TimeoutAndPanic tests that slow and panicking jobs count as failed attempts instead of running on or killing the worker
+func TestRedisQueue_TimeoutAndPanic(t *testing.T) {
+	ctx := context.Background()
+	q, client, _ := newTestRedisQueue(t, "events")
+	q.runTimeout = 20 * time.Millisecond
+	require.NoError(t, q.Enqueue(ctx, nil, "events", []byte("slow")))
+	require.NoError(t, q.Enqueue(ctx, nil, "events", []byte("panic")))
+	handler := RawHandler{Workers: 1, Run: func(ctx context.Context, payload []byte) error {
+		if string(payload) == "panic" {
+			panic("nil map")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	assert.ErrorIs(t, runOne(t, q, "events", "c1", handler), context.DeadlineExceeded)
+	assert.EqualError(t, runOne(t, q, "events", "c1", handler), "job panicked: nil map")
+	pending, err := q.Pending(ctx, "events", 1, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	for _, entry := range pending {
+		assert.Equal(t, 1, entry.Attempts, entry.Payload)
+	}
+	assert.Zero(t, client.XLen(ctx, streamKey("events")).Val())
+}
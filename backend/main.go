@@ -6,15 +6,18 @@ package main
 import (
 	"context"
 	"crowdfund/backend/handlers"
+	"crowdfund/backend/jobs"
 	"crowdfund/backend/middlewares"
 	"crowdfund/backend/models"
 	"crowdfund/backend/services"
+	"crowdfund/backend/utils"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
@@ -38,6 +41,15 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return value
 }
 
+// workerCount reads a worker pool size from the environment.
+func workerCount(key string, defaultValue int) int {
+	count, err := strconv.Atoi(getEnvOrDefault(key, strconv.Itoa(defaultValue)))
+	if err != nil || count < 1 {
+		log.Fatalf("Invalid %s: must be a positive number", key)
+	}
+	return count
+}
+
 func main() {
 	err := godotenv.Load()
 	if err != nil {
@@ -79,38 +91,229 @@ func main() {
 
 	userService := services.NewUserService(db)
 	projectService := services.NewProjectService(db)
-	emailService := services.NewEmailService()
+	categoryService := services.NewCategoryService(db)
 	cacheService := services.NewCacheService()
 
-	// Worker Pool Setup
-	donationTasks := make(chan models.Donation, 100) // Buffered channel
-	var donationWg sync.WaitGroup
-	numDonationWorkers := 5
-	for i := 1; i <= numDonationWorkers; i++ {
-		donationWg.Add(1)
-		go services.DonationWorker(i, donationTasks, &donationWg, db, emailService)
+	// Background jobs go through Postgres by default; Redis Streams trade
+	// transactional enqueueing for throughput.
+	var jobQueue jobs.Queue
+	switch getEnvOrDefault("JOB_BACKEND", "postgres") {
+	case "redis":
+		hostname, _ := os.Hostname()
+		jobQueue = jobs.NewRedisQueue(cacheService.Client(), db, "crowdfund", fmt.Sprintf("%s-%d", hostname, os.Getpid()))
+	default:
+		jobQueue = jobs.NewPostgresQueue(db, 1*time.Second)
+	}
+	emailService := services.NewEmailService(jobQueue)
+
+	var blobStore services.BlobStore
+	var localBlobStore *services.LocalBlobStore
+	switch getEnvOrDefault("STORAGE_DRIVER", "local") {
+	case "s3":
+		blobStore = services.NewS3BlobStore(services.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	default:
+		localBlobStore = services.NewLocalBlobStore(
+			getEnvOrDefault("MEDIA_ROOT", "./uploads"),
+			getEnvOrDefault("MEDIA_BASE_URL", "http://localhost:8080"),
+			utils.GetSecretKey(),
+		)
+		blobStore = localBlobStore
+	}
+	mediaService := services.NewMediaService(db, blobStore)
+
+	var rateProvider services.RateProvider
+	switch getEnvOrDefault("RATE_PROVIDER", "static") {
+	case "http":
+		rateProvider = services.NewHTTPRateProvider(getEnvOrDefault("RATES_URL", "https://api.frankfurter.app/latest"), 1*time.Hour)
+	default:
+		if path := os.Getenv("RATES_FILE"); path != "" {
+			rateProvider, err = services.NewStaticRateProviderFromFile(path)
+			if err != nil {
+				log.Fatalf("Failed to load exchange rates: %v", err)
+			}
+		} else {
+			// Without a rates file only same-currency donations are accepted.
+			rateProvider, _ = services.NewStaticRateProvider(models.DefaultCurrency, nil)
+		}
+	}
+
+	var paymentProvider services.PaymentProvider
+	var webhookSecret string
+	switch getEnvOrDefault("PAYMENT_PROVIDER", "fake") {
+	case "stripe":
+		webhookSecret = os.Getenv("STRIPE_WEBHOOK_SECRET")
+		if webhookSecret == "" {
+			log.Fatal("STRIPE_WEBHOOK_SECRET must be set")
+		}
+		paymentProvider = services.NewStripePaymentProvider(getEnvOrDefault("STRIPE_API_URL", "https://api.stripe.com"), os.Getenv("STRIPE_SECRET_KEY"), webhookSecret)
+	default:
+		webhookSecret = os.Getenv("WEBHOOK_SECRET")
+		paymentProvider = services.NewFakePaymentProvider(webhookSecret)
+	}
+	webhookVerifiers := map[string]services.PaymentWebhookVerifier{}
+	if verifier, ok := paymentProvider.(services.PaymentWebhookVerifier); ok {
+		// Without a secret anyone could forge events, so the provider's
+		// webhooks are turned away as unknown.
+		if webhookSecret != "" {
+			webhookVerifiers[paymentProvider.Name()] = verifier
+		} else {
+			log.Printf("WEBHOOK_SECRET is not set; %s webhooks are disabled", paymentProvider.Name())
+		}
 	}
-	donationService := services.NewDonationService(db, emailService, donationTasks)
+
+	paymentEventService := services.NewPaymentEventService(db, webhookVerifiers, jobQueue)
+	donationService := services.NewDonationService(db, emailService, rateProvider, paymentProvider, jobQueue)
+	projectUpdateService := services.NewProjectUpdateService(db, projectService, donationService, emailService)
+	commentService := services.NewCommentService(db, donationService)
+	revisionService := services.NewProjectRevisionService(db, donationService, emailService)
+	autoHideThreshold, err := strconv.ParseInt(getEnvOrDefault("MODERATION_AUTO_HIDE_REPORTS", "5"), 10, 64)
+	if err != nil {
+		log.Fatalf("Invalid MODERATION_AUTO_HIDE_REPORTS: %v", err)
+	}
+	moderationService := services.NewModerationService(db, autoHideThreshold)
+	previewService := services.NewProjectPreviewService(db, utils.GetSecretKey())
+	followService := services.NewFollowService(db, emailService)
+	preferenceService := services.NewNotificationPreferenceService(db)
+	featuredSlots, err := strconv.Atoi(getEnvOrDefault("FEATURED_SLOTS", "6"))
+	if err != nil {
+		log.Fatalf("Invalid FEATURED_SLOTS: %v", err)
+	}
+	rankingRefreshInterval := 5 * time.Minute
+	rankingService := services.NewRankingService(db, cacheService, featuredSlots, rankingRefreshInterval)
+	idempotencyService := services.NewIdempotencyService(db, services.IdempotencyKeyTTL)
+	jobService := services.NewJobService(db, jobQueue)
+	refundWindowDays, err := strconv.Atoi(getEnvOrDefault("REFUND_WINDOW_DAYS", strconv.Itoa(int(services.DefaultRefundWindow/(24*time.Hour)))))
+	if err != nil || refundWindowDays < 0 {
+		log.Fatal("Invalid REFUND_WINDOW_DAYS: must be a number of days")
+	}
+	refundService := services.NewRefundService(db, emailService, paymentProvider, jobQueue, time.Duration(refundWindowDays)*24*time.Hour)
+	fundingService := services.NewFundingService(db, emailService, refundService, paymentProvider, jobQueue)
+	subscriptionService := services.NewSubscriptionService(db, donationService, emailService)
+
+	// Worker Pool Setup
+	services.ChargeDonationJob.Handle(jobQueue, donationService.ChargeHandler(workerCount("DONATION_WORKERS", 5)))
+	services.SendEmailJob.Handle(jobQueue, emailService.SendHandler(workerCount("EMAIL_WORKERS", 2)))
+	services.ApplyPaymentEventJob.Handle(jobQueue, paymentEventService.ApplyHandler(workerCount("PAYMENT_EVENT_WORKERS", 2)))
+	services.RefundDonationJob.Handle(jobQueue, refundService.RefundHandler(workerCount("REFUND_WORKERS", 2)))
+	services.SettleDonationJob.Handle(jobQueue, fundingService.SettleHandler(workerCount("SETTLEMENT_WORKERS", 2)))
+
+	// Background schedulers stop when schedulerCtx is cancelled on shutdown.
+	schedulerCtx, stopSchedulers := context.WithCancel(context.Background())
+	// Job workers also stop on schedulerCtx, after finishing their current job.
+	var jobWg sync.WaitGroup
+	jobWg.Add(1)
+	go func() {
+		defer jobWg.Done()
+		jobQueue.Run(schedulerCtx)
+	}()
+	go projectUpdateService.RunScheduler(schedulerCtx, 1*time.Minute)
+	go followService.RunScheduler(schedulerCtx, 10*time.Minute)
+	go rankingService.RunScheduler(schedulerCtx, rankingRefreshInterval)
+	go idempotencyService.RunScheduler(schedulerCtx, 1*time.Hour)
+	go fundingService.RunScheduler(schedulerCtx, 1*time.Minute)
+	go subscriptionService.RunScheduler(schedulerCtx, 1*time.Minute)
 
 	r := gin.Default()
+	r.Use(middlewares.DBMiddleware(db))
 
 	userHandlers := handlers.NewUserHandlers(userService, cacheService)
-	projectHandlers := handlers.NewProjectHandlers(projectService, cacheService)
-	donationHandlers := handlers.NewDonationHandlers(donationService)
+	projectHandlers := handlers.NewProjectHandlers(projectService, categoryService, mediaService, revisionService, previewService, cacheService)
+	mediaHandlers := handlers.NewMediaHandlers(mediaService, projectService, cacheService)
+	categoryHandlers := handlers.NewCategoryHandlers(categoryService)
+	donationHandlers := handlers.NewDonationHandlers(donationService, projectService)
+	projectUpdateHandlers := handlers.NewProjectUpdateHandlers(projectUpdateService, projectService, donationService)
+	commentHandlers := handlers.NewCommentHandlers(commentService, projectService, projectUpdateService, donationService)
+	moderationHandlers := handlers.NewModerationHandlers(moderationService, cacheService)
+	revisionHandlers := handlers.NewProjectRevisionHandlers(revisionService, projectService)
+	previewHandlers := handlers.NewProjectPreviewHandlers(previewService, projectService)
+	followHandlers := handlers.NewFollowHandlers(followService, projectService)
+	preferenceHandlers := handlers.NewNotificationPreferenceHandlers(preferenceService)
+	rankingHandlers := handlers.NewRankingHandlers(rankingService)
+	paymentWebhookHandlers := handlers.NewPaymentWebhookHandlers(paymentEventService)
+	refundHandlers := handlers.NewRefundHandlers(refundService, donationService, projectService, cacheService)
+	subscriptionHandlers := handlers.NewSubscriptionHandlers(subscriptionService)
+	jobHandlers := handlers.NewJobHandlers(jobService)
 	passHandlers := handlers.PassHandlers{}
 
 	r.POST("/users/register", userHandlers.Register)
 	r.POST("/users/login", userHandlers.Login)
 	r.GET("/api/users/profile", middlewares.AuthMiddleware(), userHandlers.Profile)
+	r.GET("/api/users/following", middlewares.AuthMiddleware(), followHandlers.ListFollowing)
+	r.GET("/api/users/notification-preferences", middlewares.AuthMiddleware(), preferenceHandlers.GetPreferences)
+	r.PUT("/api/users/notification-preferences", middlewares.AuthMiddleware(), preferenceHandlers.UpdatePreferences)
+	r.GET("/api/users/subscriptions", middlewares.AuthMiddleware(), subscriptionHandlers.ListSubscriptions)
+	r.POST("/api/users/subscriptions/:id/pause", middlewares.AuthMiddleware(), subscriptionHandlers.PauseSubscription)
+	r.POST("/api/users/subscriptions/:id/resume", middlewares.AuthMiddleware(), subscriptionHandlers.ResumeSubscription)
+	r.POST("/api/users/subscriptions/:id/cancel", middlewares.AuthMiddleware(), subscriptionHandlers.CancelSubscription)
 
-	r.POST("/api/projects", middlewares.AuthMiddleware(), projectHandlers.CreateProject)
-	r.GET("/api/projects/:id", projectHandlers.GetProject)
+	r.POST("/api/projects", middlewares.AuthMiddleware(), middlewares.IdempotencyMiddleware(idempotencyService), projectHandlers.CreateProject)
+	r.GET("/api/projects/:id", middlewares.OptionalAuthMiddleware(), projectHandlers.GetProject)
 	r.PUT("/api/projects/:id", middlewares.AuthMiddleware(), projectHandlers.UpdateProject)
+	r.PATCH("/api/projects/:id", middlewares.AuthMiddleware(), projectHandlers.PatchProject)
 	r.DELETE("/api/projects/:id", middlewares.AuthMiddleware(), projectHandlers.DeleteProject)
+	r.POST("/api/projects/:id/cancel", middlewares.AuthMiddleware(), refundHandlers.CancelProject)
 	r.GET("/api/projects", projectHandlers.ListProjects)
+	r.GET("/api/projects/trending", rankingHandlers.Trending)
+	r.GET("/api/projects/featured", rankingHandlers.Featured)
+	r.PUT("/api/projects/:id/tags", middlewares.AuthMiddleware(), projectHandlers.SetProjectTags)
+	r.GET("/api/projects/:id/revisions", middlewares.OptionalAuthMiddleware(), revisionHandlers.ListRevisions)
+	r.GET("/api/projects/:id/revisions/diff", middlewares.OptionalAuthMiddleware(), revisionHandlers.DiffRevisions)
+	r.POST("/api/projects/:id/follow", middlewares.AuthMiddleware(), followHandlers.FollowProject)
+	r.DELETE("/api/projects/:id/follow", middlewares.AuthMiddleware(), followHandlers.UnfollowProject)
+	r.POST("/api/projects/:id/previews", middlewares.AuthMiddleware(), previewHandlers.CreatePreview)
+	r.GET("/api/projects/:id/previews", middlewares.AuthMiddleware(), previewHandlers.ListPreviews)
+	r.DELETE("/api/projects/:id/previews/:previewID", middlewares.AuthMiddleware(), previewHandlers.RevokePreview)
+	r.POST("/api/projects/:id/media", middlewares.AuthMiddleware(), mediaHandlers.UploadMedia)
+	r.DELETE("/api/projects/:id/media/:mediaID", middlewares.AuthMiddleware(), mediaHandlers.DeleteMedia)
+	r.POST("/api/projects/:id/updates", middlewares.AuthMiddleware(), projectUpdateHandlers.CreateUpdate)
+	r.GET("/api/projects/:id/updates", middlewares.OptionalAuthMiddleware(), projectUpdateHandlers.ListUpdates)
+	r.GET("/api/projects/:id/updates/:updateID", middlewares.OptionalAuthMiddleware(), projectUpdateHandlers.GetUpdate)
+	r.PUT("/api/projects/:id/updates/:updateID", middlewares.AuthMiddleware(), projectUpdateHandlers.EditUpdate)
+	r.DELETE("/api/projects/:id/updates/:updateID", middlewares.AuthMiddleware(), projectUpdateHandlers.DeleteUpdate)
+	r.GET("/api/projects/:id/comments", middlewares.OptionalAuthMiddleware(), commentHandlers.ListComments)
+	r.POST("/api/projects/:id/comments", middlewares.AuthMiddleware(), commentHandlers.CreateComment)
+	r.GET("/api/projects/:id/updates/:updateID/comments", middlewares.OptionalAuthMiddleware(), commentHandlers.ListComments)
+	r.POST("/api/projects/:id/updates/:updateID/comments", middlewares.AuthMiddleware(), commentHandlers.CreateComment)
+	r.PUT("/api/comments/:commentID", middlewares.AuthMiddleware(), commentHandlers.EditComment)
+	r.DELETE("/api/comments/:commentID", middlewares.AuthMiddleware(), commentHandlers.DeleteComment)
+	r.POST("/api/reports", middlewares.AuthMiddleware(), moderationHandlers.CreateReport)
+	if localBlobStore != nil {
+		r.GET("/media/*key", handlers.ServeLocalMedia(localBlobStore))
+	}
+
+	r.GET("/api/categories", categoryHandlers.ListCategories)
+	r.GET("/api/tags", categoryHandlers.ListTags)
+
+	admin := r.Group("/api/admin", middlewares.AuthMiddleware(), middlewares.AdminMiddleware())
+	admin.POST("/categories", categoryHandlers.CreateCategory)
+	admin.PUT("/categories/:id", categoryHandlers.UpdateCategory)
+	admin.DELETE("/categories/:id", categoryHandlers.DeleteCategory)
+	admin.POST("/tags/merge", categoryHandlers.MergeTags)
+	admin.DELETE("/projects/:id", projectHandlers.AdminDeleteProject)
+	admin.POST("/projects/:id/restore", projectHandlers.RestoreProject)
+	admin.GET("/featured", rankingHandlers.ListFeaturedSlots)
+	admin.PUT("/featured/:slot", rankingHandlers.SetFeaturedSlot)
+	admin.DELETE("/featured/:slot", rankingHandlers.ClearFeaturedSlot)
+	admin.GET("/jobs", jobHandlers.ListPending)
+	admin.GET("/jobs/dead", jobHandlers.ListDead)
+	admin.POST("/jobs/dead/:id/replay", jobHandlers.ReplayDead)
+	admin.GET("/moderation/queue", moderationHandlers.Queue)
+	admin.POST("/moderation/actions", moderationHandlers.TakeAction)
+	admin.GET("/moderation/:type/:id", moderationHandlers.TargetDetails)
 
-	r.POST("/api/projects/:id/donations", middlewares.AuthMiddleware(), donationHandlers.CreateDonation)
-	r.GET("/api/projects/:id/donations", middlewares.AuthMiddleware(), donationHandlers.GetDonationsByProjectID)
+	r.POST("/api/projects/:id/donations", middlewares.AuthMiddleware(), middlewares.IdempotencyMiddleware(idempotencyService), donationHandlers.CreateDonation)
+	r.GET("/api/projects/:id/donations", middlewares.OptionalAuthMiddleware(), donationHandlers.GetDonationsByProjectID)
+	r.POST("/api/projects/:id/subscriptions", middlewares.AuthMiddleware(), middlewares.IdempotencyMiddleware(idempotencyService), subscriptionHandlers.CreateSubscription)
+	r.GET("/api/donations/:ref", middlewares.AuthMiddleware(), donationHandlers.GetDonation)
+	r.POST("/api/donations/:ref/refunds", middlewares.AuthMiddleware(), refundHandlers.CreateRefund)
+	r.GET("/api/donations/:ref/refunds", middlewares.AuthMiddleware(), refundHandlers.ListRefunds)
+	r.POST("/webhooks/payments/:provider", paymentWebhookHandlers.ReceiveWebhook)
 	r.POST("/password", passHandlers.GetHashForPass)
 	
 	srv := &http.Server{
@@ -136,8 +339,8 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
-	close(donationTasks) // Signal workers to stop
-	donationWg.Wait()    // Wait for workers to finish
+	stopSchedulers()
+	jobWg.Wait() // Wait for workers to finish their current job
 
 	log.Println("Server exiting")
 }
@@ -1,8 +1,10 @@
 package middlewares
 
 import (
+	"crowdfund/backend/models"
 	"crowdfund/backend/services"
 	"crowdfund/backend/utils"
+	"errors"
 	"net/http"
 	"os"
 	"strings"
@@ -19,33 +21,55 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-		tokenID, err := utils.ExtractTokenID(tokenString)
-
+		user, err := authenticate(c, authHeader)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			return
 		}
 
-		if utils.IsTokenRevoked(tokenID) {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
-			return
-		}
+		c.Set("user", user)
+		c.Next()
+	}
+}
 
-		claims, err := utils.ValidateJWT(tokenString, os.Getenv("JWT_SECRET"))
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			return
+// OptionalAuthMiddleware sets "user" when the request carries a valid token
+// and lets anonymous requests through untouched. Handlers behind it must
+// check c.Get("user") themselves.
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+			if user, err := authenticate(c, authHeader); err == nil {
+				c.Set("user", user)
+			}
 		}
+		c.Next()
+	}
+}
 
-		userService := services.NewUserService(c.MustGet("db").(*gorm.DB))
-		user, err := userService.GetUserByID(uint(claims.UserID))
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-			return
-		}
+func authenticate(c *gin.Context, authHeader string) (models.User, error) {
+	tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
+	tokenID, err := utils.ExtractTokenID(tokenString)
 
-		c.Set("user", user)
-		c.Next()
+	if err != nil {
+		return models.User{}, errors.New("Invalid token")
+	}
+
+	if utils.IsTokenRevoked(tokenID) {
+		return models.User{}, errors.New("Token revoked")
+	}
+
+	claims, err := utils.ValidateJWT(tokenString, os.Getenv("JWT_SECRET"))
+	if err != nil {
+		return models.User{}, errors.New("Invalid token")
+	}
+
+	userService := services.NewUserService(c.MustGet("db").(*gorm.DB))
+	user, err := userService.GetUserByID(uint(claims.UserID))
+	if err != nil {
+		return models.User{}, errors.New("User not found")
+	}
+	if user.BannedAt != nil {
+		return models.User{}, errors.New("Account suspended")
 	}
+	return user, nil
 }
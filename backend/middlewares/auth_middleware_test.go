@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"crowdfund/backend/models"
+	"crowdfund/backend/services"
+	"crowdfund/backend/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestAuthMiddleware_Banned tests that a banned user's token stops working
+func TestAuthMiddleware_Banned(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Project{}, &models.Report{}, &models.ModerationAction{}))
+	author := models.User{Username: "author", Email: "author@example.com"}
+	require.NoError(t, db.Create(&author).Error)
+	project := models.Project{Title: "Spam", UserID: author.ID, StartDate: time.Now(), EndDate: time.Now().Add(time.Hour)}
+	require.NoError(t, db.Create(&project).Error)
+	token, err := utils.GenerateJWT(author.ID, "test-secret")
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/me", DBMiddleware(db), AuthMiddleware(), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusNoContent, get().Code)
+
+	_, err = services.NewModerationService(db, 0).TakeAction(1, models.TakeModerationAction{
+		TargetType: models.ReportTargetProject,
+		TargetID:   project.ID,
+		Action:     models.ActionBan,
+	})
+	require.NoError(t, err)
+	w := get()
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Account suspended")
+}
@@ -0,0 +1,136 @@
+package middlewares
+
+import (
+	"bytes"
+	"crowdfund/backend/models"
+	"crowdfund/backend/services"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxIdempotencyKeyLength matches the idempotency_keys.key column.
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotentBody caps the bodies read for fingerprinting. The routes
+// behind this middleware take small JSON documents.
+const maxIdempotentBody = 1 << 20
+
+// replayedHeaders are the response headers stored with an idempotent
+// response and sent again with it.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag", "Cache-Control"}
+
+// IdempotencyMiddleware must run after AuthMiddleware. Requests carrying an
+// Idempotency-Key header are recorded per user; a retry with the same key
+// and body gets the first response back, headers such as Location
+// included, marked with an Idempotent-Replayed header, instead of running
+// again. Server errors are not stored so those requests can be retried,
+// and a key whose request never finished can be reused after a couple of
+// minutes.
+func IdempotencyMiddleware(idempotencyService *services.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBody))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		user := c.MustGet("user").(models.User)
+		fingerprint := services.RequestFingerprint(c.Request.Method, c.Request.URL.Path, body)
+		record, replay, err := idempotencyService.Begin(user.ID, key, fingerprint)
+		switch {
+		case errors.Is(err, services.ErrIdempotencyKeyReused):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrIdempotencyKeyInProgress):
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		case replay:
+			header, err := services.ReplayedHeaders(record)
+			if err != nil {
+				log.Printf("Error reading stored headers of idempotency key %d: %v", record.ID, err)
+			}
+			contentType := header.Get("Content-Type")
+			if contentType == "" {
+				contentType = "application/json; charset=utf-8"
+			}
+			for name, values := range header {
+				if name != "Content-Type" {
+					c.Writer.Header()[name] = values
+				}
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(record.ResponseStatus, contentType, record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		completed := false
+		defer func() {
+			// Runs on panics too, so a crashed request does not hold the key.
+			if completed {
+				return
+			}
+			if err := idempotencyService.Release(record); err != nil {
+				log.Printf("Error releasing idempotency key %d: %v", record.ID, err)
+			}
+		}()
+
+		c.Next()
+
+		if recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+		header := http.Header{}
+		for _, name := range replayedHeaders {
+			if values := recorder.Header().Values(name); len(values) > 0 {
+				header[name] = values
+			}
+		}
+		if err := idempotencyService.Complete(record, recorder.Status(), header, recorder.body.Bytes()); err != nil {
+			log.Printf("Error storing idempotent response %d: %v", record.ID, err)
+			return
+		}
+		completed = true
+	}
+}
+
+// responseRecorder keeps a copy of the response body as it is written.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,116 @@
+package middlewares
+
+import (
+	"crowdfund/backend/models"
+	"crowdfund/backend/services"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newIdempotentRouter serves POST /donations behind IdempotencyMiddleware
+// as user 1, counting how often the handler runs.
+func newIdempotentRouter(t *testing.T) (*gin.Engine, *gorm.DB, *int) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.IdempotencyKey{}))
+	// The unique key from the migration, which the model does not declare.
+	require.NoError(t, db.Exec("CREATE UNIQUE INDEX idx_idempotency_keys_user_key ON idempotency_keys(user_id, key)").Error)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	runs := 0
+	r.POST("/donations", func(c *gin.Context) {
+		c.Set("user", models.User{ID: 1})
+	}, IdempotencyMiddleware(services.NewIdempotencyService(db, services.IdempotencyKeyTTL)), func(c *gin.Context) {
+		runs++
+		c.Header("Location", "/api/donations/don_"+strconv.Itoa(runs))
+		c.Header("X-Request-Id", strconv.Itoa(runs))
+		c.JSON(http.StatusCreated, gin.H{"run": runs})
+	})
+	return r, db, &runs
+}
+
+func postIdempotent(r *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/donations", strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// TestIdempotencyMiddleware_Replay tests that a retry gets the first response without running the handler again
+func TestIdempotencyMiddleware_Replay(t *testing.T) {
+	r, _, runs := newIdempotentRouter(t)
+
+	first := postIdempotent(r, "k1", `{"amount":"10.00"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	retry := postIdempotent(r, "k1", `{"amount":"10.00"}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "/api/donations/don_1", retry.Header().Get("Location"))
+	assert.Equal(t, first.Header().Get("Content-Type"), retry.Header().Get("Content-Type"))
+	assert.Empty(t, retry.Header().Get("X-Request-Id"))
+	assert.Equal(t, 1, *runs)
+
+	other := postIdempotent(r, "k2", `{"amount":"10.00"}`)
+	assert.Equal(t, http.StatusCreated, other.Code)
+	assert.Empty(t, other.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 2, *runs)
+}
+
+// TestIdempotencyMiddleware_BodyMismatch tests that reusing a key for a different body is rejected
+func TestIdempotencyMiddleware_BodyMismatch(t *testing.T) {
+	r, _, runs := newIdempotentRouter(t)
+
+	postIdempotent(r, "k1", `{"amount":"10.00"}`)
+	w := postIdempotent(r, "k1", `{"amount":"20.00"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), services.ErrIdempotencyKeyReused.Error())
+	assert.Equal(t, 1, *runs)
+}
+
+// TestIdempotencyMiddleware_InProgress tests that a key still running is refused until its lease runs out
+func TestIdempotencyMiddleware_InProgress(t *testing.T) {
+	r, db, runs := newIdempotentRouter(t)
+	body := `{"amount":"10.00"}`
+	stuck := models.IdempotencyKey{
+		UserID:      1,
+		Key:         "k1",
+		Fingerprint: services.RequestFingerprint(http.MethodPost, "/donations", []byte(body)),
+		ExpiresAt:   time.Now().Add(services.IdempotencyKeyTTL),
+	}
+	require.NoError(t, db.Create(&stuck).Error)
+
+	w := postIdempotent(r, "k1", body)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), services.ErrIdempotencyKeyInProgress.Error())
+	assert.Zero(t, *runs)
+
+	// The request that claimed it crashed a while ago.
+	require.NoError(t, db.Model(&stuck).Update("created_at", time.Now().Add(-time.Hour)).Error)
+	w = postIdempotent(r, "k1", body)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 1, *runs)
+}
+
+// TestIdempotencyMiddleware_BodyTooLarge tests that oversized bodies are refused before they are read into memory
+func TestIdempotencyMiddleware_BodyTooLarge(t *testing.T) {
+	r, _, runs := newIdempotentRouter(t)
+
+	w := postIdempotent(r, "k1", strings.Repeat("x", maxIdempotentBody+1))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Zero(t, *runs)
+}
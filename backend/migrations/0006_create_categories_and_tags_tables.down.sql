@@ -0,0 +1,4 @@
+ALTER TABLE projects DROP COLUMN category_id;
+DROP TABLE project_tags;
+DROP TABLE tags;
+DROP TABLE categories;
@@ -0,0 +1,16 @@
+CREATE TABLE project_updates (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER REFERENCES projects(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id),
+    title VARCHAR(255) NOT NULL,
+    body_markdown TEXT NOT NULL,
+    body_html TEXT NOT NULL,
+    backers_only BOOLEAN NOT NULL DEFAULT FALSE,
+    publish_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    notified_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_project_updates_project_id ON project_updates(project_id, publish_at);
+CREATE INDEX idx_project_updates_pending ON project_updates(publish_at) WHERE notified_at IS NULL;
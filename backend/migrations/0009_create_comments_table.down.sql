@@ -0,0 +1,2 @@
+ALTER TABLE projects DROP COLUMN comments_backers_only;
+DROP TABLE comments;
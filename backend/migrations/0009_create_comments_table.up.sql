@@ -0,0 +1,18 @@
+CREATE TABLE comments (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER REFERENCES projects(id) ON DELETE CASCADE,
+    update_id INTEGER REFERENCES project_updates(id) ON DELETE CASCADE,
+    parent_id INTEGER REFERENCES comments(id),
+    root_id INTEGER REFERENCES comments(id),
+    user_id INTEGER REFERENCES users(id),
+    body TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX idx_comments_thread ON comments(project_id, update_id, created_at) WHERE parent_id IS NULL;
+CREATE INDEX idx_comments_root_id ON comments(root_id);
+CREATE INDEX idx_comments_user_created ON comments(user_id, created_at);
+
+ALTER TABLE projects ADD COLUMN comments_backers_only BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,5 @@
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+ALTER TABLE users DROP COLUMN banned_at;
+ALTER TABLE comments DROP COLUMN moderation_status;
+ALTER TABLE projects DROP COLUMN moderation_status;
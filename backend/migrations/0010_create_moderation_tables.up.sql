@@ -0,0 +1,31 @@
+ALTER TABLE projects ADD COLUMN moderation_status VARCHAR(16) NOT NULL DEFAULT 'active';
+ALTER TABLE comments ADD COLUMN moderation_status VARCHAR(16) NOT NULL DEFAULT 'active';
+ALTER TABLE users ADD COLUMN banned_at TIMESTAMP;
+
+CREATE TABLE reports (
+    id SERIAL PRIMARY KEY,
+    target_type VARCHAR(16) NOT NULL,
+    target_id INTEGER NOT NULL,
+    reporter_id INTEGER REFERENCES users(id),
+    reason VARCHAR(32) NOT NULL,
+    details TEXT,
+    status VARCHAR(16) NOT NULL DEFAULT 'open',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP,
+    resolved_by INTEGER REFERENCES users(id),
+    UNIQUE (target_type, target_id, reporter_id)
+);
+
+CREATE INDEX idx_reports_open ON reports(target_type, target_id) WHERE status = 'open';
+
+CREATE TABLE moderation_actions (
+    id SERIAL PRIMARY KEY,
+    target_type VARCHAR(16) NOT NULL,
+    target_id INTEGER NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    admin_id INTEGER REFERENCES users(id),
+    note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_moderation_actions_target ON moderation_actions(target_type, target_id, created_at);
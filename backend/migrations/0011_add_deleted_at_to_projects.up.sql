@@ -0,0 +1,3 @@
+ALTER TABLE projects ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_projects_deleted_at ON projects(deleted_at);
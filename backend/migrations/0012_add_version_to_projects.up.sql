@@ -0,0 +1 @@
+ALTER TABLE projects ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
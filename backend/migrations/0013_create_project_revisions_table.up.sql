@@ -0,0 +1,10 @@
+CREATE TABLE project_revisions (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER REFERENCES projects(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    user_id INTEGER REFERENCES users(id),
+    snapshot TEXT NOT NULL,
+    changed_fields TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (project_id, version)
+);
@@ -0,0 +1,2 @@
+DROP TABLE project_slug_history;
+ALTER TABLE projects DROP COLUMN slug;
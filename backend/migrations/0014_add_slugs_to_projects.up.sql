@@ -0,0 +1,23 @@
+ALTER TABLE projects ADD COLUMN slug VARCHAR(255);
+
+-- Existing projects get the slugified title with their ID appended, cut to
+-- the 100 characters slugs are limited to (maxSlugLength in
+-- services/project_slugs.go). Cutting may leave a hyphen at the end, which
+-- would double up with the suffix's.
+UPDATE projects
+SET slug = coalesce(
+    nullif(rtrim(left(trim(both '-' from lower(regexp_replace(title, '[^a-zA-Z0-9]+', '-', 'g'))), 100 - length('-' || id)), '-'), ''),
+    'project'
+) || '-' || id;
+
+ALTER TABLE projects ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE projects ADD CONSTRAINT projects_slug_key UNIQUE (slug);
+
+-- Old slugs keep resolving (and redirecting) after a project is renamed.
+CREATE TABLE project_slug_history (
+    slug VARCHAR(255) PRIMARY KEY,
+    project_id INTEGER REFERENCES projects(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_project_slug_history_project_id ON project_slug_history(project_id);
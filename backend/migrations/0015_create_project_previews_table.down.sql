@@ -0,0 +1 @@
+DROP TABLE project_previews;
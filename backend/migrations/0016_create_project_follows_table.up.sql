@@ -0,0 +1,18 @@
+CREATE TABLE project_follows (
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    project_id INTEGER REFERENCES projects(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    ending_soon_notified_at TIMESTAMP,
+    funded_notified_at TIMESTAMP,
+    PRIMARY KEY (user_id, project_id)
+);
+
+CREATE INDEX idx_project_follows_project_id ON project_follows(project_id);
+
+-- Users without a row get the defaults: every notification enabled.
+CREATE TABLE notification_preferences (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    project_ending_soon BOOLEAN NOT NULL DEFAULT TRUE,
+    project_funded BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1 @@
+DROP TABLE featured_projects;
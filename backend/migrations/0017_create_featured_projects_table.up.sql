@@ -0,0 +1,7 @@
+CREATE TABLE featured_projects (
+    slot INTEGER PRIMARY KEY,
+    project_id INTEGER NOT NULL UNIQUE REFERENCES projects(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id),
+    ends_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
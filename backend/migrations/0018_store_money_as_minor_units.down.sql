@@ -0,0 +1,10 @@
+UPDATE project_revisions
+SET snapshot = jsonb_set(snapshot::jsonb, '{goal}', to_jsonb((snapshot::jsonb->'goal'->>'amount')::NUMERIC))::TEXT
+WHERE jsonb_typeof(snapshot::jsonb->'goal') = 'object';
+
+ALTER TABLE donations DROP COLUMN currency;
+ALTER TABLE donations ALTER COLUMN amount TYPE FLOAT USING amount / 100.0;
+
+ALTER TABLE projects DROP COLUMN goal_currency;
+ALTER TABLE projects ALTER COLUMN goal_amount TYPE FLOAT USING goal_amount / 100.0;
+ALTER TABLE projects RENAME COLUMN goal_amount TO goal;
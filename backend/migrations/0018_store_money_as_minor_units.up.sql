@@ -0,0 +1,20 @@
+-- Amounts move from FLOAT major units to BIGINT minor units (cents) with an
+-- ISO 4217 currency code. Everything so far was in US dollars.
+ALTER TABLE projects RENAME COLUMN goal TO goal_amount;
+ALTER TABLE projects ALTER COLUMN goal_amount TYPE BIGINT USING round(goal_amount * 100)::BIGINT;
+ALTER TABLE projects ADD COLUMN goal_currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE donations ALTER COLUMN amount TYPE BIGINT USING round(amount * 100)::BIGINT;
+ALTER TABLE donations ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+-- Revision snapshots store the goal as JSON; rewrite it in the new format.
+UPDATE project_revisions
+SET snapshot = jsonb_set(
+    snapshot::jsonb,
+    '{goal}',
+    jsonb_build_object(
+        'amount', to_char((snapshot::jsonb->>'goal')::NUMERIC, 'FM999999999999990.00'),
+        'currency', 'USD'
+    )
+)::TEXT
+WHERE jsonb_typeof(snapshot::jsonb->'goal') = 'number';
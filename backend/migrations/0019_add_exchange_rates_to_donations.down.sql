@@ -0,0 +1,5 @@
+ALTER TABLE donations DROP COLUMN rate_fetched_at;
+ALTER TABLE donations DROP COLUMN rate_source;
+ALTER TABLE donations DROP COLUMN exchange_rate;
+ALTER TABLE donations DROP COLUMN base_currency;
+ALTER TABLE donations DROP COLUMN base_amount;
@@ -0,0 +1,12 @@
+-- Each donation records its value in the project's base currency and the
+-- exchange rate used, so historical totals can be reproduced exactly.
+ALTER TABLE donations ADD COLUMN base_amount BIGINT;
+ALTER TABLE donations ADD COLUMN base_currency CHAR(3);
+ALTER TABLE donations ADD COLUMN exchange_rate TEXT NOT NULL DEFAULT '1';
+ALTER TABLE donations ADD COLUMN rate_source VARCHAR(255) NOT NULL DEFAULT 'identity';
+ALTER TABLE donations ADD COLUMN rate_fetched_at TIMESTAMP;
+
+UPDATE donations SET base_amount = amount, base_currency = currency, rate_fetched_at = timestamp;
+
+ALTER TABLE donations ALTER COLUMN base_amount SET NOT NULL;
+ALTER TABLE donations ALTER COLUMN base_currency SET NOT NULL;
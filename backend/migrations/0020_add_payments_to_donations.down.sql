@@ -0,0 +1,4 @@
+DROP INDEX idx_donations_payment_intent;
+ALTER TABLE donations DROP COLUMN payment_status;
+ALTER TABLE donations DROP COLUMN payment_intent_id;
+ALTER TABLE donations DROP COLUMN payment_provider;
@@ -0,0 +1,5 @@
+ALTER TABLE donations ADD COLUMN payment_provider VARCHAR(50) NOT NULL DEFAULT 'legacy';
+ALTER TABLE donations ADD COLUMN payment_intent_id VARCHAR(255);
+ALTER TABLE donations ADD COLUMN payment_status VARCHAR(20) NOT NULL DEFAULT 'captured';
+
+CREATE UNIQUE INDEX idx_donations_payment_intent ON donations(payment_provider, payment_intent_id);
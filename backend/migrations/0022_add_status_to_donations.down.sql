@@ -0,0 +1,8 @@
+DROP INDEX idx_donations_project_status;
+DROP INDEX idx_donations_payment_intent;
+CREATE UNIQUE INDEX idx_donations_payment_intent ON donations(payment_provider, payment_intent_id);
+
+ALTER TABLE donations DROP COLUMN failure_reason;
+ALTER TABLE donations DROP COLUMN status;
+DROP INDEX idx_donations_reference;
+ALTER TABLE donations DROP COLUMN reference;
@@ -0,0 +1,16 @@
+ALTER TABLE donations ADD COLUMN reference VARCHAR(32);
+UPDATE donations SET reference = 'don_' || substr(md5(random()::text || id::text), 1, 24);
+ALTER TABLE donations ALTER COLUMN reference SET NOT NULL;
+CREATE UNIQUE INDEX idx_donations_reference ON donations(reference);
+
+-- Donations saved before this change had already been charged.
+ALTER TABLE donations ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'succeeded';
+ALTER TABLE donations ALTER COLUMN status SET DEFAULT 'queued';
+UPDATE donations SET status = 'refunded' WHERE payment_status = 'refunded';
+ALTER TABLE donations ADD COLUMN failure_reason TEXT NOT NULL DEFAULT '';
+
+-- Queued donations have no payment intent yet.
+DROP INDEX idx_donations_payment_intent;
+CREATE UNIQUE INDEX idx_donations_payment_intent ON donations(payment_provider, payment_intent_id)
+    WHERE payment_intent_id IS NOT NULL AND payment_intent_id <> '';
+CREATE INDEX idx_donations_project_status ON donations(project_id, status);
@@ -0,0 +1,28 @@
+-- Outbox of work for background workers. Rows are written in the same
+-- transaction as the change that needs them and deleted once done.
+CREATE TABLE jobs (
+    id SERIAL PRIMARY KEY,
+    queue VARCHAR(50) NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    run_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_jobs_queue_run_at ON jobs(queue, run_at);
+
+-- Jobs that ran out of attempts, kept for inspection and replay.
+CREATE TABLE dead_jobs (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER NOT NULL,
+    queue VARCHAR(50) NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INTEGER NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    enqueued_at TIMESTAMP NOT NULL,
+    failed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    replayed_at TIMESTAMP
+);
+
+CREATE INDEX idx_dead_jobs_failed_at ON dead_jobs(failed_at) WHERE replayed_at IS NULL;
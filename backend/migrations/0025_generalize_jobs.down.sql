@@ -0,0 +1,3 @@
+DELETE FROM jobs WHERE queue = 'payment_events';
+DELETE FROM dead_jobs WHERE job_id !~ '^[0-9]+$';
+ALTER TABLE dead_jobs ALTER COLUMN job_id TYPE INTEGER USING job_id::INTEGER;
@@ -0,0 +1,7 @@
+-- Jobs from the Redis backend have stream IDs such as "1700000000000-0".
+ALTER TABLE dead_jobs ALTER COLUMN job_id TYPE VARCHAR(64);
+
+-- Webhook events are now applied through the job queue. Queue the ones
+-- still waiting so they are not stranded.
+INSERT INTO jobs (queue, payload)
+SELECT 'payment_events', id::TEXT FROM payment_events WHERE processed_at IS NULL;
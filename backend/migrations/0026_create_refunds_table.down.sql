@@ -0,0 +1,4 @@
+ALTER TABLE projects DROP COLUMN cancelled_at;
+ALTER TABLE donations DROP COLUMN base_refunded_amount;
+ALTER TABLE donations DROP COLUMN refunded_amount;
+DROP TABLE refunds;
@@ -0,0 +1,25 @@
+CREATE TABLE refunds (
+    id SERIAL PRIMARY KEY,
+    donation_id INTEGER NOT NULL REFERENCES donations(id),
+    amount BIGINT NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    base_amount BIGINT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    requested_by INTEGER REFERENCES users(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    failure_reason TEXT NOT NULL DEFAULT '',
+    provider_refund_id VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP
+);
+
+CREATE INDEX idx_refunds_donation_id ON refunds(donation_id);
+
+-- Refunded and in-flight refund amounts, so totals and the refundable
+-- remainder are read from the donation row. Donations already refunded by
+-- webhooks were refunded in full.
+ALTER TABLE donations ADD COLUMN refunded_amount BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE donations ADD COLUMN base_refunded_amount BIGINT NOT NULL DEFAULT 0;
+UPDATE donations SET refunded_amount = amount, base_refunded_amount = base_amount WHERE status = 'refunded';
+
+ALTER TABLE projects ADD COLUMN cancelled_at TIMESTAMP;
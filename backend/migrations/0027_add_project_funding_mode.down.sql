@@ -0,0 +1,5 @@
+UPDATE project_revisions SET snapshot = (snapshot::jsonb - 'funding_mode')::text;
+DROP INDEX idx_projects_unclosed_end_date;
+ALTER TABLE projects DROP COLUMN funding_result;
+ALTER TABLE projects DROP COLUMN closed_at;
+ALTER TABLE projects DROP COLUMN funding_mode;
@@ -0,0 +1,10 @@
+ALTER TABLE projects ADD COLUMN funding_mode VARCHAR(20) NOT NULL DEFAULT 'flexible';
+ALTER TABLE projects ADD COLUMN closed_at TIMESTAMP;
+ALTER TABLE projects ADD COLUMN funding_result VARCHAR(20) NOT NULL DEFAULT '';
+
+-- Campaigns waiting for the close-out scheduler.
+CREATE INDEX idx_projects_unclosed_end_date ON projects(end_date) WHERE closed_at IS NULL;
+
+-- Every project so far was flexible; say so in its history so diffs
+-- against old revisions do not show the mode appearing.
+UPDATE project_revisions SET snapshot = jsonb_set(snapshot::jsonb, '{funding_mode}', '"flexible"')::text;
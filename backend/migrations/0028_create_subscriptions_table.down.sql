@@ -0,0 +1,3 @@
+ALTER TABLE subscriptions DROP CONSTRAINT fk_subscriptions_pending_donation;
+ALTER TABLE donations DROP COLUMN subscription_id;
+DROP TABLE subscriptions;
@@ -0,0 +1,27 @@
+CREATE TABLE subscriptions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    project_id INTEGER NOT NULL REFERENCES projects(id),
+    amount BIGINT NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    interval VARCHAR(10) NOT NULL,
+    start_date TIMESTAMP NOT NULL,
+    cycle INTEGER NOT NULL DEFAULT 0,
+    next_billing_at TIMESTAMP NOT NULL,
+    retry_at TIMESTAMP,
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    payment_method VARCHAR(255) NOT NULL,
+    pending_donation_id INTEGER,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    last_failure TEXT NOT NULL DEFAULT '',
+    cancelled_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_subscriptions_user_id ON subscriptions(user_id);
+CREATE INDEX idx_subscriptions_next_billing_at ON subscriptions(next_billing_at) WHERE status IN ('active', 'past_due');
+
+ALTER TABLE donations ADD COLUMN subscription_id INTEGER REFERENCES subscriptions(id);
+CREATE INDEX idx_donations_subscription_id ON donations(subscription_id) WHERE subscription_id IS NOT NULL;
+ALTER TABLE subscriptions ADD CONSTRAINT fk_subscriptions_pending_donation FOREIGN KEY (pending_donation_id) REFERENCES donations(id);
@@ -0,0 +1,5 @@
+DROP INDEX idx_donations_project_timestamp;
+ALTER TABLE donations DROP COLUMN moderation_status;
+ALTER TABLE donations DROP COLUMN message;
+ALTER TABLE donations DROP COLUMN display_name;
+ALTER TABLE donations DROP COLUMN anonymous;
@@ -0,0 +1,6 @@
+ALTER TABLE donations ADD COLUMN anonymous BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE donations ADD COLUMN display_name VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE donations ADD COLUMN message TEXT NOT NULL DEFAULT '';
+ALTER TABLE donations ADD COLUMN moderation_status VARCHAR(16) NOT NULL DEFAULT 'active';
+
+CREATE INDEX idx_donations_project_timestamp ON donations(project_id, timestamp DESC);
@@ -0,0 +1,9 @@
+UPDATE jobs j SET payload = jsonb_set(j.payload::jsonb, '{payment_method}', to_jsonb(d.payment_method))::TEXT
+FROM donations d
+WHERE j.queue = 'donations' AND (j.payload::jsonb->>'donation_id')::int = d.id;
+
+UPDATE dead_jobs j SET payload = jsonb_set(j.payload::jsonb, '{payment_method}', to_jsonb(d.payment_method))::TEXT
+FROM donations d
+WHERE j.queue = 'donations' AND (j.payload::jsonb->>'donation_id')::int = d.id;
+
+ALTER TABLE donations DROP COLUMN payment_method;
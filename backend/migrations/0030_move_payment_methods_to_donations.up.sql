@@ -0,0 +1,16 @@
+-- Card tokens are kept on the donation instead of in the charge job's
+-- payload, so job listings and dead letters no longer carry them.
+ALTER TABLE donations ADD COLUMN payment_method VARCHAR(255) NOT NULL DEFAULT '';
+
+UPDATE donations d SET payment_method = j.payload::jsonb->>'payment_method'
+FROM jobs j
+WHERE j.queue = 'donations' AND (j.payload::jsonb->>'donation_id')::int = d.id
+  AND j.payload::jsonb ? 'payment_method';
+
+UPDATE donations d SET payment_method = j.payload::jsonb->>'payment_method'
+FROM dead_jobs j
+WHERE j.queue = 'donations' AND (j.payload::jsonb->>'donation_id')::int = d.id
+  AND j.payload::jsonb ? 'payment_method' AND d.payment_method = '';
+
+UPDATE jobs SET payload = (payload::jsonb - 'payment_method')::TEXT WHERE queue = 'donations';
+UPDATE dead_jobs SET payload = (payload::jsonb - 'payment_method')::TEXT WHERE queue = 'donations';
@@ -0,0 +1,4 @@
+-- Pledges save the donor's card with the provider and are charged when
+-- their campaign closes, since card authorizations lapse after about a
+-- week. Pledges authorized before this keep their authorization.
+ALTER TABLE donations ADD COLUMN payment_customer VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- Plans save the donor's card with the provider when they start, so later
+-- cycles can be charged off-session. Plans started before this have no
+-- customer and their cards are charged as on-session payments.
+ALTER TABLE subscriptions ADD COLUMN payment_customer VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- Headers such as Location are replayed along with the stored body, so a
+-- retried create looks the same as the first response. Stored as a JSON
+-- object of header names to values; empty for keys stored before this.
+ALTER TABLE idempotency_keys ADD COLUMN response_headers TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type Category struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	ParentID  *uint     `json:"parent_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	// Populated by CategoryService.ListCategories, not stored.
+	ProjectCount int64      `gorm:"-" json:"project_count"`
+	Children     []Category `gorm:"-" json:"children,omitempty"`
+}
+
+type CreateCategory struct {
+	Name     string `json:"name" binding:"required"`
+	Slug     string `json:"slug"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"-"`
+}
+
+type SetProjectTags struct {
+	Tags []string `json:"tags"`
+}
+
+type MergeTags struct {
+	SourceIDs []uint `json:"source_ids" binding:"required"`
+	TargetID  uint   `json:"target_id" binding:"required"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+const (
+	DeletedCommentBody = "[deleted]"
+	RemovedCommentBody = "[removed by moderator]"
+)
+
+// Comment belongs to a project, or to one of its updates when UpdateID is
+// set. Replies point at their parent and at the top-level comment of their
+// thread (RootID) so a whole thread can be loaded in one query.
+type Comment struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	ProjectID uint       `json:"project_id"`
+	UpdateID  *uint      `json:"update_id,omitempty"`
+	ParentID  *uint      `json:"parent_id,omitempty"`
+	RootID    *uint      `json:"-"`
+	UserID    uint       `json:"user_id"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt *time.Time `json:"-"`
+
+	ModerationStatus string `gorm:"default:active" json:"-"`
+
+	// Filled in when the comment is returned, not stored.
+	Username  string    `gorm:"-" json:"username"`
+	IsCreator bool      `gorm:"-" json:"is_creator"`
+	Deleted   bool      `gorm:"-" json:"deleted"`
+	Replies   []Comment `gorm:"-" json:"replies"`
+}
+
+type CreateComment struct {
+	Body     string `json:"body" binding:"required"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+type EditComment struct {
+	Body string `json:"body" binding:"required"`
+}
+
+// CommentPage is one page of top-level comments with their full reply trees.
+type CommentPage struct {
+	Comments []Comment `json:"comments"`
+	Page     int       `json:"page"`
+	PerPage  int       `json:"per_page"`
+	Total    int64     `json:"total"`
+}
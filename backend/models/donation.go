@@ -2,22 +2,97 @@ package models
 
 import "time"
 
+// Donation status. A donation is saved as queued when it is made and a
+// worker charges it in the background; clients poll its reference for the
+// outcome. Donations to all-or-nothing projects stop at authorized, with
+// the donor's card saved rather than charged, until the campaign closes,
+// then succeed or fail with it.
+const (
+	DonationQueued     = "queued"
+	DonationProcessing = "processing"
+	DonationAuthorized = "authorized"
+	DonationSucceeded  = "succeeded"
+	DonationFailed     = "failed"
+	DonationRefunded   = "refunded"
+)
+
+// CountedDonationStatuses are the statuses that count towards a project's
+// total and make the donor a backer: charged donations and authorized
+// pledges.
+var CountedDonationStatuses = []string{DonationAuthorized, DonationSucceeded}
+
 type Donation struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
+	Reference string    `json:"reference"` // Opaque ID given to the client, e.g. "don_..."
 	ProjectID uint      `json:"project_id"`
 	UserID    uint      `json:"user_id"`
-	Amount    float64   `json:"amount"`
+	Amount    Money     `gorm:"embedded" json:"amount"`
 	Timestamp time.Time `gorm:"autoCreateTime" json:"timestamp"`
+
+	Status        string `json:"status"`
+	FailureReason string `json:"failure_reason,omitempty"`
+
+	// BaseAmount is Amount converted to the project's base currency with
+	// the rate below, recorded at donation time so totals never shift when
+	// rates move.
+	BaseAmount    Money     `gorm:"embedded;embeddedPrefix:base_" json:"base_amount"`
+	ExchangeRate  string    `json:"exchange_rate"` // Units of base currency per unit of Amount's currency
+	RateSource    string    `json:"rate_source"`
+	RateFetchedAt time.Time `json:"rate_fetched_at"`
+
+	// RefundedAmount is how much of Amount has been refunded or is being
+	// refunded; BaseRefundedAmount is the same in the base currency and is
+	// left out of project totals.
+	RefundedAmount     int64 `json:"-"`
+	BaseRefundedAmount int64 `json:"-"`
+
+	SubscriptionID *uint `json:"subscription_id,omitempty"` // Set on the donations of a recurring plan
+
+	// Anonymous donors are listed without their name or user ID to anyone
+	// but the project's creator and admins. DisplayName, when set, is shown
+	// instead of the username.
+	Anonymous        bool   `json:"anonymous"`
+	DisplayName      string `json:"display_name,omitempty"`
+	Message          string `json:"message,omitempty"`
+	ModerationStatus string `gorm:"default:active" json:"-"`
+
+	PaymentProvider string `json:"payment_provider"`
+	PaymentMethod   string `json:"-"` // Processor's token for the donor's card
+	PaymentCustomer string `json:"-"` // Set once the card is saved for off-session charges
+	PaymentIntentID string `json:"-"`
+	PaymentStatus   string `json:"payment_status"`
 }
 
+// CreateDonation is the request body for a donation. The project comes from
+// the URL and the donor from the session, so neither is read from the body.
 type CreateDonation struct {
-	ProjectID uint    `json:"project_id"`
-	UserID    uint    `json:"user_id"`
-	Amount    float64 `json:"amount"`
+	ProjectID uint  `json:"-"`
+	UserID    uint  `json:"-"`
+	Amount    Money `json:"amount" binding:"gt=0"`
+	// PaymentMethod is the processor's token for the donor's card, created
+	// client-side, e.g. "pm_..." for Stripe.
+	PaymentMethod string `json:"payment_method" binding:"required"`
+
+	Anonymous   bool   `json:"anonymous"`
+	DisplayName string `json:"display_name" binding:"max=50"`
+	Message     string `json:"message" binding:"max=500"`
+}
+
+// PublicDonation is a donation as listed on its project's page. UserID and
+// Username are left empty for anonymous donors.
+type PublicDonation struct {
+	ID          uint      `json:"id"`
+	UserID      uint      `json:"user_id,omitempty"`
+	Username    string    `json:"username,omitempty"`
+	DisplayName string    `json:"display_name,omitempty"`
+	Anonymous   bool      `json:"anonymous"`
+	Amount      Money     `json:"amount"`
+	Message     string    `json:"message,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
 }
 
 type UpdateDonation struct {
-	Amount float64 `json:"amount"`
+	Amount Money `json:"amount"`
 }
 
 type DeleteDonation struct {
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// ProjectFollow puts a project on a user's watchlist. The notified
+// timestamps make sure each reminder is sent to a follower only once.
+type ProjectFollow struct {
+	UserID               uint       `gorm:"primaryKey" json:"user_id"`
+	ProjectID            uint       `gorm:"primaryKey" json:"project_id"`
+	CreatedAt            time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	EndingSoonNotifiedAt *time.Time `json:"-"`
+	FundedNotifiedAt     *time.Time `json:"-"`
+}
+
+// FollowedProject is a project on the user's watchlist.
+type FollowedProject struct {
+	Project
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// NotificationPreferences controls which optional emails a user receives.
+type NotificationPreferences struct {
+	UserID            uint      `gorm:"primaryKey" json:"-"`
+	ProjectEndingSoon bool      `json:"project_ending_soon"` // Followed project ends within 48 hours
+	ProjectFunded     bool      `json:"project_funded"`      // Followed project reached its goal
+	UpdatedAt         time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// DefaultNotificationPreferences are used for users who never changed them.
+func DefaultNotificationPreferences(userID uint) NotificationPreferences {
+	return NotificationPreferences{UserID: userID, ProjectEndingSoon: true, ProjectFunded: true}
+}
+
+type UpdateNotificationPreferences struct {
+	ProjectEndingSoon *bool `json:"project_ending_soon"`
+	ProjectFunded     *bool `json:"project_funded"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IdempotencyKey records a request made with an Idempotency-Key header so
+// a retry gets the original response instead of repeating the request.
+// ResponseStatus is zero while the first request is still running.
+type IdempotencyKey struct {
+	ID              uint `gorm:"primaryKey"`
+	UserID          uint
+	Key             string
+	Fingerprint     string // SHA-256 of the method, path and body
+	ResponseStatus  int
+	ResponseHeaders string // JSON object of the headers replayed with the body
+	ResponseBody    []byte
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+	ExpiresAt       time.Time
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Job is a unit of background work in the outbox. Payload is JSON whose
+// shape depends on the queue.
+type Job struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Queue     string    `json:"queue"`
+	Payload   string    `json:"payload"`
+	Attempts  int       `json:"attempts"`
+	RunAt     time.Time `json:"run_at"` // Not picked up before this time
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// DeadJob is a job that failed on every attempt. Replaying it puts a copy
+// back on its queue.
+type DeadJob struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	JobID      string     `json:"job_id"` // The ID the queue backend gave the job
+	Queue      string     `json:"queue"`
+	Payload    string     `json:"payload"`
+	Attempts   int        `json:"attempts"`
+	LastError  string     `json:"last_error"`
+	EnqueuedAt time.Time  `json:"enqueued_at"`
+	FailedAt   time.Time  `gorm:"autoCreateTime" json:"failed_at"`
+	ReplayedAt *time.Time `json:"replayed_at,omitempty"`
+}
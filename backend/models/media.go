@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	MediaKindImage = "image"
+	MediaKindVideo = "video"
+)
+
+type ProjectMedia struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ProjectID    uint      `json:"project_id"`
+	Kind         string    `json:"kind"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	StorageKey   string    `json:"storage_key"`
+	ThumbnailKey *string   `json:"thumbnail_key,omitempty"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	// Signed, expiring URLs filled in just before the media is returned.
+	URL          string `gorm:"-" json:"url"`
+	ThumbnailURL string `gorm:"-" json:"thumbnail_url,omitempty"`
+}
+
+func (ProjectMedia) TableName() string {
+	return "project_media"
+}
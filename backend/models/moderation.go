@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// Moderation status of projects, comments and donation messages. Only active
+// content is shown publicly.
+const (
+	ModerationActive    = "active"
+	ModerationHidden    = "hidden"
+	ModerationSuspended = "suspended"
+)
+
+const (
+	ReportTargetProject  = "project"
+	ReportTargetComment  = "comment"
+	ReportTargetDonation = "donation" // A donation's message and display name
+
+	ReportStatusOpen      = "open"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+)
+
+// Moderation actions an admin can take on a reported target. ActionAutoHide
+// is recorded when the report threshold hides a target automatically.
+const (
+	ActionHide     = "hide"
+	ActionSuspend  = "suspend"
+	ActionBan      = "ban"
+	ActionDismiss  = "dismiss"
+	ActionRestore  = "restore"
+	ActionAutoHide = "auto_hide"
+)
+
+type Report struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	TargetType string     `json:"target_type"`
+	TargetID   uint       `json:"target_id"`
+	ReporterID uint       `json:"reporter_id"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	ResolvedBy *uint      `json:"resolved_by,omitempty"`
+}
+
+type CreateReport struct {
+	TargetType string `json:"target_type" binding:"required,oneof=project comment donation"`
+	TargetID   uint   `json:"target_id" binding:"required"`
+	Reason     string `json:"reason" binding:"required,oneof=scam spam offensive copyright other"`
+	Details    string `json:"details"`
+}
+
+// ModerationQueueItem groups the open reports against one target.
+type ModerationQueueItem struct {
+	TargetType       string    `json:"target_type"`
+	TargetID         uint      `json:"target_id"`
+	ReportCount      int64     `json:"report_count"`
+	FirstReportedAt  time.Time `json:"first_reported_at"`
+	LastReportedAt   time.Time `json:"last_reported_at"`
+	ModerationStatus string    `json:"moderation_status"`
+}
+
+type ModerationAction struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TargetType string    `json:"target_type"`
+	TargetID   uint      `json:"target_id"`
+	Action     string    `json:"action"`
+	AdminID    *uint     `json:"admin_id"` // nil for automatic actions
+	Note       string    `json:"note"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type TakeModerationAction struct {
+	TargetType string `json:"target_type" binding:"required,oneof=project comment donation"`
+	TargetID   uint   `json:"target_id" binding:"required"`
+	Action     string `json:"action" binding:"required,oneof=hide suspend ban dismiss restore"`
+	Note       string `json:"note"`
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used when a request does not name one.
+const DefaultCurrency = "USD"
+
+var (
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+	ErrInvalidAmount       = errors.New("invalid amount")
+)
+
+// Currency describes how an ISO 4217 currency is written.
+type Currency struct {
+	Code     string
+	Exponent int    // Digits after the decimal point; 2 means cents
+	Symbol   string // Written before the amount
+}
+
+// Currencies are the ISO 4217 currencies amounts may be given in.
+var Currencies = map[string]Currency{
+	"AUD": {Code: "AUD", Exponent: 2, Symbol: "A$"},
+	"CAD": {Code: "CAD", Exponent: 2, Symbol: "CA$"},
+	"CHF": {Code: "CHF", Exponent: 2, Symbol: "CHF "},
+	"EUR": {Code: "EUR", Exponent: 2, Symbol: "€"},
+	"GBP": {Code: "GBP", Exponent: 2, Symbol: "£"},
+	"INR": {Code: "INR", Exponent: 2, Symbol: "₹"},
+	"JPY": {Code: "JPY", Exponent: 0, Symbol: "¥"},
+	"KWD": {Code: "KWD", Exponent: 3, Symbol: "KWD "},
+	"PKR": {Code: "PKR", Exponent: 2, Symbol: "Rs "},
+	"USD": {Code: "USD", Exponent: 2, Symbol: "$"},
+}
+
+// Money is an amount in the smallest unit of its currency, so 1050 USD is
+// $10.50. Keeping integers avoids float rounding in sums and comparisons.
+//
+// In JSON the amount is a decimal string in major units, e.g.
+// {"amount": "10.50", "currency": "USD"}, which survives clients that parse
+// numbers as floats. Numbers are accepted on input.
+type Money struct {
+	Amount   int64  `gorm:"column:amount" json:"-"`
+	Currency string `gorm:"column:currency;size:3" json:"-"`
+}
+
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	currency, ok := Currencies[m.Currency]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedCurrency, m.Currency)
+	}
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{formatDecimal(m.Amount, currency.Exponent), m.Currency})
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw moneyJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	code := strings.ToUpper(strings.TrimSpace(raw.Currency))
+	if code == "" {
+		code = DefaultCurrency
+	}
+	currency, ok := Currencies[code]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnsupportedCurrency, raw.Currency)
+	}
+
+	text := strings.TrimSpace(string(raw.Amount))
+	if text == "" || text == "null" {
+		*m = Money{Currency: code}
+		return nil
+	}
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(raw.Amount, &text); err != nil {
+			return err
+		}
+	}
+	amount, err := ParseAmount(text, currency)
+	if err != nil {
+		return err
+	}
+	*m = Money{Amount: amount, Currency: code}
+	return nil
+}
+
+// ParseAmount converts a decimal string in major units, such as "10.5", to
+// minor units without going through a float. More decimal places than the
+// currency has is an error rather than being rounded away.
+func ParseAmount(text string, currency Currency) (int64, error) {
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(text, "-")
+	whole, frac, hasFrac := strings.Cut(text, ".")
+	if whole == "" || (hasFrac && frac == "") || len(frac) > currency.Exponent {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, text)
+	}
+	frac += strings.Repeat("0", currency.Exponent-len(frac))
+	amount, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil || strings.ContainsAny(whole+frac, "+-") {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, text)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// String formats the amount for people, e.g. "$1,234.50" or "¥1,235".
+func (m Money) String() string {
+	currency, ok := Currencies[m.Currency]
+	if !ok {
+		return formatDecimal(m.Amount, 0) + " " + m.Currency
+	}
+	text := formatDecimal(m.Amount, currency.Exponent)
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	whole, frac, hasFrac := strings.Cut(text, ".")
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	if hasFrac {
+		whole += "." + frac
+	}
+	return sign + currency.Symbol + whole
+}
+
+func formatDecimal(amount int64, exponent int) string {
+	sign := ""
+	// Work with the magnitude as uint64 so math.MinInt64 does not overflow.
+	magnitude := uint64(amount)
+	if amount < 0 {
+		sign = "-"
+		magnitude = uint64(-(amount + 1)) + 1
+	}
+	digits := strconv.FormatUint(magnitude, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	split := len(digits) - exponent
+	return sign + digits[:split] + "." + digits[split:]
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMoneyJSON tests that money round-trips through JSON without floats
+func TestMoneyJSON(t *testing.T) {
+	for _, m := range []Money{
+		{Amount: 1050, Currency: "USD"},
+		{Amount: -5, Currency: "EUR"},
+		{Amount: 1235, Currency: "JPY"},
+		{Amount: 9007199254740993, Currency: "USD"}, // not exact as a float64
+		{Amount: 1, Currency: "KWD"},
+	} {
+		b, err := json.Marshal(m)
+		assert.NoError(t, err)
+		var back Money
+		assert.NoError(t, json.Unmarshal(b, &back), string(b))
+		assert.Equal(t, m, back, string(b))
+	}
+
+	b, _ := json.Marshal(Money{Amount: 1050, Currency: "USD"})
+	assert.JSONEq(t, `{"amount": "10.50", "currency": "USD"}`, string(b))
+
+	var m Money
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": 10.5}`), &m))
+	assert.Equal(t, Money{Amount: 1050, Currency: DefaultCurrency}, m)
+
+	for _, bad := range []string{`{"amount": "1.234", "currency": "USD"}`, `{"amount": "1.5", "currency": "JPY"}`,
+		`{"amount": "1e3"}`, `{"amount": "+1"}`, `{"amount": "1.", "currency": "USD"}`, `{"amount": "1", "currency": "ABC"}`} {
+		assert.Error(t, json.Unmarshal([]byte(bad), &m), bad)
+	}
+}
+
+// TestMoneyString tests per-currency formatting
+func TestMoneyString(t *testing.T) {
+	assert.Equal(t, "$1,234.50", Money{Amount: 123450, Currency: "USD"}.String())
+	assert.Equal(t, "$0.05", Money{Amount: 5, Currency: "USD"}.String())
+	assert.Equal(t, "-€12.00", Money{Amount: -1200, Currency: "EUR"}.String())
+	assert.Equal(t, "¥1,235", Money{Amount: 1235, Currency: "JPY"}.String())
+	assert.Equal(t, "KWD 1.000", Money{Amount: 1000, Currency: "KWD"}.String())
+	assert.Equal(t, "$1,000,000.00", Money{Amount: 100000000, Currency: "USD"}.String())
+}
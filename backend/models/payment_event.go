@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// PaymentEvent is a webhook delivery from a payment provider, stored as
+// received. IntentID and Status are parsed out when it arrives; the worker
+// applies Status to the matching donation.
+type PaymentEvent struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Provider    string     `json:"provider"`
+	EventID     string     `json:"event_id"` // The provider's ID, used to drop redeliveries
+	EventType   string     `json:"event_type"`
+	IntentID    string     `json:"intent_id"`
+	Status      string     `json:"status"` // Payment status the event moves to; empty if irrelevant
+	Payload     string     `json:"payload"`
+	ReceivedAt  time.Time  `gorm:"autoCreateTime" json:"received_at"`
+	ProcessedAt *time.Time `json:"processed_at"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+}
@@ -1,21 +1,91 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Funding modes. Flexible campaigns charge backers straight away and keep
+// whatever they raise; all-or-nothing campaigns only authorize pledges and
+// charge them if the goal is met when the campaign closes.
+const (
+	FundingFlexible     = "flexible"
+	FundingAllOrNothing = "all_or_nothing"
+)
+
+// Funding results, recorded when a campaign closes.
+const (
+	FundingFunded   = "funded"
+	FundingUnfunded = "unfunded"
+)
 
 type Project struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Goal        float64   `json:"goal"`
-	StartDate   time.Time `json:"start_date"`
-	EndDate     time.Time `json:"end_date"`
-	UserID      uint      `json:"user_id"` // Creator of the project
+	ID                  uint           `gorm:"primaryKey" json:"id"`
+	Title               string         `json:"title"`
+	Slug                string         `json:"slug"`
+	Description         string         `json:"description"`
+	Goal                Money          `gorm:"embedded;embeddedPrefix:goal_" json:"goal"` // Its currency is the project's base currency
+	StartDate           time.Time      `json:"start_date"`
+	EndDate             time.Time      `json:"end_date"`
+	UserID              uint           `json:"user_id"` // Creator of the project
+	CategoryID          *uint          `json:"category_id"`
+	CommentsBackersOnly bool           `json:"comments_backers_only"` // Only backers may comment
+	FundingMode         string         `gorm:"default:flexible" json:"funding_mode"`
+	ClosedAt            *time.Time     `json:"closed_at,omitempty"`      // When the campaign was closed out after its end date
+	FundingResult       string         `json:"funding_result,omitempty"` // Whether the goal was met, once closed
+	Version             int            `gorm:"default:1" json:"version"` // Bumped on every update
+	ModerationStatus    string         `gorm:"default:active" json:"-"`
+	CancelledAt         *time.Time     `json:"cancelled_at,omitempty"` // Set when the creator calls the campaign off; its donations are refunded
+	DeletedAt           gorm.DeletedAt `gorm:"index" json:"-"`
+	Tags                []Tag          `gorm:"many2many:project_tags;" json:"tags,omitempty"`
+	Media               []ProjectMedia `json:"media,omitempty"`
+}
+
+// IsDraft reports whether the project has not launched yet. Drafts are only
+// visible to their creator, admins and holders of a preview link.
+func (p Project) IsDraft(now time.Time) bool {
+	return p.StartDate.After(now)
 }
 
 type CreateProject struct {
-	Title       string    `json:"title"`
+	Title       string    `json:"title" binding:"notblank,max=255"`
+	Slug        string    `json:"slug"` // Generated from Title when empty
 	Description string    `json:"description"`
-	Goal        float64   `json:"goal"`
-	StartDate   time.Time `json:"start_date"`
-	EndDate     time.Time `json:"end_date"`
+	Goal        Money     `json:"goal" binding:"gt=0"` // Its currency becomes the base currency
+	StartDate   time.Time `json:"start_date" binding:"required"`
+	EndDate     time.Time `json:"end_date" binding:"required,gtfield=StartDate"`
+	CategoryID  *uint     `json:"category_id"`
+	FundingMode string    `json:"funding_mode" binding:"omitempty,oneof=flexible all_or_nothing"` // Defaults to flexible
+}
+
+// UpdateProject replaces a project's details. The category and the
+// comment setting are kept when left out; clear the category with PATCH.
+type UpdateProject struct {
+	Title               string    `json:"title" binding:"notblank,max=255"`
+	Slug                string    `json:"slug"` // Kept when empty
+	Description         string    `json:"description"`
+	Goal                Money     `json:"goal" binding:"gt=0"`
+	StartDate           time.Time `json:"start_date" binding:"required"`
+	EndDate             time.Time `json:"end_date" binding:"required,gtfield=StartDate"`
+	CategoryID          *uint     `json:"category_id"`
+	CommentsBackersOnly *bool     `json:"comments_backers_only"`
+	FundingMode         string    `json:"funding_mode" binding:"omitempty,oneof=flexible all_or_nothing"` // Kept when empty
+}
+
+// ProjectFilter narrows ListProjects results. Zero values mean "no filter".
+type ProjectFilter struct {
+	CategoryIDs []uint
+	Tag         string
+}
+
+// ProjectSlugHistory remembers a slug a project used to have.
+type ProjectSlugHistory struct {
+	Slug      string    `gorm:"primaryKey" json:"slug"`
+	ProjectID uint      `json:"project_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (ProjectSlugHistory) TableName() string {
+	return "project_slug_history"
 }
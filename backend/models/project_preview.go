@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// ProjectPreview is a shareable link that lets anyone holding its token view
+// a project before it launches. The token itself is not stored; it is
+// signed over the preview's ID and expiry and checked against this row so
+// the owner can revoke it.
+type ProjectPreview struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	ProjectID uint       `json:"project_id"`
+	UserID    uint       `json:"user_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	// Token and URL are only returned when the preview is created.
+	Token string `gorm:"-" json:"token,omitempty"`
+	URL   string `gorm:"-" json:"url,omitempty"`
+}
+
+type CreateProjectPreview struct {
+	ExpiresInHours int `json:"expires_in_hours"` // Defaults to 72, at most 720
+}
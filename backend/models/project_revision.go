@@ -0,0 +1,100 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ProjectRevision records the state of a project's editable fields after
+// each save, so backers can see what changed after they pledged.
+type ProjectRevision struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`
+	ProjectID     uint            `json:"project_id"`
+	Version       int             `json:"version"`
+	UserID        uint            `json:"user_id"` // Who made the change
+	Snapshot      ProjectSnapshot `json:"snapshot"`
+	ChangedFields StringList      `json:"changed_fields"`
+	CreatedAt     time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ProjectSnapshot is the user-visible content of a project at one version.
+// It is stored as JSON text.
+type ProjectSnapshot struct {
+	Title               string    `json:"title"`
+	Description         string    `json:"description"`
+	Goal                Money     `json:"goal"`
+	StartDate           time.Time `json:"start_date"`
+	EndDate             time.Time `json:"end_date"`
+	CategoryID          *uint     `json:"category_id"`
+	CommentsBackersOnly bool      `json:"comments_backers_only"`
+	FundingMode         string    `json:"funding_mode"`
+}
+
+func SnapshotOf(p Project) ProjectSnapshot {
+	return ProjectSnapshot{
+		Title:               p.Title,
+		Description:         p.Description,
+		Goal:                p.Goal,
+		StartDate:           p.StartDate.UTC(),
+		EndDate:             p.EndDate.UTC(),
+		CategoryID:          p.CategoryID,
+		CommentsBackersOnly: p.CommentsBackersOnly,
+		FundingMode:         p.FundingMode,
+	}
+}
+
+func (s ProjectSnapshot) Value() (driver.Value, error) {
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+func (s *ProjectSnapshot) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	case []byte:
+		return json.Unmarshal(v, s)
+	}
+	return errors.New("unsupported type for ProjectSnapshot")
+}
+
+// StringList is stored as a comma-separated TEXT column.
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	return strings.Join(l, ","), nil
+}
+
+func (l *StringList) Scan(value interface{}) error {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return errors.New("unsupported type for StringList")
+	}
+	*l = StringList{}
+	if s != "" {
+		*l = strings.Split(s, ",")
+	}
+	return nil
+}
+
+// FieldChange is one field's before/after values in a revision diff.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type RevisionDiff struct {
+	ProjectID   uint          `json:"project_id"`
+	FromVersion int           `json:"from_version"`
+	ToVersion   int           `json:"to_version"`
+	Changes     []FieldChange `json:"changes"`
+}
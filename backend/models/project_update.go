@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// ProjectUpdate is a progress post written by a project's creator. It becomes
+// visible once PublishAt has passed; backers are emailed at that point.
+type ProjectUpdate struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	ProjectID    uint       `json:"project_id"`
+	UserID       uint       `json:"user_id"`
+	Title        string     `json:"title"`
+	BodyMarkdown string     `json:"body_markdown,omitempty"`
+	BodyHTML     string     `gorm:"column:body_html" json:"body_html,omitempty"`
+	BackersOnly  bool       `json:"backers_only"`
+	PublishAt    time.Time  `json:"publish_at"`
+	NotifiedAt   *time.Time `json:"-"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Locked is set when a backers-only update is shown to someone who has
+	// not backed the project; the body is withheld.
+	Locked bool `gorm:"-" json:"locked,omitempty"`
+}
+
+type CreateProjectUpdate struct {
+	Title        string     `json:"title" binding:"required"`
+	BodyMarkdown string     `json:"body_markdown" binding:"required"`
+	BackersOnly  bool       `json:"backers_only"`
+	PublishAt    *time.Time `json:"publish_at"` // nil publishes immediately
+}
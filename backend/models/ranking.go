@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// FeaturedProject places a project in one of the admin-curated slots shown
+// at the top of the site. Slot 1 is shown first.
+type FeaturedProject struct {
+	Slot      int        `gorm:"primaryKey;autoIncrement:false" json:"slot"`
+	ProjectID uint       `json:"project_id"`
+	UserID    uint       `json:"user_id"`           // Admin who featured it
+	EndsAt    *time.Time `json:"ends_at,omitempty"` // nil keeps it featured until removed
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type SetFeaturedProject struct {
+	ProjectID uint       `json:"project_id" binding:"required"`
+	EndsAt    *time.Time `json:"ends_at"`
+}
+
+// TrendingStats is the recent activity a project's trending score is
+// computed from.
+type TrendingStats struct {
+	ProjectID    uint
+	Goal         int64 // Minor units
+	RecentAmount int64 // Donated within the velocity window, in minor units
+	NewBackers   int64 // First-time backers within the growth window
+	NewFollows   int64 // Follows within the growth window
+}
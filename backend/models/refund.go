@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Refund status. Refunds are saved as pending and sent to the payment
+// provider by a worker.
+const (
+	RefundPending   = "pending"
+	RefundSucceeded = "succeeded"
+	RefundFailed    = "failed"
+)
+
+// Refund returns part or all of a donation to the donor. Amount is in the
+// donation's currency; BaseAmount is the same amount in the project's base
+// currency at the donation's exchange rate.
+type Refund struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	DonationID       uint       `json:"donation_id"`
+	Amount           Money      `gorm:"embedded" json:"amount"`
+	BaseAmount       int64      `json:"-"`
+	Reason           string     `json:"reason,omitempty"`
+	RequestedBy      *uint      `json:"requested_by,omitempty"` // Nil for automatic refunds
+	Status           string     `json:"status"`
+	FailureReason    string     `json:"failure_reason,omitempty"`
+	ProviderRefundID string     `json:"-"`
+	CreatedAt        time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	ProcessedAt      *time.Time `json:"processed_at,omitempty"`
+}
+
+// CreateRefund is the request body for a refund.
+type CreateRefund struct {
+	// Amount must be in the donation's currency. Everything not yet
+	// refunded is refunded when it is omitted.
+	Amount *Money `json:"amount"`
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// CancelProject is the request body for cancelling a project.
+type CancelProject struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Subscription status. Active plans are billed every cycle; a failed charge
+// makes the plan past due while it is retried, and it is cancelled if the
+// retries fail too. Paused plans skip the cycles that fall while paused.
+const (
+	SubscriptionActive    = "active"
+	SubscriptionPastDue   = "past_due"
+	SubscriptionPaused    = "paused"
+	SubscriptionCancelled = "cancelled"
+)
+
+// Billing intervals.
+const (
+	IntervalMonthly = "monthly"
+	IntervalYearly  = "yearly"
+)
+
+// Subscription is a recurring donation to a project. Every cycle the
+// billing scheduler creates a Donation for Amount, which is charged like
+// any other donation.
+type Subscription struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `json:"user_id"`
+	ProjectID uint      `json:"project_id"`
+	Amount    Money     `gorm:"embedded" json:"amount"`
+	Interval  string    `json:"interval"`
+	StartDate time.Time `json:"start_date"` // First charge; later cycles fall on the same day of the month
+	Cycle     int       `json:"-"`          // Index of the next cycle to bill, counted from StartDate
+	// NextBillingAt is when the next cycle is billed. RetryAt is set while
+	// a failed charge waits to be retried.
+	NextBillingAt     time.Time  `json:"next_billing_at"`
+	RetryAt           *time.Time `json:"retry_at,omitempty"`
+	Status            string     `json:"status"`
+	PaymentMethod     string     `json:"-"` // Processor's token for the donor's card
+	PaymentCustomer   string     `json:"-"` // Who the card is saved under for off-session charges
+	PendingDonationID *uint      `json:"-"` // Donation of the charge in progress
+	FailedAttempts    int        `json:"failed_attempts"`
+	LastFailure       string     `json:"last_failure,omitempty"`
+	CancelledAt       *time.Time `json:"cancelled_at,omitempty"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// CreateSubscription is the request body for a recurring donation. The
+// project comes from the URL and the donor from the session.
+type CreateSubscription struct {
+	Amount   Money  `json:"amount" binding:"gt=0"`
+	Interval string `json:"interval" binding:"required,oneof=monthly yearly"`
+	// StartDate is when the first charge is made; now when omitted.
+	StartDate *time.Time `json:"start_date"`
+	// PaymentMethod is the processor's token for the donor's card. It is
+	// saved with the processor and charged every cycle.
+	PaymentMethod string `json:"payment_method" binding:"required"`
+}
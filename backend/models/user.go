@@ -1,10 +1,14 @@
 package models
 
+import "time"
+
 type User struct {
-	ID       uint   `gorm:"primaryKey" json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	ID       uint       `gorm:"primaryKey" json:"id"`
+	Username string     `json:"username"`
+	Email    string     `json:"email"`
+	Password string     `json:"password"`
+	IsAdmin  bool       `json:"is_admin"`
+	BannedAt *time.Time `json:"-"`
 }
 
 type LoginCredentials struct {
@@ -0,0 +1,18 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore persists uploaded files. Objects are private; clients reach them
+// through expiring signed URLs.
+type BlobStore interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Delete(ctx context.Context, key string) error
+	SignedURL(key string, expires time.Duration) (string, error)
+}
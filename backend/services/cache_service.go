@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"math"
 	"os"
 	"strconv"
 	"time"
@@ -33,6 +34,12 @@ func NewCacheService() *CacheService {
 	return &CacheService{client: client}
 }
 
+// Client returns the underlying Redis client so other Redis-backed
+// components can share its connection pool.
+func (s *CacheService) Client() *redis.Client {
+	return s.client
+}
+
 func (s *CacheService) Get(ctx context.Context, key string, value interface{}) error {
 	val, err := s.client.Get(ctx, key).Result()
 	if err != nil {
@@ -70,3 +77,52 @@ func (s *CacheService) InvalidateUserCache(userID uint) {
 		log.Printf("Error invalidating user cache: %v", err)
 	}
 }
+
+// emptySortedSetMarker is kept in every sorted set written by
+// ReplaceSortedSet, below all real members, so an empty ranking is still
+// stored rather than looking like a missing key. SortedSetRange leaves it
+// out.
+const emptySortedSetMarker = ""
+
+// ReplaceSortedSet atomically swaps the contents of a sorted set, so readers
+// never see a half-written ranking. An empty ranking is stored too, and
+// reads back as no members rather than redis.Nil.
+func (s *CacheService) ReplaceSortedSet(ctx context.Context, key string, scores map[string]float64, expiration time.Duration) error {
+	members := make([]*redis.Z, 0, len(scores)+1)
+	members = append(members, &redis.Z{Score: math.Inf(-1), Member: emptySortedSetMarker})
+	for member, score := range scores {
+		members = append(members, &redis.Z{Score: score, Member: member})
+	}
+	tmp := key + ":building"
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, tmp)
+	pipe.ZAdd(ctx, tmp, members...)
+	pipe.Rename(ctx, tmp, key)
+	pipe.Expire(ctx, key, expiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SortedSetRange returns members of a sorted set from the highest score
+// down, between the zero-based ranks start and stop inclusive. A missing key
+// is reported as redis.Nil.
+func (s *CacheService) SortedSetRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	exists, err := s.client.Exists(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		return nil, redis.Nil
+	}
+	members, err := s.client.ZRevRange(ctx, key, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	ranked := members[:0]
+	for _, member := range members {
+		if member != emptySortedSetMarker {
+			ranked = append(ranked, member)
+		}
+	}
+	return ranked, nil
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxTagLength = 64
+
+var (
+	ErrInvalidTag      = errors.New("invalid tag")
+	ErrCategoryCycle   = errors.New("category cannot be its own ancestor")
+	ErrMergeIntoItself = errors.New("cannot merge a tag into itself")
+	tagInvalidChars    = regexp.MustCompile(`[^a-z0-9-]+`)
+	tagRepeatedHyphens = regexp.MustCompile(`-{2,}`)
+	slugInvalidChars   = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+type CategoryService struct {
+	db *gorm.DB
+}
+
+func NewCategoryService(db *gorm.DB) *CategoryService {
+	return &CategoryService{db: db}
+}
+
+// NormalizeTag lowercases a free-form tag and folds whitespace and
+// punctuation into single hyphens, so "Board Games" and "board-games"
+// end up as the same tag.
+func NormalizeTag(raw string) (string, error) {
+	tag := strings.ToLower(strings.TrimSpace(raw))
+	tag = strings.Join(strings.Fields(tag), "-")
+	tag = tagInvalidChars.ReplaceAllString(tag, "-")
+	tag = tagRepeatedHyphens.ReplaceAllString(tag, "-")
+	tag = strings.Trim(tag, "-")
+	if tag == "" || len(tag) > maxTagLength {
+		return "", ErrInvalidTag
+	}
+	return tag, nil
+}
+
+// Slugify turns a display name into a URL-safe identifier.
+func Slugify(name string) string {
+	return strings.Trim(slugInvalidChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+func (s *CategoryService) CreateCategory(category *models.Category) error {
+	if category.Slug == "" {
+		category.Slug = Slugify(category.Name)
+	}
+	return s.db.Create(category).Error
+}
+
+func (s *CategoryService) UpdateCategory(category *models.Category) error {
+	if category.Slug == "" {
+		category.Slug = Slugify(category.Name)
+	}
+	if category.ParentID != nil {
+		all, err := s.allCategories()
+		if err != nil {
+			return err
+		}
+		for _, id := range descendantIDs(all, category.ID) {
+			if id == *category.ParentID {
+				return ErrCategoryCycle
+			}
+		}
+	}
+	return s.db.Model(category).Select("name", "slug", "parent_id").Updates(category).Error
+}
+
+func (s *CategoryService) DeleteCategory(id uint64) error {
+	return s.db.Delete(&models.Category{}, id).Error
+}
+
+// ListCategories returns the category tree. Each node's ProjectCount includes
+// projects filed under any of its subcategories, counting only those
+// ListProjects would show.
+func (s *CategoryService) ListCategories() ([]models.Category, error) {
+	all, err := s.allCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		CategoryID uint
+		Count      int64
+	}
+	err = s.db.Model(&models.Project{}).
+		Select("category_id, COUNT(*) AS count").
+		Scopes(listedProjects(time.Now())).
+		Where("category_id IS NOT NULL").
+		Group("category_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	direct := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		direct[row.CategoryID] = row.Count
+	}
+
+	return buildCategoryTree(all, direct), nil
+}
+
+// DescendantIDs returns the ID of the category and every category below it.
+func (s *CategoryService) DescendantIDs(id uint) ([]uint, error) {
+	all, err := s.allCategories()
+	if err != nil {
+		return nil, err
+	}
+	return descendantIDs(all, id), nil
+}
+
+func (s *CategoryService) allCategories() ([]models.Category, error) {
+	var categories []models.Category
+	err := s.db.Order("name").Find(&categories).Error
+	return categories, err
+}
+
+func descendantIDs(all []models.Category, root uint) []uint {
+	children := make(map[uint][]uint)
+	for _, c := range all {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c.ID)
+		}
+	}
+	ids := []uint{root}
+	seen := map[uint]bool{root: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids
+}
+
+func buildCategoryTree(all []models.Category, direct map[uint]int64) []models.Category {
+	children := make(map[uint][]models.Category)
+	var roots []models.Category
+	for _, c := range all {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+		} else {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+
+	var fill func(c *models.Category) int64
+	fill = func(c *models.Category) int64 {
+		c.ProjectCount = direct[c.ID]
+		c.Children = children[c.ID]
+		for i := range c.Children {
+			c.ProjectCount += fill(&c.Children[i])
+		}
+		return c.ProjectCount
+	}
+	for i := range roots {
+		fill(&roots[i])
+	}
+	return roots
+}
+
+func (s *CategoryService) ListTags() ([]models.Tag, error) {
+	var tags []models.Tag
+	err := s.db.Order("name").Find(&tags).Error
+	return tags, err
+}
+
+// SetProjectTags replaces the tags on a project, creating any tags that do
+// not exist yet.
+func (s *CategoryService) SetProjectTags(projectID uint64, names []string) ([]models.Tag, error) {
+	seen := make(map[string]bool)
+	var tags []models.Tag
+	for _, raw := range names {
+		name, err := NormalizeTag(raw)
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, models.Tag{Name: name})
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for i := range tags {
+			err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags[i]).Error
+			if err != nil {
+				return err
+			}
+			if err := tx.Where("name = ?", tags[i].Name).First(&tags[i]).Error; err != nil {
+				return err
+			}
+		}
+		project := models.Project{ID: uint(projectID)}
+		return tx.Model(&project).Association("Tags").Replace(tags)
+	})
+	return tags, err
+}
+
+// MergeTags retags every project carrying one of the source tags with the
+// target tag and then removes the source tags.
+func (s *CategoryService) MergeTags(sourceIDs []uint, targetID uint) error {
+	for _, id := range sourceIDs {
+		if id == targetID {
+			return ErrMergeIntoItself
+		}
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.Tag{}, targetID).Error; err != nil {
+			return err
+		}
+		err := tx.Exec(`INSERT INTO project_tags (project_id, tag_id)
+			SELECT DISTINCT project_id, ? FROM project_tags WHERE tag_id IN ?
+			ON CONFLICT DO NOTHING`, targetID, sourceIDs).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM project_tags WHERE tag_id IN ?", sourceIDs).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Tag{}, sourceIDs).Error
+	})
+}
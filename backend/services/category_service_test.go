@@ -0,0 +1,77 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNormalizeTag tests that equivalent spellings collapse to one tag
+func TestNormalizeTag(t *testing.T) {
+	cases := map[string]string{
+		"Board Games":       "board-games",
+		"  board--games  ":  "board-games",
+		"Sci-Fi & Fantasy!": "sci-fi-fantasy",
+		"3D_Printing":       "3d-printing",
+	}
+	for raw, expected := range cases {
+		tag, err := NormalizeTag(raw)
+		assert.NoError(t, err, raw)
+		assert.Equal(t, expected, tag, raw)
+	}
+
+	_, err := NormalizeTag(" -- ")
+	assert.ErrorIs(t, err, ErrInvalidTag)
+}
+
+// TestBuildCategoryTree tests that project counts roll up to parent categories
+func TestBuildCategoryTree(t *testing.T) {
+	games := uint(1)
+	board := uint(2)
+	all := []models.Category{
+		{ID: games, Name: "Games"},
+		{ID: board, Name: "Board Games", ParentID: &games},
+		{ID: 3, Name: "Dice", ParentID: &board},
+		{ID: 4, Name: "Music"},
+	}
+	direct := map[uint]int64{1: 1, 2: 2, 3: 4, 4: 5}
+
+	tree := buildCategoryTree(all, direct)
+
+	assert.Len(t, tree, 2)
+	assert.Equal(t, int64(7), tree[0].ProjectCount)
+	assert.Equal(t, int64(6), tree[0].Children[0].ProjectCount)
+	assert.Equal(t, int64(5), tree[1].ProjectCount)
+	assert.ElementsMatch(t, []uint{1, 2, 3}, descendantIDs(all, games))
+}
+
+// TestListCategories_CountsListedProjects tests that category counts match the projects ListProjects shows
+func TestListCategories_CountsListedProjects(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	category := models.Category{Name: "Games"}
+	require.NoError(t, NewCategoryService(db).CreateCategory(&category))
+
+	listed := createTestProject(t, db, owner)
+	draft := createTestProject(t, db, owner)
+	hidden := createTestProject(t, db, owner)
+	deleted := createTestProject(t, db, owner)
+	for _, project := range []models.Project{listed, draft, hidden, deleted} {
+		require.NoError(t, db.Model(&project).Update("category_id", category.ID).Error)
+	}
+	require.NoError(t, db.Model(&draft).Update("start_date", time.Now().Add(24*time.Hour)).Error)
+	require.NoError(t, db.Model(&hidden).Update("moderation_status", models.ModerationHidden).Error)
+	require.NoError(t, db.Delete(&deleted).Error)
+
+	categories, err := NewCategoryService(db).ListCategories()
+	require.NoError(t, err)
+	require.Len(t, categories, 1)
+	projects, err := NewProjectService(db).ListProjects(models.ProjectFilter{CategoryIDs: []uint{category.ID}})
+	require.NoError(t, err)
+	require.Len(t, projects, 1)
+	assert.Equal(t, listed.ID, projects[0].ID)
+	assert.Equal(t, int64(len(projects)), categories[0].ProjectCount)
+}
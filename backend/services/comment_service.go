@@ -0,0 +1,217 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	commentRateLimit  = 5
+	commentRateWindow = 1 * time.Minute
+)
+
+var (
+	ErrCommentRateLimited  = errors.New("too many comments, please slow down")
+	ErrCommentsBackersOnly = errors.New("only backers can comment on this project")
+	ErrCommentDeleted      = errors.New("comment has been deleted")
+	ErrInvalidParent       = errors.New("parent comment is not in this thread")
+)
+
+type CommentService struct {
+	db              *gorm.DB
+	donationService *DonationService
+}
+
+func NewCommentService(db *gorm.DB, donationService *DonationService) *CommentService {
+	return &CommentService{db: db, donationService: donationService}
+}
+
+// CreateComment enforces the per-user rate limit and the project's
+// backers-only setting before storing the comment. Replies inherit the
+// thread of their parent.
+func (s *CommentService) CreateComment(project models.Project, comment *models.Comment) error {
+	var recent int64
+	err := s.db.Model(&models.Comment{}).
+		Where("user_id = ? AND created_at > ?", comment.UserID, time.Now().Add(-commentRateWindow)).
+		Count(&recent).Error
+	if err != nil {
+		return err
+	}
+	if recent >= commentRateLimit {
+		return ErrCommentRateLimited
+	}
+
+	if project.CommentsBackersOnly && comment.UserID != project.UserID {
+		backer, err := s.donationService.IsBacker(uint64(project.ID), comment.UserID)
+		if err != nil {
+			return err
+		}
+		if !backer {
+			return ErrCommentsBackersOnly
+		}
+	}
+
+	if comment.ParentID != nil {
+		var parent models.Comment
+		if err := s.db.First(&parent, *comment.ParentID).Error; err != nil {
+			return ErrInvalidParent
+		}
+		if parent.ProjectID != comment.ProjectID || !sameUpdate(parent.UpdateID, comment.UpdateID) {
+			return ErrInvalidParent
+		}
+		if parent.DeletedAt != nil {
+			return ErrCommentDeleted
+		}
+		comment.RootID = parent.RootID
+		if comment.RootID == nil {
+			comment.RootID = &parent.ID
+		}
+	}
+
+	if err := s.db.Create(comment).Error; err != nil {
+		return err
+	}
+	comment.IsCreator = comment.UserID == project.UserID
+	comment.Replies = []models.Comment{}
+	return nil
+}
+
+func (s *CommentService) GetComment(id uint64) (models.Comment, error) {
+	var comment models.Comment
+	err := s.db.First(&comment, id).Error
+	return comment, err
+}
+
+func (s *CommentService) EditComment(comment *models.Comment, body string) error {
+	if comment.DeletedAt != nil {
+		return ErrCommentDeleted
+	}
+	comment.Body = body
+	return s.db.Model(comment).Update("body", body).Error
+}
+
+// DeleteComment soft-deletes the comment so its replies stay attached to the
+// thread; it is rendered as a "[deleted]" placeholder.
+func (s *CommentService) DeleteComment(comment *models.Comment) error {
+	if comment.DeletedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	comment.DeletedAt = &now
+	return s.db.Model(comment).Update("deleted_at", now).Error
+}
+
+// ListComments returns a page of top-level comments on the project (or on
+// one of its updates when updateID is non-nil), oldest first, each with its
+// full reply tree.
+func (s *CommentService) ListComments(project models.Project, updateID *uint, page, perPage int) (models.CommentPage, error) {
+	result := models.CommentPage{Comments: []models.Comment{}, Page: page, PerPage: perPage}
+
+	query := s.db.Model(&models.Comment{}).Where("project_id = ? AND parent_id IS NULL", project.ID)
+	if updateID != nil {
+		query = query.Where("update_id = ?", *updateID)
+	} else {
+		query = query.Where("update_id IS NULL")
+	}
+	if err := query.Count(&result.Total).Error; err != nil {
+		return result, err
+	}
+
+	var roots []models.Comment
+	err := query.Order("created_at, id").Offset((page - 1) * perPage).Limit(perPage).Find(&roots).Error
+	if err != nil || len(roots) == 0 {
+		return result, err
+	}
+
+	rootIDs := make([]uint, len(roots))
+	for i, root := range roots {
+		rootIDs[i] = root.ID
+	}
+	var replies []models.Comment
+	if err := s.db.Where("root_id IN ?", rootIDs).Order("created_at, id").Find(&replies).Error; err != nil {
+		return result, err
+	}
+
+	all := append(roots, replies...)
+	usernames, err := s.usernames(all)
+	if err != nil {
+		return result, err
+	}
+	for i := range all {
+		presentComment(&all[i], project, usernames)
+	}
+	result.Comments = buildCommentTree(all)
+	return result, nil
+}
+
+func (s *CommentService) usernames(comments []models.Comment) (map[uint]string, error) {
+	ids := make([]uint, 0, len(comments))
+	for _, c := range comments {
+		ids = append(ids, c.UserID)
+	}
+	var users []models.User
+	if err := s.db.Select("id", "username").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(users))
+	for _, u := range users {
+		names[u.ID] = u.Username
+	}
+	return names, nil
+}
+
+// presentComment fills in the display-only fields and blanks out deleted comments.
+func presentComment(comment *models.Comment, project models.Project, usernames map[uint]string) {
+	comment.Replies = []models.Comment{}
+	if comment.DeletedAt != nil {
+		comment.Deleted = true
+		comment.Body = models.DeletedCommentBody
+		comment.UserID = 0
+		return
+	}
+	if comment.ModerationStatus == models.ModerationHidden {
+		comment.Body = models.RemovedCommentBody
+	}
+	comment.Username = usernames[comment.UserID]
+	comment.IsCreator = comment.UserID == project.UserID
+}
+
+// buildCommentTree nests replies under their parents. comments must be in
+// display order; the returned slice holds only top-level comments.
+func buildCommentTree(comments []models.Comment) []models.Comment {
+	children := make(map[uint][]int)
+	var roots []int
+	for i, c := range comments {
+		if c.ParentID == nil {
+			roots = append(roots, i)
+		} else {
+			children[*c.ParentID] = append(children[*c.ParentID], i)
+		}
+	}
+
+	var build func(i int) models.Comment
+	build = func(i int) models.Comment {
+		c := comments[i]
+		c.Replies = []models.Comment{}
+		for _, child := range children[c.ID] {
+			c.Replies = append(c.Replies, build(child))
+		}
+		return c
+	}
+
+	tree := make([]models.Comment, 0, len(roots))
+	for _, i := range roots {
+		tree = append(tree, build(i))
+	}
+	return tree
+}
+
+func sameUpdate(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBuildCommentTree tests that replies nest under their parents and deleted comments keep their place
+func TestBuildCommentTree(t *testing.T) {
+	one, two := uint(1), uint(2)
+	deletedAt := time.Now()
+	project := models.Project{ID: 9, UserID: 7}
+	comments := []models.Comment{
+		{ID: 1, UserID: 3, Body: "first"},
+		{ID: 4, UserID: 7, Body: "second"},
+		{ID: 2, UserID: 5, Body: "gone", ParentID: &one, RootID: &one, DeletedAt: &deletedAt},
+		{ID: 3, UserID: 7, Body: "reply to deleted", ParentID: &two, RootID: &one},
+	}
+	usernames := map[uint]string{3: "alice", 5: "bob", 7: "creator"}
+	for i := range comments {
+		presentComment(&comments[i], project, usernames)
+	}
+
+	tree := buildCommentTree(comments)
+
+	assert.Len(t, tree, 2)
+	assert.Equal(t, "alice", tree[0].Username)
+	assert.True(t, tree[1].IsCreator)
+
+	deleted := tree[0].Replies[0]
+	assert.True(t, deleted.Deleted)
+	assert.Equal(t, models.DeletedCommentBody, deleted.Body)
+	assert.Empty(t, deleted.Username)
+	assert.Equal(t, uint(0), deleted.UserID)
+
+	assert.Len(t, deleted.Replies, 1)
+	assert.Equal(t, "reply to deleted", deleted.Replies[0].Body)
+	assert.True(t, deleted.Replies[0].IsCreator)
+}
@@ -1,43 +1,390 @@
 package services
 
 import (
+	"context"
+	"crowdfund/backend/jobs"
 	"crowdfund/backend/models"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
-	"sync"
+	"strconv"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrProjectNotAcceptingDonations = errors.New("project is not accepting donations")
+
+// DonationTask is the payload of a ChargeDonationJob. The card token is
+// kept on the donation rather than here, so it does not show up in job
+// listings or dead letters.
+type DonationTask struct {
+	DonationID uint `json:"donation_id"`
+}
+
+// ChargeDonationJob charges a queued donation.
+const ChargeDonationJob jobs.Kind[DonationTask] = "donations"
+
 type DonationService struct {
-	db            *gorm.DB
-	emailService  *EmailService
-	donationTasks chan<- models.Donation
+	db              *gorm.DB
+	emailService    *EmailService
+	rateProvider    RateProvider
+	paymentProvider PaymentProvider
+	queue           jobs.Queue
 }
 
-func NewDonationService(db *gorm.DB, emailService *EmailService, donationTasks chan<- models.Donation) *DonationService {
-	return &DonationService{db: db, emailService: emailService, donationTasks: donationTasks}
+func NewDonationService(db *gorm.DB, emailService *EmailService, rateProvider RateProvider, paymentProvider PaymentProvider, queue jobs.Queue) *DonationService {
+	return &DonationService{db: db, emailService: emailService, rateProvider: rateProvider, paymentProvider: paymentProvider, queue: queue}
 }
 
-func (s *DonationService) CreateDonation(donation models.Donation) error {
-	s.donationTasks <- donation // Send to worker pool
-	return nil
+// CreateDonation saves the donation as queued, along with the processor's
+// token for the donor's card, and enqueues a job to charge it. With the
+// Postgres job queue both are written in one transaction, so a donation is
+// never saved without its job or lost in a restart. The project must exist
+// and its campaign must be running. Donations in another currency are
+// converted to the project's base currency at the current rate, which is
+// stored with the donation. The donor is charged in the currency they
+// chose.
+//
+// The returned donation carries the reference the client uses to follow
+// its status.
+func (s *DonationService) CreateDonation(ctx context.Context, donation models.Donation, paymentMethod string) (models.Donation, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		donation, err = s.createDonation(ctx, tx, donation, paymentMethod)
+		return err
+	})
+	return donation, err
 }
 
-func DonationWorker(id int, tasks <-chan models.Donation, wg *sync.WaitGroup, db *gorm.DB, emailService *EmailService) {
-	defer wg.Done()
-	for task := range tasks {
-		log.Printf("Worker %d processing donation: %v", id, task)
-		if err := db.Create(&task).Error; err != nil {
-			log.Printf("Error saving donation: %v", err)
-			continue
+// createDonation is CreateDonation within tx.
+func (s *DonationService) createDonation(ctx context.Context, tx *gorm.DB, donation models.Donation, paymentMethod string) (models.Donation, error) {
+	project, err := s.acceptingProject(tx, donation.ProjectID, time.Now())
+	if err != nil {
+		return donation, err
+	}
+	rate, err := s.rateProvider.Rate(ctx, donation.Amount.Currency, project.Goal.Currency)
+	if err != nil {
+		return donation, err
+	}
+	if donation.BaseAmount, err = ConvertMoney(donation.Amount, rate); err != nil {
+		return donation, err
+	}
+	donation.ExchangeRate = rate.Rate
+	donation.RateSource = rate.Source
+	donation.RateFetchedAt = rate.FetchedAt
+
+	if donation.Reference, err = newDonationReference(); err != nil {
+		return donation, err
+	}
+	donation.Status = models.DonationQueued
+	donation.PaymentProvider = s.paymentProvider.Name()
+	donation.PaymentMethod = paymentMethod
+	if err := tx.Create(&donation).Error; err != nil {
+		return donation, err
+	}
+	err = ChargeDonationJob.Enqueue(ctx, s.queue, tx, DonationTask{DonationID: donation.ID})
+	return donation, err
+}
+
+// GetDonationByReference looks a donation up by its client-visible
+// reference.
+func (s *DonationService) GetDonationByReference(reference string) (models.Donation, error) {
+	var donation models.Donation
+	err := s.db.Where("reference = ?", reference).First(&donation).Error
+	return donation, err
+}
+
+func newDonationReference() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "don_" + hex.EncodeToString(b), nil
+}
+
+// chargeDonation creates a payment intent for the donation, authorizes it
+// with the donor's payment method and captures it. Cards saved for the
+// donation are charged off-session. If capture fails the authorization is
+// released so the donor is not left with a hold. The donation's reference
+// is the idempotency key, so a retried charge gets the same intent back
+// and carries on from wherever the last attempt stopped.
+func chargeDonation(ctx context.Context, provider PaymentProvider, donation models.Donation) (PaymentIntent, error) {
+	metadata := map[string]string{
+		"donation":   donation.Reference,
+		"project_id": strconv.FormatUint(uint64(donation.ProjectID), 10),
+		"user_id":    strconv.FormatUint(uint64(donation.UserID), 10),
+	}
+	intent, err := provider.CreateIntent(ctx, donation.Amount, metadata, donation.Reference)
+	if err != nil {
+		return intent, err
+	}
+	if intent.Status == PaymentCreated {
+		var authorized PaymentIntent
+		if donation.PaymentCustomer != "" {
+			authorized, err = provider.AuthorizeOffSession(ctx, intent.ID, donation.PaymentCustomer, donation.PaymentMethod)
+		} else {
+			authorized, err = provider.Authorize(ctx, intent.ID, donation.PaymentMethod)
 		}
-		// Send email confirmation
-		emailService.SendDonationConfirmation(task)
+		if authorized.ID != "" {
+			intent = authorized
+		}
+		if err != nil {
+			return intent, err
+		}
+	}
+	if intent.Status == PaymentAuthorized {
+		captured, err := provider.Capture(ctx, intent.ID, 0)
+		if err != nil {
+			voided, voidErr := provider.Void(ctx, intent.ID)
+			if voidErr != nil {
+				log.Printf("Error voiding payment %s after failed capture: %v", intent.ID, voidErr)
+				return intent, err
+			}
+			return voided, err
+		}
+		intent = captured
+	}
+	if intent.Status != PaymentCaptured {
+		return intent, fmt.Errorf("%w: payment is %s", ErrPaymentState, intent.Status)
+	}
+	return intent, nil
+}
+
+func (s *DonationService) acceptingProject(tx *gorm.DB, projectID uint, now time.Time) (models.Project, error) {
+	var project models.Project
+	err := tx.Select("id", "start_date", "end_date", "goal_currency", "funding_mode", "cancelled_at").
+		Where("moderation_status = ?", models.ModerationActive).
+		First(&project, projectID).Error
+	if err != nil {
+		return project, err
+	}
+	if project.CancelledAt != nil || now.Before(project.StartDate) || !now.Before(project.EndDate) {
+		return project, ErrProjectNotAcceptingDonations
 	}
+	return project, nil
 }
 
-func (s *DonationService) GetDonationsByProjectID(projectID uint64) ([]models.Donation, error) {
+// ChargeHandler returns the handler for ChargeDonationJob. Donors are
+// emailed a confirmation once their donation succeeds or their pledge is
+// authorized.
+func (s *DonationService) ChargeHandler(workers int) jobs.Handler[DonationTask] {
+	return jobs.Handler[DonationTask]{
+		Workers: workers,
+		Run: func(ctx context.Context, task DonationTask) error {
+			donation, err := processDonation(ctx, s.db, s.paymentProvider, s.queue, task)
+			if err != nil {
+				return err
+			}
+			if donation.Status != models.DonationSucceeded && donation.Status != models.DonationAuthorized {
+				return nil
+			}
+			to, project, err := donationRecipient(s.db, donation.ID)
+			if err != nil {
+				log.Printf("Error loading donor for donation %d: %v", donation.ID, err)
+				return nil
+			}
+			if donation.Status == models.DonationSucceeded {
+				s.emailService.SendDonationConfirmation(to, project, donation)
+			} else {
+				s.emailService.SendPledgeConfirmation(to, project, donation)
+			}
+			return nil
+		},
+		OnDead:   failDonationJob,
+		OnReplay: requeueDonationJob,
+	}
+}
+
+// processDonation charges a queued donation and records the outcome. The
+// donation is marked processing first so clients polling it can see the
+// charge has started; a retried job finds it still processing and carries
+// on. A declined card is an outcome rather than an error; other failures
+// are returned so the job is retried. Donations to a cancelled project
+// fail without being charged. One whose charge was in flight when the
+// project was cancelled is refunded.
+//
+// Pledges to all-or-nothing projects are not charged or authorized here:
+// an authorization lapses after about a week, long before most campaigns
+// close. The card is saved instead and charged off-session at close-out.
+// A pledge saved after its campaign closed is settled straight away.
+func processDonation(ctx context.Context, db *gorm.DB, paymentProvider PaymentProvider, queue jobs.Queue, task DonationTask) (models.Donation, error) {
+	var donation models.Donation
+	if err := db.First(&donation, task.DonationID).Error; err != nil {
+		// The Redis queue can deliver the job before the donation commits.
+		return donation, err
+	}
+	if donation.Status != models.DonationQueued && donation.Status != models.DonationProcessing {
+		return donation, nil // Already charged
+	}
+	var project models.Project
+	if err := db.Unscoped().Select("id", "funding_mode", "cancelled_at").First(&project, donation.ProjectID).Error; err != nil {
+		return donation, err
+	}
+	if project.CancelledAt != nil {
+		err := db.Model(&donation).Updates(map[string]interface{}{"status": models.DonationFailed, "failure_reason": ErrProjectCancelled.Error()}).Error
+		return donation, err
+	}
+	if err := db.Model(&donation).Update("status", models.DonationProcessing).Error; err != nil {
+		return donation, err
+	}
+
+	var intent PaymentIntent
+	var chargeErr error
+	updates := map[string]interface{}{"status": models.DonationSucceeded}
+	if project.FundingMode == models.FundingAllOrNothing {
+		var customer string
+		customer, chargeErr = paymentProvider.SaveCard(ctx, donation.PaymentMethod, "card-"+donation.Reference)
+		updates["status"] = models.DonationAuthorized
+		updates["payment_customer"] = customer
+	} else {
+		intent, chargeErr = chargeDonation(ctx, paymentProvider, donation)
+	}
+	if chargeErr != nil && !errors.Is(chargeErr, ErrPaymentDeclined) && !errors.Is(chargeErr, ErrPaymentState) {
+		return donation, chargeErr
+	}
+	if intent.ID != "" {
+		updates["payment_intent_id"] = intent.ID
+		updates["payment_status"] = intent.Status
+	}
+	if chargeErr != nil {
+		updates["status"] = models.DonationFailed
+		updates["failure_reason"] = chargeErr.Error()
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&donation).Updates(updates).Error; err != nil {
+			return err
+		}
+		if donation.Status == models.DonationFailed {
+			return nil
+		}
+		// Share-locking the project orders this against CancelProject and
+		// the close-out: either they see this donation, or this sees them.
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "SHARE"}).
+			Select("id", "funding_mode", "cancelled_at", "closed_at").First(&project, donation.ProjectID).Error
+		if err != nil {
+			return err
+		}
+		switch {
+		case donation.Status == models.DonationAuthorized && (project.CancelledAt != nil || project.ClosedAt != nil):
+			return SettleDonationJob.Enqueue(ctx, queue, tx, donation.ID)
+		case donation.Status == models.DonationSucceeded && project.CancelledAt != nil:
+			_, err := queueRefund(ctx, tx, queue, donation, donation.Amount.Amount, ErrProjectCancelled.Error(), nil)
+			return err
+		}
+		return nil
+	})
+	return donation, err
+}
+
+// failDonationJob marks the donation failed once its job runs out of
+// attempts.
+func failDonationJob(tx *gorm.DB, task DonationTask, cause error) error {
+	return tx.Model(&models.Donation{}).
+		Where("id = ? AND status IN ?", task.DonationID, []string{models.DonationQueued, models.DonationProcessing}).
+		Updates(map[string]interface{}{"status": models.DonationFailed, "failure_reason": cause.Error()}).Error
+}
+
+// requeueDonationJob puts a donation failed by failDonationJob back in the
+// queue when its job is replayed.
+func requeueDonationJob(tx *gorm.DB, task DonationTask) error {
+	return tx.Model(&models.Donation{}).
+		Where("id = ? AND status = ?", task.DonationID, models.DonationFailed).
+		Updates(map[string]interface{}{"status": models.DonationQueued, "failure_reason": ""}).Error
+}
+
+// GetDonationsByProjectID returns a page of the project's donations in
+// full, newest first, whatever their status.
+func (s *DonationService) GetDonationsByProjectID(projectID uint64, page, perPage int) ([]models.Donation, error) {
 	var donations []models.Donation
-	err := s.db.Where("project_id = ?", projectID).Find(&donations).Error
+	err := s.db.Where("project_id = ?", projectID).
+		Order("timestamp DESC, id DESC").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Find(&donations).Error
 	return donations, err
 }
+
+// ListPublicDonations returns a page of the project's successful donations
+// and pledges, newest first, as shown to the public.
+func (s *DonationService) ListPublicDonations(projectID uint64, page, perPage int) ([]models.PublicDonation, error) {
+	var donations []models.Donation
+	err := s.db.Where("project_id = ? AND status IN ?", projectID, models.CountedDonationStatuses).
+		Order("timestamp DESC, id DESC").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Find(&donations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var userIDs []uint
+	for _, d := range donations {
+		if !d.Anonymous {
+			userIDs = append(userIDs, d.UserID)
+		}
+	}
+	usernames := map[uint]string{}
+	if len(userIDs) > 0 {
+		var users []models.User
+		if err := s.db.Select("id", "username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			usernames[u.ID] = u.Username
+		}
+	}
+
+	public := make([]models.PublicDonation, len(donations))
+	for i, d := range donations {
+		public[i] = presentDonation(d, usernames)
+	}
+	return public, nil
+}
+
+// presentDonation strips a donation down to what the public may see.
+// Anonymous donors lose their name and user ID, and a message hidden by a
+// moderator is dropped along with the display name.
+func presentDonation(donation models.Donation, usernames map[uint]string) models.PublicDonation {
+	public := models.PublicDonation{
+		ID:        donation.ID,
+		Anonymous: donation.Anonymous,
+		Amount:    donation.Amount,
+		Timestamp: donation.Timestamp,
+	}
+	hidden := donation.ModerationStatus == models.ModerationHidden
+	if !hidden {
+		public.Message = donation.Message
+	}
+	if !donation.Anonymous {
+		public.UserID = donation.UserID
+		public.Username = usernames[donation.UserID]
+		if !hidden {
+			public.DisplayName = donation.DisplayName
+		}
+	}
+	return public
+}
+
+// IsBacker reports whether the user has a successful donation or an
+// authorized pledge to the project.
+func (s *DonationService) IsBacker(projectID uint64, userID uint) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.Donation{}).
+		Where("project_id = ? AND user_id = ? AND status IN ?", projectID, userID, models.CountedDonationStatuses).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// BackerEmails returns the distinct email addresses of everyone who has
+// donated to the project.
+func (s *DonationService) BackerEmails(projectID uint64) ([]string, error) {
+	var emails []string
+	err := s.db.Model(&models.User{}).
+		Distinct("users.email").
+		Joins("JOIN donations ON donations.user_id = users.id").
+		Where("donations.project_id = ? AND donations.status IN ?", projectID, models.CountedDonationStatuses).
+		Pluck("users.email", &emails).Error
+	return emails, err
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestChargeDonation tests the outcome recorded for charged and declined donations
+func TestChargeDonation(t *testing.T) {
+	ctx := context.Background()
+	p := NewFakePaymentProvider("")
+	reference, err := newDonationReference()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(reference, "don_"))
+	donation := models.Donation{Reference: reference, Amount: models.Money{Amount: 2500, Currency: "USD"}, PaymentMethod: "pm_card_visa"}
+
+	intent, err := chargeDonation(ctx, p, donation)
+	assert.NoError(t, err)
+	assert.Equal(t, PaymentCaptured, intent.Status)
+
+	// The reference is the idempotency key, so a retried job finds the
+	// captured intent instead of charging again.
+	retry, err := chargeDonation(ctx, p, donation)
+	assert.NoError(t, err)
+	assert.Equal(t, intent.ID, retry.ID)
+	assert.Equal(t, PaymentCaptured, retry.Status)
+
+	// A card can only be charged once without being saved.
+	donation.Reference = "don_reused"
+	intent, err = chargeDonation(ctx, p, donation)
+	assert.ErrorIs(t, err, ErrPaymentDeclined)
+	assert.Equal(t, PaymentFailed, intent.Status)
+
+	donation.Reference = "don_declined"
+	donation.PaymentMethod = FakeCardDeclined
+	intent, err = chargeDonation(ctx, p, donation)
+	assert.ErrorIs(t, err, ErrPaymentDeclined)
+	assert.NotEmpty(t, intent.ID)
+	assert.Equal(t, PaymentFailed, intent.Status)
+
+	// Saved cards are charged off-session, as often as needed.
+	donation.PaymentMethod = "pm_card_mastercard"
+	donation.PaymentCustomer, err = p.SaveCard(ctx, donation.PaymentMethod, "")
+	require.NoError(t, err)
+	for _, reference := range []string{"don_cycle_1", "don_cycle_2"} {
+		donation.Reference = reference
+		intent, err = chargeDonation(ctx, p, donation)
+		assert.NoError(t, err)
+		assert.Equal(t, PaymentCaptured, intent.Status)
+	}
+}
+
+// TestCreateDonation_CardTokenNotInJob tests that the card token is kept on the donation, not in the charge job
+func TestCreateDonation_CardTokenNotInJob(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	queue := &recordingQueue{}
+	service := newTestDonationService(t, db, queue, NewFakePaymentProvider(""))
+	owner := createTestUser(t, db, "owner")
+	donor := createTestUser(t, db, "donor")
+	project := createTestProject(t, db, owner)
+
+	donation, err := service.CreateDonation(ctx, models.Donation{
+		ProjectID: project.ID,
+		UserID:    donor.ID,
+		Amount:    models.Money{Amount: 2500, Currency: "EUR"},
+	}, FakeCardDeclined)
+	require.NoError(t, err)
+	payloads := queue.payloads(string(ChargeDonationJob))
+	require.Len(t, payloads, 1)
+	assert.JSONEq(t, `{"donation_id":`+strconv.FormatUint(uint64(donation.ID), 10)+`}`, string(payloads[0]))
+
+	// The worker charges the stored token.
+	require.NoError(t, service.ChargeHandler(1).Run(ctx, DonationTask{DonationID: donation.ID}))
+	require.NoError(t, db.First(&donation, donation.ID).Error)
+	assert.Equal(t, FakeCardDeclined, donation.PaymentMethod)
+	assert.Equal(t, models.DonationFailed, donation.Status)
+}
+
+// TestChargeHandler_Confirmation tests that the donor is thanked at their own address, with the conversion applied
+func TestChargeHandler_Confirmation(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	queue := &recordingQueue{}
+	rates, err := NewStaticRateProvider(models.DefaultCurrency, map[string]string{"EUR": "0.9"})
+	require.NoError(t, err)
+	service := NewDonationService(db, NewEmailService(queue), rates, NewFakePaymentProvider(""), queue)
+	owner := createTestUser(t, db, "owner")
+	donor := createTestUser(t, db, "donor")
+	project := createTestProject(t, db, owner)
+
+	donation, err := service.CreateDonation(ctx, models.Donation{
+		ProjectID: project.ID,
+		UserID:    donor.ID,
+		Amount:    models.Money{Amount: 1000, Currency: "USD"},
+	}, "pm_card_visa")
+	require.NoError(t, err)
+	require.NoError(t, service.ChargeHandler(1).Run(ctx, DonationTask{DonationID: donation.ID}))
+
+	messages := queue.emails(t)
+	require.Len(t, messages, 1)
+	assert.Equal(t, []string{"donor@example.com"}, messages[0].To)
+	assert.Equal(t, "Donation Confirmation", messages[0].Subject)
+	assert.Contains(t, messages[0].Text, `"Solar kettle"`)
+	assert.Contains(t, messages[0].Text, "EUR")
+}
+
+// TestPresentDonation tests what the public sees of a donation
+func TestPresentDonation(t *testing.T) {
+	usernames := map[uint]string{9: "alice"}
+	donation := models.Donation{
+		ID:          3,
+		UserID:      9,
+		Amount:      models.Money{Amount: 2500, Currency: "EUR"},
+		DisplayName: "Alice & family",
+		Message:     "Good luck!",
+	}
+
+	public := presentDonation(donation, usernames)
+	assert.Equal(t, uint(9), public.UserID)
+	assert.Equal(t, "alice", public.Username)
+	assert.Equal(t, "Alice & family", public.DisplayName)
+	assert.Equal(t, "Good luck!", public.Message)
+	assert.Equal(t, donation.Amount, public.Amount)
+
+	anonymous := donation
+	anonymous.Anonymous = true
+	public = presentDonation(anonymous, usernames)
+	assert.Zero(t, public.UserID)
+	assert.Empty(t, public.Username)
+	assert.Empty(t, public.DisplayName)
+	assert.Equal(t, "Good luck!", public.Message)
+
+	hidden := donation
+	hidden.ModerationStatus = models.ModerationHidden
+	public = presentDonation(hidden, usernames)
+	assert.Equal(t, "alice", public.Username)
+	assert.Empty(t, public.DisplayName)
+	assert.Empty(t, public.Message)
+}
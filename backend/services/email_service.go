@@ -1,31 +1,222 @@
 package services
 
 import (
+	"context"
+	"crowdfund/backend/jobs"
 	"crowdfund/backend/models"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/smtp"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/jordan-wright/email"
 )
 
-type EmailService struct{}
+// EmailMessage is the payload of a SendEmailJob.
+type EmailMessage struct {
+	From    string   `json:"from"`
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	Text    string   `json:"text"`
+	HTML    string   `json:"html,omitempty"`
+}
 
-func NewEmailService() *EmailService {
-	return &EmailService{}
+// email builds the message to hand to SMTP.
+func (m EmailMessage) email() *email.Email {
+	e := email.NewEmail()
+	e.From = m.From
+	e.To = m.To
+	e.Subject = m.Subject
+	e.Text = []byte(m.Text)
+	if m.HTML != "" {
+		e.HTML = []byte(m.HTML)
+	}
+	return e
 }
 
-func (s *EmailService) SendDonationConfirmation(donation models.Donation) {
+// SendEmailJob delivers one email over SMTP, so a slow or failing mail
+// server neither holds up requests nor loses mail.
+const SendEmailJob jobs.Kind[EmailMessage] = "emails"
+
+type EmailService struct {
+	queue jobs.Queue
+}
+
+func NewEmailService(queue jobs.Queue) *EmailService {
+	return &EmailService{queue: queue}
+}
+
+// SendDonationConfirmation thanks a donor once their donation has been
+// charged, with the conversion when they paid in another currency than
+// the project's.
+func (s *EmailService) SendDonationConfirmation(to, projectTitle string, donation models.Donation) {
 	e := email.NewEmail()
 	e.From = "noreply@crowdfund.com"
-	e.To = []string{"user@example.com"} // get user email from db.
+	e.To = []string{to}
 	e.Subject = "Donation Confirmation"
-	e.Text = []byte(fmt.Sprintf("Thank you for your donation of $%f", donation.Amount))
+	text := fmt.Sprintf("Thank you for your donation of %s to %q", donation.Amount, projectTitle)
+	if donation.BaseAmount.Currency != "" && donation.BaseAmount.Currency != donation.Amount.Currency {
+		text += fmt.Sprintf(" (%s at %s %s per %s)", donation.BaseAmount, donation.ExchangeRate, donation.BaseAmount.Currency, donation.Amount.Currency)
+	}
+	e.Text = []byte(text)
+
+	s.send(e)
+}
+
+// SendPledgeConfirmation thanks a donor for a pledge to an all-or-nothing
+// project, which is only charged if the campaign reaches its goal.
+func (s *EmailService) SendPledgeConfirmation(to, projectTitle string, donation models.Donation) {
+	e := email.NewEmail()
+	e.From = "noreply@crowdfund.com"
+	e.To = []string{to}
+	e.Subject = "Pledge Confirmation"
+	e.Text = []byte(fmt.Sprintf("Thank you for pledging %s to %q. Your card has been saved and will only be charged when the campaign closes, if it reaches its goal.", donation.Amount, projectTitle))
+
+	s.send(e)
+}
+
+// SendPledgeReleased tells a donor their pledge was released without
+// charging them, because the campaign fell short or was cancelled.
+func (s *EmailService) SendPledgeReleased(to, projectTitle string, donation models.Donation) {
+	e := email.NewEmail()
+	e.From = "noreply@crowdfund.com"
+	e.To = []string{to}
+	e.Subject = fmt.Sprintf("%s was not funded", projectTitle)
+	e.Text = []byte(fmt.Sprintf("The campaign for %q did not go ahead, so your pledge of %s has been released and you have not been charged.", projectTitle, donation.Amount))
+
+	s.send(e)
+}
+
+// SendSubscriptionPaymentFailed tells a donor that this cycle's charge for
+// their recurring donation failed and when it will be retried.
+func (s *EmailService) SendSubscriptionPaymentFailed(to, projectTitle string, subscription models.Subscription, reason string, retryAt time.Time) {
+	e := email.NewEmail()
+	e.From = "noreply@crowdfund.com"
+	e.To = []string{to}
+	e.Subject = "Your recurring donation could not be charged"
+	e.Text = []byte(fmt.Sprintf("We could not charge %s for your %s donation to %q (%s). We will try again on %s; update your payment method before then to keep supporting the project.",
+		subscription.Amount, subscription.Interval, projectTitle, reason, retryAt.UTC().Format("Jan 2, 2006")))
+
+	s.send(e)
+}
+
+// SendSubscriptionCancelled tells a donor that their recurring donation was
+// stopped without them asking, e.g. after repeated failed charges.
+func (s *EmailService) SendSubscriptionCancelled(to, projectTitle string, subscription models.Subscription, reason string) {
+	e := email.NewEmail()
+	e.From = "noreply@crowdfund.com"
+	e.To = []string{to}
+	e.Subject = "Your recurring donation has been cancelled"
+	e.Text = []byte(fmt.Sprintf("Your %s donation of %s to %q has been cancelled because %s. You will not be charged again.",
+		subscription.Interval, subscription.Amount, projectTitle, reason))
+
+	s.send(e)
+}
+
+// SendProjectUpdate emails a newly published project update to one backer.
+func (s *EmailService) SendProjectUpdate(to string, project models.Project, update models.ProjectUpdate) {
+	e := email.NewEmail()
+	e.From = "noreply@crowdfund.com"
+	e.To = []string{to}
+	e.Subject = fmt.Sprintf("%s: %s", project.Title, update.Title)
+	e.Text = []byte(update.BodyMarkdown)
+	e.HTML = []byte(update.BodyHTML)
+
+	s.send(e)
+}
+
+// SendProjectChanged tells a backer that a project they support changed its
+// goal or deadline.
+func (s *EmailService) SendProjectChanged(to string, project models.Project, changes []models.FieldChange) {
+	var body strings.Builder
+	fmt.Fprintf(&body, "The creator of %q has changed the campaign:\n\n", project.Title)
+	for _, change := range changes {
+		fmt.Fprintf(&body, "  %s: %s -> %s\n", change.Field, formatChangeValue(change.From), formatChangeValue(change.To))
+	}
+
+	e := email.NewEmail()
+	e.From = "noreply@crowdfund.com"
+	e.To = []string{to}
+	e.Subject = fmt.Sprintf("%s has been updated", project.Title)
+	e.Text = []byte(body.String())
+
+	s.send(e)
+}
+
+// SendProjectEndingSoon reminds a follower that a project's campaign is
+// about to close.
+func (s *EmailService) SendProjectEndingSoon(to string, project models.Project) {
+	e := email.NewEmail()
+	e.From = "noreply@crowdfund.com"
+	e.To = []string{to}
+	e.Subject = fmt.Sprintf("%s ends soon", project.Title)
+	e.Text = []byte(fmt.Sprintf("The campaign for %q ends on %s. There is still time to back it.",
+		project.Title, project.EndDate.UTC().Format("Jan 2, 2006 15:04 MST")))
+
+	s.send(e)
+}
+
+// SendProjectFunded tells a follower that a project reached its goal.
+func (s *EmailService) SendProjectFunded(to string, project models.Project) {
+	e := email.NewEmail()
+	e.From = "noreply@crowdfund.com"
+	e.To = []string{to}
+	e.Subject = fmt.Sprintf("%s is fully funded", project.Title)
+	e.Text = []byte(fmt.Sprintf("Good news: %q has reached its funding goal.", project.Title))
+
+	s.send(e)
+}
+
+// SendRefundConfirmation tells a donor that money from their donation to
+// a project is on its way back to them.
+func (s *EmailService) SendRefundConfirmation(to, projectTitle string, refund models.Refund) {
+	e := email.NewEmail()
+	e.From = "noreply@crowdfund.com"
+	e.To = []string{to}
+	e.Subject = "Refund Confirmation"
+	text := fmt.Sprintf("%s of your donation to %q has been refunded to your original payment method.", refund.Amount, projectTitle)
+	if refund.Reason != "" {
+		text += "\n\nReason: " + refund.Reason
+	}
+	e.Text = []byte(text)
+
+	s.send(e)
+}
+
+// formatChangeValue writes a revision diff value for people. Money comes
+// out of the diff as a decoded JSON object and is formatted per currency.
+func formatChangeValue(value interface{}) string {
+	if object, ok := value.(map[string]interface{}); ok && object["currency"] != nil {
+		if b, err := json.Marshal(object); err == nil {
+			var money models.Money
+			if json.Unmarshal(b, &money) == nil {
+				return money.String()
+			}
+		}
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// send queues the email for delivery.
+func (s *EmailService) send(e *email.Email) {
+	message := EmailMessage{From: e.From, To: e.To, Subject: e.Subject, Text: string(e.Text), HTML: string(e.HTML)}
+	if err := SendEmailJob.Enqueue(context.Background(), s.queue, nil, message); err != nil {
+		log.Printf("Error queueing email: %v", err)
+	}
+}
 
-	auth := smtp.PlainAuth("", os.Getenv("MAILTRAP_USER"), os.Getenv("MAILTRAP_PASSWORD"), os.Getenv("MAILTRAP_HOST"))
-	err := e.Send(os.Getenv("MAILTRAP_HOST")+":"+os.Getenv("MAILTRAP_PORT"), auth)
-	if err != nil {
-		log.Printf("Error sending email: %v", err)
+// SendHandler returns the handler for SendEmailJob. SMTP errors are
+// returned so the email is retried.
+func (s *EmailService) SendHandler(workers int) jobs.Handler[EmailMessage] {
+	return jobs.Handler[EmailMessage]{
+		Workers: workers,
+		Run: func(ctx context.Context, message EmailMessage) error {
+			e := message.email()
+			auth := smtp.PlainAuth("", os.Getenv("MAILTRAP_USER"), os.Getenv("MAILTRAP_PASSWORD"), os.Getenv("MAILTRAP_HOST"))
+			return e.Send(os.Getenv("MAILTRAP_HOST")+":"+os.Getenv("MAILTRAP_PORT"), auth)
+		},
 	}
 }
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSendProjectUpdate_HTML tests that an update's HTML body survives the trip through the email queue
+func TestSendProjectUpdate_HTML(t *testing.T) {
+	queue := &recordingQueue{}
+	project := models.Project{Title: "Solar kettle"}
+	update := models.ProjectUpdate{Title: "Shipping", BodyMarkdown: "We **ship** today", BodyHTML: "<p>We <strong>ship</strong> today</p>"}
+	NewEmailService(queue).SendProjectUpdate("backer@example.com", project, update)
+
+	messages := queue.emails(t)
+	require.Len(t, messages, 1)
+	e := messages[0].email()
+	assert.Equal(t, []string{"backer@example.com"}, e.To)
+	assert.Equal(t, "Solar kettle: Shipping", e.Subject)
+	assert.Equal(t, update.BodyMarkdown, string(e.Text))
+	assert.Equal(t, update.BodyHTML, string(e.HTML))
+
+	// Plain-text emails stay plain text.
+	NewEmailService(queue).SendPledgeReleased("donor@example.com", project.Title, models.Donation{})
+	messages = queue.emails(t)
+	require.Len(t, messages, 1)
+	assert.Nil(t, messages[0].email().HTML)
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Payment methods understood by FakePaymentProvider. Any other method is
+// authorized successfully.
+const (
+	FakeCardDeclined = "pm_card_declined"
+	// FakeCardDeclinedOffSession saves fine but is declined whenever it
+	// is charged off-session.
+	FakeCardDeclinedOffSession = "pm_card_declined_off_session"
+)
+
+// FakePaymentProvider keeps payments in memory with predictable IDs
+// ("pi_fake_1", "re_fake_1", "cus_fake_1", ...). It is used in development
+// and tests. Like a real processor, it declines a payment method charged a
+// second time unless it was saved with SaveCard and is charged
+// off-session.
+type FakePaymentProvider struct {
+	webhookSecret string
+
+	mu        sync.Mutex
+	intents   map[string]*PaymentIntent
+	refunds   map[string]PaymentRefund
+	customers map[string]string // Customer -> saved payment method
+	used      map[string]bool   // Payment methods charged or saved already
+	keys      map[string]string // Idempotency key -> intent, refund or customer ID
+	sequence  int
+}
+
+func NewFakePaymentProvider(webhookSecret string) *FakePaymentProvider {
+	return &FakePaymentProvider{
+		webhookSecret: webhookSecret,
+		intents:       map[string]*PaymentIntent{},
+		refunds:       map[string]PaymentRefund{},
+		customers:     map[string]string{},
+		used:          map[string]bool{},
+		keys:          map[string]string{},
+	}
+}
+
+func (p *FakePaymentProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakePaymentProvider) CreateIntent(ctx context.Context, amount models.Money, metadata map[string]string, idempotencyKey string) (PaymentIntent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if id, ok := p.keys["intent:"+idempotencyKey]; ok && idempotencyKey != "" {
+		return *p.intents[id], nil
+	}
+	p.sequence++
+	intent := &PaymentIntent{ID: fmt.Sprintf("pi_fake_%d", p.sequence), Amount: amount, Status: PaymentCreated}
+	p.intents[intent.ID] = intent
+	if idempotencyKey != "" {
+		p.keys["intent:"+idempotencyKey] = intent.ID
+	}
+	return *intent, nil
+}
+
+func (p *FakePaymentProvider) Authorize(ctx context.Context, intentID, paymentMethod string) (PaymentIntent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return PaymentIntent{}, ErrPaymentNotFound
+	}
+	if intent.Status != PaymentCreated {
+		return *intent, ErrPaymentState
+	}
+	if paymentMethod == FakeCardDeclined || p.used[paymentMethod] {
+		intent.Status = PaymentFailed
+		return *intent, ErrPaymentDeclined
+	}
+	p.used[paymentMethod] = true
+	intent.Status = PaymentAuthorized
+	return *intent, nil
+}
+
+func (p *FakePaymentProvider) SaveCard(ctx context.Context, paymentMethod, idempotencyKey string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if id, ok := p.keys["customer:"+idempotencyKey]; ok && idempotencyKey != "" {
+		return id, nil
+	}
+	if paymentMethod == FakeCardDeclined || p.used[paymentMethod] {
+		return "", ErrPaymentDeclined
+	}
+	p.used[paymentMethod] = true
+	p.sequence++
+	customer := fmt.Sprintf("cus_fake_%d", p.sequence)
+	p.customers[customer] = paymentMethod
+	if idempotencyKey != "" {
+		p.keys["customer:"+idempotencyKey] = customer
+	}
+	return customer, nil
+}
+
+func (p *FakePaymentProvider) AuthorizeOffSession(ctx context.Context, intentID, customer, paymentMethod string) (PaymentIntent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return PaymentIntent{}, ErrPaymentNotFound
+	}
+	if intent.Status != PaymentCreated {
+		return *intent, ErrPaymentState
+	}
+	if p.customers[customer] != paymentMethod || paymentMethod == FakeCardDeclinedOffSession {
+		intent.Status = PaymentFailed
+		return *intent, ErrPaymentDeclined
+	}
+	intent.Status = PaymentAuthorized
+	return *intent, nil
+}
+
+func (p *FakePaymentProvider) Capture(ctx context.Context, intentID string, amount int64) (PaymentIntent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return PaymentIntent{}, ErrPaymentNotFound
+	}
+	if intent.Status != PaymentAuthorized {
+		return *intent, ErrPaymentState
+	}
+	if amount == 0 {
+		amount = intent.Amount.Amount
+	}
+	if amount < 0 || amount > intent.Amount.Amount {
+		return *intent, ErrPaymentState
+	}
+	intent.Status = PaymentCaptured
+	intent.Captured = amount
+	return *intent, nil
+}
+
+func (p *FakePaymentProvider) Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (PaymentRefund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if id, ok := p.keys["refund:"+idempotencyKey]; ok && idempotencyKey != "" {
+		return p.refunds[id], nil
+	}
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return PaymentRefund{}, ErrPaymentNotFound
+	}
+	if intent.Status != PaymentCaptured {
+		return PaymentRefund{}, ErrPaymentState
+	}
+	if amount == 0 {
+		amount = intent.Captured - intent.Refunded
+	}
+	if amount <= 0 || intent.Refunded+amount > intent.Captured {
+		return PaymentRefund{}, ErrRefundExceedsAmount
+	}
+	p.sequence++
+	refund := PaymentRefund{
+		ID:       fmt.Sprintf("re_fake_%d", p.sequence),
+		IntentID: intentID,
+		Amount:   models.Money{Amount: amount, Currency: intent.Amount.Currency},
+	}
+	intent.Refunded += amount
+	p.refunds[refund.ID] = refund
+	if idempotencyKey != "" {
+		p.keys["refund:"+idempotencyKey] = refund.ID
+	}
+	return refund, nil
+}
+
+func (p *FakePaymentProvider) Void(ctx context.Context, intentID string) (PaymentIntent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return PaymentIntent{}, ErrPaymentNotFound
+	}
+	if intent.Status != PaymentCreated && intent.Status != PaymentAuthorized {
+		return *intent, ErrPaymentState
+	}
+	intent.Status = PaymentVoided
+	return *intent, nil
+}
+
+// ParseWebhook accepts {"id", "type", "intent_id", "status"} bodies signed
+// with a hex HMAC-SHA256 of the body in X-Webhook-Signature.
+func (p *FakePaymentProvider) ParseWebhook(header http.Header, body []byte, now time.Time) (WebhookEvent, error) {
+	if !verifyHMAC([]byte(p.webhookSecret), body, header.Get("X-Webhook-Signature")) {
+		return WebhookEvent{}, ErrInvalidWebhookSignature
+	}
+	var event struct {
+		ID       string `json:"id"`
+		Type     string `json:"type"`
+		IntentID string `json:"intent_id"`
+		Status   string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" {
+		return WebhookEvent{}, ErrInvalidWebhookPayload
+	}
+	return WebhookEvent{ID: event.ID, Type: event.Type, IntentID: event.IntentID, Status: event.Status}, nil
+}
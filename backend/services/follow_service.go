@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EndingSoonWindow is how long before a project's EndDate followers get a
+// reminder.
+const EndingSoonWindow = 48 * time.Hour
+
+type FollowService struct {
+	db           *gorm.DB
+	emailService *EmailService
+}
+
+func NewFollowService(db *gorm.DB, emailService *EmailService) *FollowService {
+	return &FollowService{db: db, emailService: emailService}
+}
+
+// Follow adds the project to the user's watchlist. Following twice is a
+// no-op. A project that is already funded does not send a "funded" email
+// to new followers.
+func (s *FollowService) Follow(userID uint, project models.Project) error {
+	follow := models.ProjectFollow{UserID: userID, ProjectID: project.ID}
+	funded, err := s.isFunded(project)
+	if err != nil {
+		return err
+	}
+	if funded {
+		now := time.Now()
+		follow.FundedNotifiedAt = &now
+	}
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&follow).Error
+}
+
+func (s *FollowService) Unfollow(userID uint, projectID uint64) error {
+	return s.db.Where("user_id = ? AND project_id = ?", userID, projectID).Delete(&models.ProjectFollow{}).Error
+}
+
+// Following lists the projects on the user's watchlist, most recently
+// followed first. Projects that were removed or hidden are left out.
+func (s *FollowService) Following(userID uint, page, perPage int) ([]models.FollowedProject, error) {
+	var follows []models.ProjectFollow
+	err := s.db.
+		Joins("JOIN projects ON projects.id = project_follows.project_id").
+		Where("project_follows.user_id = ? AND projects.deleted_at IS NULL AND projects.moderation_status = ?", userID, models.ModerationActive).
+		Order("project_follows.created_at DESC").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Find(&follows).Error
+	if err != nil || len(follows) == 0 {
+		return []models.FollowedProject{}, err
+	}
+
+	ids := make([]uint, len(follows))
+	for i, follow := range follows {
+		ids[i] = follow.ProjectID
+	}
+	var projects []models.Project
+	if err := s.db.Preload("Tags").Preload("Media").Find(&projects, ids).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Project, len(projects))
+	for _, project := range projects {
+		byID[project.ID] = project
+	}
+
+	result := make([]models.FollowedProject, 0, len(follows))
+	for _, follow := range follows {
+		if project, ok := byID[follow.ProjectID]; ok {
+			result = append(result, models.FollowedProject{Project: project, FollowedAt: follow.CreatedAt})
+		}
+	}
+	return result, nil
+}
+
+// RunScheduler emails followers about projects ending soon or reaching
+// their goal. It returns when ctx is cancelled.
+func (s *FollowService) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.remindEndingSoon()
+			s.announceFunded()
+		}
+	}
+}
+
+func (s *FollowService) remindEndingSoon() {
+	now := time.Now()
+	var projects []models.Project
+	err := s.db.Where("moderation_status = ? AND end_date > ? AND end_date <= ?", models.ModerationActive, now, now.Add(EndingSoonWindow)).
+		Where("id IN (?)", s.db.Model(&models.ProjectFollow{}).Select("project_id").Where("ending_soon_notified_at IS NULL")).
+		Find(&projects).Error
+	if err != nil {
+		log.Printf("Error loading projects ending soon: %v", err)
+		return
+	}
+	for _, project := range projects {
+		s.notify(project, "ending_soon_notified_at", NotifyProjectEndingSoon, s.emailService.SendProjectEndingSoon)
+	}
+}
+
+func (s *FollowService) announceFunded() {
+	var projects []models.Project
+	err := s.db.Where("moderation_status = ?", models.ModerationActive).
+		Where("goal_amount <= (SELECT COALESCE(SUM(base_amount - base_refunded_amount), 0) FROM donations WHERE donations.project_id = projects.id AND donations.status IN ?)", models.CountedDonationStatuses).
+		Where("id IN (?)", s.db.Model(&models.ProjectFollow{}).Select("project_id").Where("funded_notified_at IS NULL")).
+		Find(&projects).Error
+	if err != nil {
+		log.Printf("Error loading funded projects: %v", err)
+		return
+	}
+	for _, project := range projects {
+		s.notify(project, "funded_notified_at", NotifyProjectFunded, s.emailService.SendProjectFunded)
+	}
+}
+
+// notify emails every follower of the project who has not had this
+// notification yet and wants it. Followers are marked before sending so a
+// slow SMTP server cannot cause duplicates on the next tick; followers who
+// opted out are marked too, so re-enabling the preference later does not
+// send stale reminders.
+func (s *FollowService) notify(project models.Project, notifiedColumn, kind string, send func(string, models.Project)) {
+	var emails []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var userIDs []uint
+		err := tx.Model(&models.ProjectFollow{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("project_id = ? AND "+notifiedColumn+" IS NULL", project.ID).
+			Pluck("user_id", &userIDs).Error
+		if err != nil || len(userIDs) == 0 {
+			return err
+		}
+		err = wantsNotification(tx.Model(&models.User{}), kind).
+			Where("users.id IN ? AND users.banned_at IS NULL", userIDs).
+			Pluck("users.email", &emails).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.ProjectFollow{}).
+			Where("project_id = ? AND user_id IN ?", project.ID, userIDs).
+			Update(notifiedColumn, time.Now()).Error
+	})
+	if err != nil {
+		log.Printf("Error notifying followers of project %d: %v", project.ID, err)
+		return
+	}
+	for _, to := range emails {
+		send(to, project)
+	}
+}
+
+func (s *FollowService) isFunded(project models.Project) (bool, error) {
+	var raised int64
+	err := s.db.Model(&models.Donation{}).Where("project_id = ? AND status IN ?", project.ID, models.CountedDonationStatuses).
+		Select("COALESCE(SUM(base_amount - base_refunded_amount), 0)").Scan(&raised).Error
+	return raised >= project.Goal.Amount, err
+}
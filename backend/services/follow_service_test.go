@@ -0,0 +1,146 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFollow tests following is idempotent and unfollowing removes the project from the watchlist
+func TestFollow(t *testing.T) {
+	db := newTestDB(t)
+	service := NewFollowService(db, NewEmailService(&recordingQueue{}))
+	owner := createTestUser(t, db, "owner")
+	fan := createTestUser(t, db, "fan")
+	project := createTestProject(t, db, owner)
+
+	require.NoError(t, service.Follow(fan.ID, project))
+	require.NoError(t, service.Follow(fan.ID, project))
+	following, err := service.Following(fan.ID, 1, 20)
+	require.NoError(t, err)
+	require.Len(t, following, 1)
+	assert.Equal(t, project.ID, following[0].ID)
+
+	// Hidden projects drop off the watchlist.
+	require.NoError(t, db.Model(&project).Update("moderation_status", models.ModerationHidden).Error)
+	following, err = service.Following(fan.ID, 1, 20)
+	require.NoError(t, err)
+	assert.Empty(t, following)
+	require.NoError(t, db.Model(&project).Update("moderation_status", models.ModerationActive).Error)
+
+	require.NoError(t, service.Unfollow(fan.ID, uint64(project.ID)))
+	following, err = service.Following(fan.ID, 1, 20)
+	require.NoError(t, err)
+	assert.Empty(t, following)
+}
+
+// TestFollow_AlreadyFunded tests that following a funded project does not send a stale "funded" email
+func TestFollow_AlreadyFunded(t *testing.T) {
+	db := newTestDB(t)
+	queue := &recordingQueue{}
+	service := NewFollowService(db, NewEmailService(queue))
+	owner := createTestUser(t, db, "owner")
+	fan := createTestUser(t, db, "fan")
+	project := createTestProject(t, db, owner)
+	createTestDonation(t, db, project, owner, project.Goal.Amount, models.DonationSucceeded)
+
+	require.NoError(t, service.Follow(fan.ID, project))
+	var follow models.ProjectFollow
+	require.NoError(t, db.First(&follow, "user_id = ? AND project_id = ?", fan.ID, project.ID).Error)
+	assert.NotNil(t, follow.FundedNotifiedAt)
+
+	service.announceFunded()
+	assert.Empty(t, queue.emails(t))
+}
+
+// TestNotificationPreferences tests defaults and partial updates
+func TestNotificationPreferences(t *testing.T) {
+	db := newTestDB(t)
+	service := NewNotificationPreferenceService(db)
+	user := createTestUser(t, db, "fan")
+
+	prefs, err := service.Get(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DefaultNotificationPreferences(user.ID), prefs)
+
+	off := false
+	prefs, err = service.Update(user.ID, models.UpdateNotificationPreferences{ProjectFunded: &off})
+	require.NoError(t, err)
+	assert.True(t, prefs.ProjectEndingSoon)
+	assert.False(t, prefs.ProjectFunded)
+
+	on := true
+	_, err = service.Update(user.ID, models.UpdateNotificationPreferences{ProjectEndingSoon: &off})
+	require.NoError(t, err)
+	_, err = service.Update(user.ID, models.UpdateNotificationPreferences{ProjectFunded: &on})
+	require.NoError(t, err)
+	prefs, err = service.Get(user.ID)
+	require.NoError(t, err)
+	assert.False(t, prefs.ProjectEndingSoon)
+	assert.True(t, prefs.ProjectFunded)
+}
+
+// TestRemindEndingSoon tests the reminder reaches each follower who wants it exactly once
+func TestRemindEndingSoon(t *testing.T) {
+	db := newTestDB(t)
+	queue := &recordingQueue{}
+	service := NewFollowService(db, NewEmailService(queue))
+	prefs := NewNotificationPreferenceService(db)
+	owner := createTestUser(t, db, "owner")
+	fan := createTestUser(t, db, "fan")
+	optedOut := createTestUser(t, db, "quiet")
+	banned := createTestUser(t, db, "banned")
+	require.NoError(t, db.Model(&banned).Update("banned_at", time.Now()).Error)
+	off := false
+	_, err := prefs.Update(optedOut.ID, models.UpdateNotificationPreferences{ProjectEndingSoon: &off})
+	require.NoError(t, err)
+
+	project := createTestProject(t, db, owner)
+	later := createTestProject(t, db, owner)
+	require.NoError(t, db.Model(&project).Update("end_date", time.Now().Add(24*time.Hour)).Error)
+	for _, user := range []models.User{fan, optedOut, banned} {
+		require.NoError(t, service.Follow(user.ID, project))
+		require.NoError(t, service.Follow(user.ID, later))
+	}
+
+	service.remindEndingSoon()
+	assert.Equal(t, []string{fan.Email}, recipients(queue.emails(t)))
+
+	// Everyone is marked, so nobody hears about it again, even after
+	// opting back in.
+	var unmarked int64
+	require.NoError(t, db.Model(&models.ProjectFollow{}).
+		Where("project_id = ? AND ending_soon_notified_at IS NULL", project.ID).Count(&unmarked).Error)
+	assert.Zero(t, unmarked)
+	on := true
+	_, err = prefs.Update(optedOut.ID, models.UpdateNotificationPreferences{ProjectEndingSoon: &on})
+	require.NoError(t, err)
+	service.remindEndingSoon()
+	assert.Empty(t, queue.emails(t))
+}
+
+// TestAnnounceFunded tests followers hear once when the goal is reached, counting refunds against it
+func TestAnnounceFunded(t *testing.T) {
+	db := newTestDB(t)
+	queue := &recordingQueue{}
+	service := NewFollowService(db, NewEmailService(queue))
+	owner := createTestUser(t, db, "owner")
+	fan := createTestUser(t, db, "fan")
+	project := createTestProject(t, db, owner)
+	require.NoError(t, service.Follow(fan.ID, project))
+
+	donation := createTestDonation(t, db, project, owner, project.Goal.Amount, models.DonationSucceeded)
+	createTestDonation(t, db, project, owner, project.Goal.Amount, models.DonationFailed)
+	require.NoError(t, db.Model(&donation).Update("base_refunded_amount", 1).Error)
+	service.announceFunded()
+	assert.Empty(t, queue.emails(t))
+
+	require.NoError(t, db.Model(&donation).Update("base_refunded_amount", 0).Error)
+	service.announceFunded()
+	assert.Equal(t, []string{fan.Email}, recipients(queue.emails(t)))
+	service.announceFunded()
+	assert.Empty(t, queue.emails(t))
+}
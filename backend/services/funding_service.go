@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/jobs"
+	"crowdfund/backend/models"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrProjectUnfunded is the failure reason for pledges released because
+// their campaign missed its goal.
+var ErrProjectUnfunded = errors.New("campaign did not reach its goal")
+
+// SettleDonationJob charges or releases an authorized pledge, by donation
+// ID, once its all-or-nothing campaign has closed or been cancelled.
+const SettleDonationJob jobs.Kind[uint] = "settlements"
+
+// FundingService closes campaigns once they end. All-or-nothing pledges are
+// charged to their saved cards if the goal was met and released otherwise.
+type FundingService struct {
+	db              *gorm.DB
+	emailService    *EmailService
+	refundService   *RefundService
+	paymentProvider PaymentProvider
+	queue           jobs.Queue
+}
+
+func NewFundingService(db *gorm.DB, emailService *EmailService, refundService *RefundService, paymentProvider PaymentProvider, queue jobs.Queue) *FundingService {
+	return &FundingService{db: db, emailService: emailService, refundService: refundService, paymentProvider: paymentProvider, queue: queue}
+}
+
+// RunScheduler closes campaigns that have ended. It returns when ctx is
+// cancelled.
+func (s *FundingService) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.closeEnded(ctx, time.Now())
+		}
+	}
+}
+
+// closeEnded closes every campaign past its end date. Campaigns with
+// donations still being charged wait for them, so the total is final.
+func (s *FundingService) closeEnded(ctx context.Context, now time.Time) {
+	var ids []uint
+	err := s.db.Model(&models.Project{}).
+		Where("end_date <= ? AND closed_at IS NULL AND cancelled_at IS NULL", now).
+		Where("NOT EXISTS (SELECT 1 FROM donations WHERE donations.project_id = projects.id AND donations.status IN ?)",
+			[]string{models.DonationQueued, models.DonationProcessing}).
+		Pluck("id", &ids).Error
+	if err != nil {
+		log.Printf("Error loading ended projects: %v", err)
+		return
+	}
+	for _, id := range ids {
+		if err := s.closeProject(ctx, id, now); err != nil {
+			log.Printf("Error closing project %d: %v", id, err)
+		}
+	}
+}
+
+// closeProject records whether the campaign met its goal and, for
+// all-or-nothing campaigns, queues its pledges to be charged or released.
+// Donations an unfunded all-or-nothing campaign already charged are
+// refunded.
+func (s *FundingService) closeProject(ctx context.Context, projectID uint, now time.Time) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var project models.Project
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "funding_mode", "goal_amount", "closed_at", "cancelled_at").
+			First(&project, projectID).Error
+		if err != nil {
+			return err
+		}
+		if project.ClosedAt != nil || project.CancelledAt != nil {
+			return nil
+		}
+
+		var raised int64
+		err = tx.Model(&models.Donation{}).
+			Where("project_id = ? AND status IN ?", projectID, models.CountedDonationStatuses).
+			Select("COALESCE(SUM(base_amount - base_refunded_amount), 0)").Scan(&raised).Error
+		if err != nil {
+			return err
+		}
+		result := models.FundingUnfunded
+		if raised >= project.Goal.Amount {
+			result = models.FundingFunded
+		}
+		err = tx.Model(&project).Updates(map[string]interface{}{"closed_at": now, "funding_result": result}).Error
+		if err != nil || project.FundingMode != models.FundingAllOrNothing {
+			return err
+		}
+
+		if err := settleDonations(ctx, tx, s.queue, projectID); err != nil {
+			return err
+		}
+		if result == models.FundingUnfunded {
+			_, err = s.refundService.RefundProject(ctx, tx, projectID, ErrProjectUnfunded.Error())
+		}
+		return err
+	})
+}
+
+// settleDonations queues every authorized pledge to the project to be
+// charged or released.
+func settleDonations(ctx context.Context, tx *gorm.DB, queue jobs.Queue, projectID uint) error {
+	var ids []uint
+	err := tx.Model(&models.Donation{}).
+		Where("project_id = ? AND status = ?", projectID, models.DonationAuthorized).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := SettleDonationJob.Enqueue(ctx, queue, tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SettleHandler returns the handler for SettleDonationJob. Donors are
+// emailed when their pledge is charged or released.
+func (s *FundingService) SettleHandler(workers int) jobs.Handler[uint] {
+	return jobs.Handler[uint]{
+		Workers: workers,
+		Run: func(ctx context.Context, donationID uint) error {
+			donation, settled, err := settleDonation(ctx, s.db, s.paymentProvider, donationID)
+			if err != nil || !settled {
+				return err
+			}
+			switch donation.Status {
+			case models.DonationSucceeded:
+				if to, project, err := donationRecipient(s.db, donation.ID); err != nil {
+					log.Printf("Error loading donor for donation %d: %v", donation.ID, err)
+				} else {
+					s.emailService.SendDonationConfirmation(to, project, donation)
+				}
+			case models.DonationFailed:
+				if donation.FailureReason != ErrProjectUnfunded.Error() && donation.FailureReason != ErrProjectCancelled.Error() {
+					break // The capture was refused
+				}
+				if to, project, err := donationRecipient(s.db, donation.ID); err != nil {
+					log.Printf("Error loading donor for donation %d: %v", donation.ID, err)
+				} else {
+					s.emailService.SendPledgeReleased(to, project, donation)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// settleDonation charges an authorized pledge off-session to its saved card
+// if its campaign was funded, and releases it if the campaign fell short or
+// was cancelled. Pledges from before cards were saved hold an
+// authorization instead, which is captured or voided. A charge the
+// provider refuses, e.g. because the card was declined or the
+// authorization expired, fails the donation; other errors are returned so
+// the job is retried. It reports whether this call settled the donation.
+func settleDonation(ctx context.Context, db *gorm.DB, provider PaymentProvider, donationID uint) (models.Donation, bool, error) {
+	var donation models.Donation
+	if err := db.First(&donation, donationID).Error; err != nil {
+		return donation, false, err
+	}
+	if donation.Status != models.DonationAuthorized {
+		return donation, false, nil
+	}
+	var project models.Project
+	err := db.Unscoped().Select("id", "cancelled_at", "funding_result").First(&project, donation.ProjectID).Error
+	if err != nil {
+		return donation, false, err
+	}
+
+	updates := map[string]interface{}{}
+	switch {
+	case project.CancelledAt != nil || project.FundingResult == models.FundingUnfunded:
+		reason := ErrProjectUnfunded
+		if project.CancelledAt != nil {
+			reason = ErrProjectCancelled
+		}
+		updates["status"] = models.DonationFailed
+		updates["failure_reason"] = reason.Error()
+		if donation.PaymentIntentID == "" {
+			break // Only the card was saved; there is nothing to release
+		}
+		intent, err := provider.Void(ctx, donation.PaymentIntentID)
+		if err != nil && !errors.Is(err, ErrPaymentState) {
+			return donation, false, err
+		}
+		if intent.Status != "" {
+			updates["payment_status"] = intent.Status
+		}
+	case project.FundingResult == models.FundingFunded:
+		var intent PaymentIntent
+		var err error
+		if donation.PaymentIntentID != "" {
+			intent, err = provider.Capture(ctx, donation.PaymentIntentID, 0)
+		} else {
+			intent, err = chargeDonation(ctx, provider, donation)
+		}
+		if err != nil && !errors.Is(err, ErrPaymentDeclined) && !errors.Is(err, ErrPaymentState) {
+			return donation, false, err
+		}
+		updates["status"] = models.DonationSucceeded
+		if err != nil {
+			updates["status"] = models.DonationFailed
+			updates["failure_reason"] = err.Error()
+		}
+		if intent.ID != "" {
+			updates["payment_intent_id"] = intent.ID
+		}
+		if intent.Status != "" {
+			updates["payment_status"] = intent.Status
+		}
+	default:
+		return donation, false, nil // The campaign is still running
+	}
+
+	// A webhook may have moved the donation on in the meantime.
+	result := db.Model(&donation).Where("status = ?", models.DonationAuthorized).Updates(updates)
+	return donation, result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// pledgeFixture is an all-or-nothing project taking pledges through the
+// fake provider.
+type pledgeFixture struct {
+	db        *gorm.DB
+	queue     *recordingQueue
+	donations *DonationService
+	funding   *FundingService
+	project   models.Project
+	donor     models.User
+}
+
+func newPledgeFixture(t *testing.T) pledgeFixture {
+	t.Helper()
+	db := newTestDB(t)
+	queue := &recordingQueue{}
+	provider := NewFakePaymentProvider("")
+	emails := NewEmailService(queue)
+	f := pledgeFixture{
+		db:        db,
+		queue:     queue,
+		donations: newTestDonationService(t, db, queue, provider),
+		funding:   NewFundingService(db, emails, NewRefundService(db, emails, provider, queue, DefaultRefundWindow), provider, queue),
+		donor:     createTestUser(t, db, "donor"),
+	}
+	f.project = createTestProject(t, db, createTestUser(t, db, "owner"))
+	require.NoError(t, db.Model(&f.project).Update("funding_mode", models.FundingAllOrNothing).Error)
+	return f
+}
+
+// pledge makes a pledge and runs its charge job.
+func (f pledgeFixture) pledge(t *testing.T, amount int64, paymentMethod string) models.Donation {
+	t.Helper()
+	ctx := context.Background()
+	donation, err := f.donations.CreateDonation(ctx, models.Donation{
+		ProjectID: f.project.ID,
+		UserID:    f.donor.ID,
+		Amount:    models.Money{Amount: amount, Currency: "EUR"},
+	}, paymentMethod)
+	require.NoError(t, err)
+	require.NoError(t, f.donations.ChargeHandler(1).Run(ctx, DonationTask{DonationID: donation.ID}))
+	require.NoError(t, f.db.First(&donation, donation.ID).Error)
+	return donation
+}
+
+// close ends the campaign and runs the settlement jobs it queues.
+func (f pledgeFixture) close(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+	require.NoError(t, f.db.Model(&f.project).Update("end_date", time.Now().Add(-time.Minute)).Error)
+	f.funding.closeEnded(ctx, time.Now())
+	for _, payload := range f.queue.payloads(string(SettleDonationJob)) {
+		donationID, err := strconv.ParseUint(string(payload), 10, 64)
+		require.NoError(t, err)
+		require.NoError(t, f.funding.SettleHandler(1).Run(ctx, uint(donationID)))
+	}
+}
+
+// TestPledge_ChargedAtClose tests that pledges only save the card, which is charged off-session once the goal is met
+func TestPledge_ChargedAtClose(t *testing.T) {
+	f := newPledgeFixture(t)
+
+	pledge := f.pledge(t, f.project.Goal.Amount, "pm_card_visa")
+	assert.Equal(t, models.DonationAuthorized, pledge.Status)
+	assert.NotEmpty(t, pledge.PaymentCustomer)
+	assert.Empty(t, pledge.PaymentIntentID)
+	lapsed := f.pledge(t, 500, FakeCardDeclinedOffSession)
+	assert.Equal(t, models.DonationAuthorized, lapsed.Status)
+	declined := f.pledge(t, 500, FakeCardDeclined)
+	assert.Equal(t, models.DonationFailed, declined.Status)
+	assert.Len(t, f.queue.emails(t), 2)
+
+	f.close(t)
+	require.NoError(t, f.db.First(&pledge, pledge.ID).Error)
+	assert.Equal(t, models.DonationSucceeded, pledge.Status)
+	assert.Equal(t, PaymentCaptured, pledge.PaymentStatus)
+	assert.NotEmpty(t, pledge.PaymentIntentID)
+	require.NoError(t, f.db.First(&lapsed, lapsed.ID).Error)
+	assert.Equal(t, models.DonationFailed, lapsed.Status)
+	assert.Contains(t, lapsed.FailureReason, ErrPaymentDeclined.Error())
+	messages := f.queue.emails(t)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "Donation Confirmation", messages[0].Subject)
+	assert.Equal(t, []string{"donor@example.com"}, messages[0].To)
+}
+
+// TestPledge_ReleasedWhenUnfunded tests that pledges to a campaign that missed its goal are released without a charge
+func TestPledge_ReleasedWhenUnfunded(t *testing.T) {
+	f := newPledgeFixture(t)
+	pledge := f.pledge(t, 500, "pm_card_visa")
+	f.queue.emails(t)
+
+	f.close(t)
+	require.NoError(t, f.db.First(&pledge, pledge.ID).Error)
+	assert.Equal(t, models.DonationFailed, pledge.Status)
+	assert.Equal(t, ErrProjectUnfunded.Error(), pledge.FailureReason)
+	assert.Empty(t, pledge.PaymentIntentID)
+	messages := f.queue.emails(t)
+	require.Len(t, messages, 1)
+	assert.Equal(t, f.project.Title+" was not funded", messages[0].Subject)
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyKeyTTL is how long a key's response is kept for replay.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// idempotencyKeyLease is how long a claimed key may stay without a
+// response. A key left unfinished past it, say by a server that crashed
+// mid-request, can be claimed again.
+const idempotencyKeyLease = 2 * time.Minute
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was already used for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still in progress")
+)
+
+type IdempotencyService struct {
+	db  *gorm.DB
+	ttl time.Duration
+}
+
+func NewIdempotencyService(db *gorm.DB, ttl time.Duration) *IdempotencyService {
+	return &IdempotencyService{db: db, ttl: ttl}
+}
+
+// RequestFingerprint identifies a request for comparison with later
+// requests that reuse its key.
+func RequestFingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Begin claims the user's key for a request. If the key already completed
+// a request with the same fingerprint, replay is true and the record holds
+// the response to send again. A claimed key must be finished with Complete
+// or Release; one that is not is taken over once its lease runs out.
+func (s *IdempotencyService) Begin(userID uint, key, fingerprint string) (record models.IdempotencyKey, replay bool, err error) {
+	now := time.Now()
+	claimed := false
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND key = ?", userID, key).
+			Where("expires_at <= ? OR (response_status = 0 AND created_at <= ?)", now, now.Add(-idempotencyKeyLease)).
+			Delete(&models.IdempotencyKey{}).Error
+		if err != nil {
+			return err
+		}
+		record = models.IdempotencyKey{UserID: userID, Key: key, Fingerprint: fingerprint, ExpiresAt: now.Add(s.ttl)}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			claimed = true
+			return nil
+		}
+		return tx.Where("user_id = ? AND key = ?", userID, key).First(&record).Error
+	})
+	switch {
+	case err != nil || claimed:
+		return record, false, err
+	case record.Fingerprint != fingerprint:
+		return record, false, ErrIdempotencyKeyReused
+	case record.ResponseStatus == 0:
+		return record, false, ErrIdempotencyKeyInProgress
+	}
+	return record, true, nil
+}
+
+// Complete stores the response to a claimed key for replay. Nothing is
+// stored if the key was taken over in the meantime.
+func (s *IdempotencyService) Complete(record models.IdempotencyKey, status int, header http.Header, body []byte) error {
+	headers, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	return s.db.Model(&record).Updates(map[string]interface{}{
+		"response_status":  status,
+		"response_headers": string(headers),
+		"response_body":    body,
+	}).Error
+}
+
+// ReplayedHeaders returns the headers stored with a completed key's
+// response. Keys stored before headers were kept have none.
+func ReplayedHeaders(record models.IdempotencyKey) (http.Header, error) {
+	header := http.Header{}
+	if record.ResponseHeaders == "" {
+		return header, nil
+	}
+	err := json.Unmarshal([]byte(record.ResponseHeaders), &header)
+	return header, err
+}
+
+// Release frees a claimed key without storing a response, so the client
+// can retry a request that failed on our side.
+func (s *IdempotencyService) Release(record models.IdempotencyKey) error {
+	return s.db.Delete(&record).Error
+}
+
+// RunScheduler deletes expired keys every interval until ctx is cancelled.
+func (s *IdempotencyService) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.db.Where("expires_at <= ?", time.Now()).Delete(&models.IdempotencyKey{}).Error; err != nil {
+				log.Printf("Error deleting expired idempotency keys: %v", err)
+			}
+		}
+	}
+}
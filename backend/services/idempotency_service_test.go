@@ -0,0 +1,17 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRequestFingerprint tests that a key reused on another endpoint or with another body is detected
+func TestRequestFingerprint(t *testing.T) {
+	body := []byte(`{"amount":"10.00","payment_method":"pm_card_visa"}`)
+	fingerprint := RequestFingerprint("POST", "/api/projects/1/donations", body)
+	assert.Len(t, fingerprint, 64)
+	assert.Equal(t, fingerprint, RequestFingerprint("POST", "/api/projects/1/donations", body))
+	assert.NotEqual(t, fingerprint, RequestFingerprint("POST", "/api/projects/2/donations", body))
+	assert.NotEqual(t, fingerprint, RequestFingerprint("POST", "/api/projects/1/donations", []byte(`{"amount":"20.00","payment_method":"pm_card_visa"}`)))
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/jobs"
+	"crowdfund/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// JobService lets admins inspect the job queue and replay dead jobs.
+type JobService struct {
+	db    *gorm.DB
+	queue jobs.Queue
+}
+
+func NewJobService(db *gorm.DB, queue jobs.Queue) *JobService {
+	return &JobService{db: db, queue: queue}
+}
+
+// Pending returns a page of jobs waiting to run on the queue, including
+// those waiting to be retried.
+func (s *JobService) Pending(ctx context.Context, queue string, page, perPage int) ([]jobs.Entry, error) {
+	return s.queue.Pending(ctx, queue, page, perPage)
+}
+
+// Dead returns a page of jobs that ran out of attempts, most recent first.
+func (s *JobService) Dead(queue string, includeReplayed bool, page, perPage int) ([]models.DeadJob, error) {
+	return jobs.Dead(s.db, queue, includeReplayed, page, perPage)
+}
+
+// Replay puts a copy of a dead job back on its queue to run straight away.
+func (s *JobService) Replay(ctx context.Context, deadJobID uint64) error {
+	return jobs.Replay(ctx, s.db, s.queue, deadJobID)
+}
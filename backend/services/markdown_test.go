@@ -0,0 +1,19 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRenderMarkdown_Sanitizes tests that scripts and javascript links are stripped
+func TestRenderMarkdown_Sanitizes(t *testing.T) {
+	html, err := RenderMarkdown("# Shipping!\n\n<script>alert(1)</script>\n\n[click](javascript:alert(1)) **bold**")
+	require.NoError(t, err)
+
+	assert.Contains(t, html, "<h1>Shipping!</h1>")
+	assert.Contains(t, html, "<strong>bold</strong>")
+	assert.NotContains(t, html, "<script>")
+	assert.NotContains(t, html, "javascript:")
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crowdfund/backend/models"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	_ "golang.org/x/image/webp"
+	"gorm.io/gorm"
+)
+
+const (
+	MaxImageUploadSize = 10 << 20  // 10 MB
+	MaxVideoUploadSize = 200 << 20 // 200 MB
+	thumbnailMaxSide   = 320
+	// maxImagePixels caps the decoded size of an uploaded image. A small,
+	// highly compressed file can otherwise expand to gigabytes in memory.
+	maxImagePixels    = 40_000_000
+	signedURLLifetime = 1 * time.Hour
+)
+
+var (
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrMediaTooLarge        = errors.New("file exceeds the upload size limit")
+	ErrImageTooLarge        = errors.New("image dimensions exceed the limit")
+
+	allowedMediaTypes = map[string]string{
+		"image/jpeg": models.MediaKindImage,
+		"image/png":  models.MediaKindImage,
+		"image/gif":  models.MediaKindImage,
+		"image/webp": models.MediaKindImage,
+		"video/mp4":  models.MediaKindVideo,
+		"video/webm": models.MediaKindVideo,
+	}
+	mediaExtensions = map[string]string{
+		"image/jpeg": ".jpg",
+		"image/png":  ".png",
+		"image/gif":  ".gif",
+		"image/webp": ".webp",
+		"video/mp4":  ".mp4",
+		"video/webm": ".webm",
+	}
+)
+
+type MediaService struct {
+	db    *gorm.DB
+	store BlobStore
+}
+
+func NewMediaService(db *gorm.DB, store BlobStore) *MediaService {
+	return &MediaService{db: db, store: store}
+}
+
+// Upload sniffs the file's real content type, enforces the size limit for
+// its kind, stores it (plus a thumbnail for images) and records it against
+// the project. The client-supplied Content-Type is ignored.
+func (s *MediaService) Upload(ctx context.Context, projectID uint64, file io.ReadSeeker, size int64) (models.ProjectMedia, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return models.ProjectMedia{}, err
+	}
+	contentType := http.DetectContentType(head[:n])
+	kind, ok := allowedMediaTypes[contentType]
+	if !ok {
+		return models.ProjectMedia{}, ErrUnsupportedMediaType
+	}
+	limit := int64(MaxImageUploadSize)
+	if kind == models.MediaKindVideo {
+		limit = MaxVideoUploadSize
+	}
+	if size > limit {
+		return models.ProjectMedia{}, ErrMediaTooLarge
+	}
+	if kind == models.MediaKindImage {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return models.ProjectMedia{}, err
+		}
+		if err := checkImageSize(file); err != nil {
+			return models.ProjectMedia{}, err
+		}
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return models.ProjectMedia{}, err
+	}
+
+	media := models.ProjectMedia{
+		ProjectID:   uint(projectID),
+		Kind:        kind,
+		ContentType: contentType,
+		Size:        size,
+		StorageKey:  fmt.Sprintf("projects/%d/%s%s", projectID, uuid.New().String(), mediaExtensions[contentType]),
+	}
+	if err := s.store.Put(ctx, media.StorageKey, file, size, contentType); err != nil {
+		return models.ProjectMedia{}, err
+	}
+
+	if kind == models.MediaKindImage {
+		if _, err := file.Seek(0, io.SeekStart); err == nil {
+			if thumb, err := makeThumbnail(file); err != nil {
+				log.Printf("Error generating thumbnail for %s: %v", media.StorageKey, err)
+			} else {
+				key := media.StorageKey + ".thumb.jpg"
+				if err := s.store.Put(ctx, key, bytes.NewReader(thumb), int64(len(thumb)), "image/jpeg"); err != nil {
+					log.Printf("Error storing thumbnail for %s: %v", media.StorageKey, err)
+				} else {
+					media.ThumbnailKey = &key
+				}
+			}
+		}
+	}
+
+	if err := s.db.Create(&media).Error; err != nil {
+		s.removeBlobs(ctx, media)
+		return models.ProjectMedia{}, err
+	}
+	s.Sign(&media)
+	return media, nil
+}
+
+func (s *MediaService) GetMedia(projectID, mediaID uint64) (models.ProjectMedia, error) {
+	var media models.ProjectMedia
+	err := s.db.Where("project_id = ?", projectID).First(&media, mediaID).Error
+	return media, err
+}
+
+func (s *MediaService) DeleteMedia(ctx context.Context, media models.ProjectMedia) error {
+	if err := s.db.Delete(&media).Error; err != nil {
+		return err
+	}
+	s.removeBlobs(ctx, media)
+	return nil
+}
+
+// Sign fills in the signed URLs for a media item.
+func (s *MediaService) Sign(media *models.ProjectMedia) {
+	var err error
+	if media.URL, err = s.store.SignedURL(media.StorageKey, signedURLLifetime); err != nil {
+		log.Printf("Error signing media URL: %v", err)
+	}
+	if media.ThumbnailKey != nil {
+		if media.ThumbnailURL, err = s.store.SignedURL(*media.ThumbnailKey, signedURLLifetime); err != nil {
+			log.Printf("Error signing thumbnail URL: %v", err)
+		}
+	}
+}
+
+// SignProject signs every media item attached to the project.
+func (s *MediaService) SignProject(project *models.Project) {
+	for i := range project.Media {
+		s.Sign(&project.Media[i])
+	}
+}
+
+func (s *MediaService) removeBlobs(ctx context.Context, media models.ProjectMedia) {
+	if err := s.store.Delete(ctx, media.StorageKey); err != nil {
+		log.Printf("Error deleting blob %s: %v", media.StorageKey, err)
+	}
+	if media.ThumbnailKey != nil {
+		if err := s.store.Delete(ctx, *media.ThumbnailKey); err != nil {
+			log.Printf("Error deleting blob %s: %v", *media.ThumbnailKey, err)
+		}
+	}
+}
+
+// checkImageSize reads just the image header and rejects images that would
+// decode to more than maxImagePixels. Files that are not decodable images
+// are rejected as unsupported.
+func checkImageSize(r io.Reader) error {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return ErrUnsupportedMediaType
+	}
+	if int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return ErrImageTooLarge
+	}
+	return nil
+}
+
+// makeThumbnail decodes an image and scales it down so its longest side is
+// at most thumbnailMaxSide, averaging the source pixels that fall into each
+// destination pixel. r must be seekable so the header can be checked
+// before the image is decoded.
+func makeThumbnail(r io.ReadSeeker) ([]byte, error) {
+	if err := checkImageSize(r); err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return nil, errors.New("empty image")
+	}
+	tw, th := w, h
+	if w > thumbnailMaxSide || h > thumbnailMaxSide {
+		if w >= h {
+			tw, th = thumbnailMaxSide, max(1, h*thumbnailMaxSide/w)
+		} else {
+			tw, th = max(1, w*thumbnailMaxSide/h), thumbnailMaxSide
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+max((x+1)*w/tw, x*w/tw+1)
+			var rs, gs, bs, as, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					r, g, bl, a := src.At(sx, sy).RGBA()
+					rs, gs, bs, as = rs+uint64(r), gs+uint64(g), bs+uint64(bl), as+uint64(a)
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(rs / n >> 8)
+			dst.Pix[i+1] = uint8(gs / n >> 8)
+			dst.Pix[i+2] = uint8(bs / n >> 8)
+			dst.Pix[i+3] = uint8(as / n >> 8)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
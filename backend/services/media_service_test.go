@@ -0,0 +1,145 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a minimal in-memory stand-in for an S3-compatible server such as
+// MinIO. It only implements the object calls S3BlobStore makes.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut, http.MethodDelete:
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=test-key/") || r.Header.Get("X-Amz-Date") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		if r.URL.Query().Get("X-Amz-Signature") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	}
+}
+
+// TestS3BlobStore_RoundTrip tests put, presigned get and delete against a fake S3 server
+func TestS3BlobStore_RoundTrip(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store := NewS3BlobStore(S3Config{Endpoint: server.URL, Bucket: "media", AccessKey: "test-key", SecretKey: "test-secret"})
+	ctx := context.Background()
+
+	err := store.Put(ctx, "projects/1/file.txt", strings.NewReader("hello"), 5, "text/plain")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), fake.objects["/media/projects/1/file.txt"])
+
+	signed, err := store.SignedURL("projects/1/file.txt", 10*time.Minute)
+	require.NoError(t, err)
+	assert.Contains(t, signed, "X-Amz-Expires=600")
+
+	resp, err := http.Get(signed)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "hello", string(body))
+
+	require.NoError(t, store.Delete(ctx, "projects/1/file.txt"))
+	assert.Empty(t, fake.objects)
+}
+
+// TestLocalBlobStore_SignedURL tests that tampered or expired signatures are rejected
+func TestLocalBlobStore_SignedURL(t *testing.T) {
+	store := NewLocalBlobStore(t.TempDir(), "http://localhost:8080", "secret")
+	require.NoError(t, store.Put(context.Background(), "a/b.txt", strings.NewReader("data"), 4, "text/plain"))
+
+	exp := time.Now().Add(time.Minute).Unix()
+	sig := store.sign("a/b.txt", strconv.FormatInt(exp, 10))
+	f, err := store.Open("a/b.txt", strconv.FormatInt(exp, 10), sig)
+	require.NoError(t, err)
+	f.Close()
+
+	_, err = store.Open("a/b.txt", strconv.FormatInt(exp+1, 10), sig)
+	assert.Error(t, err)
+
+	past := time.Now().Add(-time.Minute).Unix()
+	_, err = store.Open("a/b.txt", strconv.FormatInt(past, 10), store.sign("a/b.txt", strconv.FormatInt(past, 10)))
+	assert.Error(t, err)
+}
+
+// TestMakeThumbnail tests that large images are scaled down preserving aspect ratio
+func TestMakeThumbnail(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 1280, 640))
+	for y := 0; y < 640; y++ {
+		for x := 0; x < 1280; x++ {
+			src.Set(x, y, color.RGBA{R: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, src))
+
+	thumb, err := makeThumbnail(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	decoded, err := jpeg.Decode(bytes.NewReader(thumb))
+	require.NoError(t, err)
+	assert.Equal(t, 320, decoded.Bounds().Dx())
+	assert.Equal(t, 160, decoded.Bounds().Dy())
+}
+
+// TestMakeThumbnail_DecompressionBomb tests that images whose header claims
+// huge dimensions are rejected before they are decoded
+func TestMakeThumbnail_DecompressionBomb(t *testing.T) {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], 100000)
+	binary.BigEndian.PutUint32(ihdr[4:], 100000)
+	ihdr[8], ihdr[9] = 8, 2 // 8-bit RGB
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	chunk := append([]byte("IHDR"), ihdr...)
+	buf.Write(chunk)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+
+	_, err := makeThumbnail(bytes.NewReader(buf.Bytes()))
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrReportTargetNotFound = errors.New("reported content not found")
+	ErrInvalidModeration    = errors.New("action is not valid for this target")
+)
+
+type ModerationService struct {
+	db *gorm.DB
+	// autoHideThreshold is the number of open reports from distinct users
+	// after which a target is hidden pending review. Zero disables it.
+	autoHideThreshold int64
+}
+
+func NewModerationService(db *gorm.DB, autoHideThreshold int64) *ModerationService {
+	return &ModerationService{db: db, autoHideThreshold: autoHideThreshold}
+}
+
+// CreateReport files a report and hides the target automatically once it
+// reaches the report threshold. A user reporting the same target twice is a
+// no-op. It returns the IDs of projects whose visibility changed.
+func (s *ModerationService) CreateReport(report *models.Report) ([]uint, error) {
+	if _, err := s.targetStatus(s.db, report.TargetType, report.TargetID); err != nil {
+		return nil, err
+	}
+	report.Status = models.ReportStatusOpen
+
+	var changed []uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(report).Error
+		if err != nil || s.autoHideThreshold == 0 {
+			return err
+		}
+
+		var open int64
+		err = tx.Model(&models.Report{}).
+			Where("target_type = ? AND target_id = ? AND status = ?", report.TargetType, report.TargetID, models.ReportStatusOpen).
+			Count(&open).Error
+		if err != nil || open < s.autoHideThreshold {
+			return err
+		}
+
+		status, err := s.targetStatus(tx, report.TargetType, report.TargetID)
+		if err != nil || status != models.ModerationActive {
+			return err
+		}
+		if err := s.setStatus(tx, report.TargetType, report.TargetID, models.ModerationHidden); err != nil {
+			return err
+		}
+		if report.TargetType == models.ReportTargetProject {
+			changed = append(changed, report.TargetID)
+		}
+		return tx.Create(&models.ModerationAction{
+			TargetType: report.TargetType,
+			TargetID:   report.TargetID,
+			Action:     models.ActionAutoHide,
+			Note:       "hidden automatically after reaching the report threshold",
+		}).Error
+	})
+	return changed, err
+}
+
+// Queue lists targets with open reports, most reported first.
+func (s *ModerationService) Queue(page, perPage int) ([]models.ModerationQueueItem, error) {
+	var items []models.ModerationQueueItem
+	err := s.db.Model(&models.Report{}).
+		Select(`target_type, target_id, COUNT(*) AS report_count,
+			MIN(created_at) AS first_reported_at, MAX(created_at) AS last_reported_at`).
+		Where("status = ?", models.ReportStatusOpen).
+		Group("target_type, target_id").
+		Order("report_count DESC, first_reported_at").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Scan(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].ModerationStatus, err = s.targetStatus(s.db, items[i].TargetType, items[i].TargetID)
+		if err != nil && !errors.Is(err, ErrReportTargetNotFound) {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+func (s *ModerationService) ReportsFor(targetType string, targetID uint) ([]models.Report, error) {
+	var reports []models.Report
+	err := s.db.Where("target_type = ? AND target_id = ?", targetType, targetID).
+		Order("created_at DESC").Find(&reports).Error
+	return reports, err
+}
+
+func (s *ModerationService) History(targetType string, targetID uint) ([]models.ModerationAction, error) {
+	var actions []models.ModerationAction
+	err := s.db.Where("target_type = ? AND target_id = ?", targetType, targetID).
+		Order("created_at DESC").Find(&actions).Error
+	return actions, err
+}
+
+// TakeAction applies an admin decision to a target, closes its open reports
+// and records the decision in the audit history. It returns the IDs of
+// projects whose visibility changed.
+func (s *ModerationService) TakeAction(adminID uint, input models.TakeModerationAction) ([]uint, error) {
+	if input.Action == models.ActionSuspend && input.TargetType != models.ReportTargetProject {
+		return nil, ErrInvalidModeration
+	}
+
+	var changed []uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		status, err := s.targetStatus(tx, input.TargetType, input.TargetID)
+		if err != nil {
+			return err
+		}
+
+		reportStatus := models.ReportStatusResolved
+		switch input.Action {
+		case models.ActionHide:
+			err = s.setStatus(tx, input.TargetType, input.TargetID, models.ModerationHidden)
+		case models.ActionSuspend:
+			err = s.setStatus(tx, input.TargetType, input.TargetID, models.ModerationSuspended)
+		case models.ActionRestore:
+			err = s.setStatus(tx, input.TargetType, input.TargetID, models.ModerationActive)
+		case models.ActionDismiss:
+			reportStatus = models.ReportStatusDismissed
+			// Reports were unfounded: undo any automatic hiding.
+			if status == models.ModerationHidden {
+				err = s.setStatus(tx, input.TargetType, input.TargetID, models.ModerationActive)
+			}
+		case models.ActionBan:
+			var suspended []uint
+			suspended, err = s.banAuthor(tx, input.TargetType, input.TargetID)
+			changed = append(changed, suspended...)
+		default:
+			return ErrInvalidModeration
+		}
+		if err != nil {
+			return err
+		}
+		if input.TargetType == models.ReportTargetProject {
+			changed = append(changed, input.TargetID)
+		}
+
+		now := time.Now()
+		err = tx.Model(&models.Report{}).
+			Where("target_type = ? AND target_id = ? AND status = ?", input.TargetType, input.TargetID, models.ReportStatusOpen).
+			Updates(map[string]interface{}{"status": reportStatus, "resolved_at": now, "resolved_by": adminID}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&models.ModerationAction{
+			TargetType: input.TargetType,
+			TargetID:   input.TargetID,
+			Action:     input.Action,
+			AdminID:    &adminID,
+			Note:       input.Note,
+		}).Error
+	})
+	return changed, err
+}
+
+// banAuthor bans the user who created the target and suspends every project
+// they own.
+func (s *ModerationService) banAuthor(tx *gorm.DB, targetType string, targetID uint) ([]uint, error) {
+	var authorID uint
+	var err error
+	switch targetType {
+	case models.ReportTargetProject:
+		err = tx.Model(&models.Project{}).Where("id = ?", targetID).Pluck("user_id", &authorID).Error
+	case models.ReportTargetDonation:
+		err = tx.Model(&models.Donation{}).Where("id = ?", targetID).Pluck("user_id", &authorID).Error
+	default:
+		err = tx.Model(&models.Comment{}).Where("id = ?", targetID).Pluck("user_id", &authorID).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(&models.User{}).Where("id = ?", authorID).Update("banned_at", time.Now()).Error; err != nil {
+		return nil, err
+	}
+	var projectIDs []uint
+	if err := tx.Model(&models.Project{}).Where("user_id = ?", authorID).Pluck("id", &projectIDs).Error; err != nil {
+		return nil, err
+	}
+	err = tx.Model(&models.Project{}).Where("user_id = ?", authorID).
+		Update("moderation_status", models.ModerationSuspended).Error
+	return projectIDs, err
+}
+
+func (s *ModerationService) targetStatus(tx *gorm.DB, targetType string, targetID uint) (string, error) {
+	var statuses []string
+	var err error
+	switch targetType {
+	case models.ReportTargetProject:
+		err = tx.Model(&models.Project{}).Where("id = ?", targetID).Pluck("moderation_status", &statuses).Error
+	case models.ReportTargetComment:
+		err = tx.Model(&models.Comment{}).Where("id = ? AND deleted_at IS NULL", targetID).Pluck("moderation_status", &statuses).Error
+	case models.ReportTargetDonation:
+		// Only the donor's own words can be reported, and only on
+		// donations the public can see.
+		err = tx.Model(&models.Donation{}).
+			Where("id = ? AND status IN ? AND (message <> '' OR display_name <> '')", targetID, models.CountedDonationStatuses).
+			Pluck("moderation_status", &statuses).Error
+	default:
+		return "", ErrReportTargetNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(statuses) == 0 {
+		return "", ErrReportTargetNotFound
+	}
+	return statuses[0], nil
+}
+
+func (s *ModerationService) setStatus(tx *gorm.DB, targetType string, targetID uint, status string) error {
+	switch targetType {
+	case models.ReportTargetProject:
+		return tx.Model(&models.Project{}).Where("id = ?", targetID).Update("moderation_status", status).Error
+	case models.ReportTargetDonation:
+		return tx.Model(&models.Donation{}).Where("id = ?", targetID).Update("moderation_status", status).Error
+	}
+	return tx.Model(&models.Comment{}).Where("id = ?", targetID).Update("moderation_status", status).Error
+}
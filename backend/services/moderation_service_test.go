@@ -0,0 +1,204 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// TestCreateReport_Donations tests that only listed donations with words of their own can be reported
+func TestCreateReport_Donations(t *testing.T) {
+	db := newTestDB(t)
+	service := NewModerationService(db, 0)
+	owner := createTestUser(t, db, "owner")
+	donor := createTestUser(t, db, "donor")
+	project := createTestProject(t, db, owner)
+
+	report := func(donation models.Donation) error {
+		_, err := service.CreateReport(&models.Report{
+			TargetType: models.ReportTargetDonation,
+			TargetID:   donation.ID,
+			ReporterID: owner.ID,
+			Reason:     "spam",
+		})
+		return err
+	}
+	for _, status := range []string{models.DonationSucceeded, models.DonationAuthorized} {
+		donation := createTestDonation(t, db, project, donor, 500, status)
+		require.NoError(t, db.Model(&donation).Update("message", "Buy cheap watches").Error)
+		assert.NoError(t, report(donation), status)
+	}
+	for _, status := range []string{models.DonationQueued, models.DonationFailed, models.DonationRefunded} {
+		donation := createTestDonation(t, db, project, donor, 500, status)
+		require.NoError(t, db.Model(&donation).Update("message", "Buy cheap watches").Error)
+		assert.ErrorIs(t, report(donation), ErrReportTargetNotFound, status)
+	}
+	silent := createTestDonation(t, db, project, donor, 500, models.DonationSucceeded)
+	assert.ErrorIs(t, report(silent), ErrReportTargetNotFound)
+}
+
+// reportProject files a report against a project from a new user.
+func reportProject(t *testing.T, db *gorm.DB, service *ModerationService, project models.Project, reporter string) []uint {
+	t.Helper()
+	changed, err := service.CreateReport(&models.Report{
+		TargetType: models.ReportTargetProject,
+		TargetID:   project.ID,
+		ReporterID: createTestUser(t, db, reporter).ID,
+		Reason:     "spam",
+	})
+	require.NoError(t, err)
+	return changed
+}
+
+// moderationStatus returns the project's current moderation status.
+func moderationStatus(t *testing.T, db *gorm.DB, project models.Project) string {
+	t.Helper()
+	require.NoError(t, db.First(&project, project.ID).Error)
+	return project.ModerationStatus
+}
+
+// TestCreateReport_AutoHide tests that a target is hidden once reports from distinct users reach the threshold
+func TestCreateReport_AutoHide(t *testing.T) {
+	db := newTestDB(t)
+	// The unique report per reporter from the migration, which the model
+	// does not declare.
+	require.NoError(t, db.Exec("CREATE UNIQUE INDEX idx_reports_target_reporter ON reports(target_type, target_id, reporter_id)").Error)
+	service := NewModerationService(db, 2)
+	owner := createTestUser(t, db, "owner")
+	project := createTestProject(t, db, owner)
+
+	first := createTestUser(t, db, "first")
+	for i := 0; i < 2; i++ {
+		changed, err := service.CreateReport(&models.Report{
+			TargetType: models.ReportTargetProject,
+			TargetID:   project.ID,
+			ReporterID: first.ID,
+			Reason:     "spam",
+		})
+		require.NoError(t, err)
+		assert.Empty(t, changed)
+	}
+	assert.Equal(t, models.ModerationActive, moderationStatus(t, db, project))
+
+	assert.Equal(t, []uint{project.ID}, reportProject(t, db, service, project, "second"))
+	assert.Equal(t, models.ModerationHidden, moderationStatus(t, db, project))
+	history, err := service.History(models.ReportTargetProject, project.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, models.ActionAutoHide, history[0].Action)
+	assert.Nil(t, history[0].AdminID)
+
+	// Already hidden: further reports are only queued.
+	assert.Empty(t, reportProject(t, db, service, project, "third"))
+	history, err = service.History(models.ReportTargetProject, project.ID)
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	_, err = service.CreateReport(&models.Report{TargetType: models.ReportTargetProject, TargetID: project.ID + 100, ReporterID: first.ID})
+	assert.ErrorIs(t, err, ErrReportTargetNotFound)
+}
+
+// TestTakeAction_ClosesReports tests that acting on a target closes only its own open reports.
+// Queue itself is not run here: SQLite returns its MIN and MAX timestamps as text.
+func TestTakeAction_ClosesReports(t *testing.T) {
+	db := newTestDB(t)
+	service := NewModerationService(db, 0)
+	owner := createTestUser(t, db, "owner")
+	quiet := createTestProject(t, db, owner)
+	noisy := createTestProject(t, db, owner)
+	reportProject(t, db, service, quiet, "a")
+	reportProject(t, db, service, noisy, "b")
+	reportProject(t, db, service, noisy, "c")
+
+	admin := createTestUser(t, db, "admin")
+	_, err := service.TakeAction(admin.ID, models.TakeModerationAction{TargetType: models.ReportTargetProject, TargetID: noisy.ID, Action: models.ActionDismiss})
+	require.NoError(t, err)
+	reports, err := service.ReportsFor(models.ReportTargetProject, noisy.ID)
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	for _, report := range reports {
+		assert.Equal(t, models.ReportStatusDismissed, report.Status)
+		assert.Equal(t, &admin.ID, report.ResolvedBy)
+	}
+	reports, err = service.ReportsFor(models.ReportTargetProject, quiet.ID)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, models.ReportStatusOpen, reports[0].Status)
+
+	// Dismissing undoes an automatic hide.
+	require.NoError(t, db.Model(&quiet).Update("moderation_status", models.ModerationHidden).Error)
+	_, err = service.TakeAction(admin.ID, models.TakeModerationAction{TargetType: models.ReportTargetProject, TargetID: quiet.ID, Action: models.ActionDismiss})
+	require.NoError(t, err)
+	assert.Equal(t, models.ModerationActive, moderationStatus(t, db, quiet))
+}
+
+// TestTakeAction tests hiding, suspending and restoring a project, with each decision recorded
+func TestTakeAction(t *testing.T) {
+	db := newTestDB(t)
+	service := NewModerationService(db, 0)
+	admin := createTestUser(t, db, "admin")
+	owner := createTestUser(t, db, "owner")
+	project := createTestProject(t, db, owner)
+	reportProject(t, db, service, project, "reporter")
+
+	act := func(action string) ([]uint, error) {
+		return service.TakeAction(admin.ID, models.TakeModerationAction{
+			TargetType: models.ReportTargetProject,
+			TargetID:   project.ID,
+			Action:     action,
+			Note:       action + " note",
+		})
+	}
+	for action, status := range map[string]string{
+		models.ActionHide:    models.ModerationHidden,
+		models.ActionSuspend: models.ModerationSuspended,
+		models.ActionRestore: models.ModerationActive,
+	} {
+		changed, err := act(action)
+		require.NoError(t, err, action)
+		assert.Equal(t, []uint{project.ID}, changed, action)
+		assert.Equal(t, status, moderationStatus(t, db, project), action)
+	}
+
+	reports, err := service.ReportsFor(models.ReportTargetProject, project.ID)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, models.ReportStatusResolved, reports[0].Status)
+	assert.NotNil(t, reports[0].ResolvedAt)
+	history, err := service.History(models.ReportTargetProject, project.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	for _, action := range history {
+		assert.Equal(t, &admin.ID, action.AdminID)
+		assert.Equal(t, action.Action+" note", action.Note)
+	}
+
+	// Only projects can be suspended.
+	donor := createTestUser(t, db, "donor")
+	donation := createTestDonation(t, db, project, donor, 500, models.DonationSucceeded)
+	_, err = service.TakeAction(admin.ID, models.TakeModerationAction{TargetType: models.ReportTargetDonation, TargetID: donation.ID, Action: models.ActionSuspend})
+	assert.ErrorIs(t, err, ErrInvalidModeration)
+}
+
+// TestTakeAction_Ban tests that banning an author suspends every project they own
+func TestTakeAction_Ban(t *testing.T) {
+	db := newTestDB(t)
+	service := NewModerationService(db, 0)
+	admin := createTestUser(t, db, "admin")
+	owner := createTestUser(t, db, "owner")
+	reported := createTestProject(t, db, owner)
+	other := createTestProject(t, db, owner)
+	bystander := createTestProject(t, db, admin)
+
+	changed, err := service.TakeAction(admin.ID, models.TakeModerationAction{TargetType: models.ReportTargetProject, TargetID: reported.ID, Action: models.ActionBan})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint{reported.ID, other.ID, reported.ID}, changed)
+	require.NoError(t, db.First(&owner, owner.ID).Error)
+	assert.NotNil(t, owner.BannedAt)
+	assert.Equal(t, models.ModerationSuspended, moderationStatus(t, db, reported))
+	assert.Equal(t, models.ModerationSuspended, moderationStatus(t, db, other))
+	assert.Equal(t, models.ModerationActive, moderationStatus(t, db, bystander))
+}
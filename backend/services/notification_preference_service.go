@@ -0,0 +1,59 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Notification kinds, named after their NotificationPreferences columns.
+const (
+	NotifyProjectEndingSoon = "project_ending_soon"
+	NotifyProjectFunded     = "project_funded"
+)
+
+type NotificationPreferenceService struct {
+	db *gorm.DB
+}
+
+func NewNotificationPreferenceService(db *gorm.DB) *NotificationPreferenceService {
+	return &NotificationPreferenceService{db: db}
+}
+
+func (s *NotificationPreferenceService) Get(userID uint) (models.NotificationPreferences, error) {
+	var prefs models.NotificationPreferences
+	err := s.db.First(&prefs, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultNotificationPreferences(userID), nil
+	}
+	return prefs, err
+}
+
+// Update changes the preferences that are set in input and leaves the rest.
+func (s *NotificationPreferenceService) Update(userID uint, input models.UpdateNotificationPreferences) (models.NotificationPreferences, error) {
+	prefs, err := s.Get(userID)
+	if err != nil {
+		return prefs, err
+	}
+	if input.ProjectEndingSoon != nil {
+		prefs.ProjectEndingSoon = *input.ProjectEndingSoon
+	}
+	if input.ProjectFunded != nil {
+		prefs.ProjectFunded = *input.ProjectFunded
+	}
+	err = s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"project_ending_soon", "project_funded", "updated_at"}),
+	}).Create(&prefs).Error
+	return prefs, err
+}
+
+// wantsNotification restricts a query joined on users to those who have
+// not turned off the given kind of email.
+func wantsNotification(query *gorm.DB, kind string) *gorm.DB {
+	return query.
+		Joins("LEFT JOIN notification_preferences ON notification_preferences.user_id = users.id").
+		Where("COALESCE(notification_preferences." + kind + ", TRUE)")
+}
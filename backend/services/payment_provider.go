@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"errors"
+)
+
+// Payment intent states, shared by every provider.
+const (
+	PaymentCreated    = "created"    // Waiting for a payment method
+	PaymentAuthorized = "authorized" // Funds held, not yet taken
+	PaymentCaptured   = "captured"
+	PaymentVoided     = "voided" // Authorization released without capture
+	PaymentFailed     = "failed"
+)
+
+// LegacyPaymentProvider is recorded on donations made before payments went
+// through a provider. They have no payment intent, so they cannot be
+// refunded through the platform.
+const LegacyPaymentProvider = "legacy"
+
+var (
+	ErrPaymentDeclined     = errors.New("payment was declined")
+	ErrPaymentState        = errors.New("payment is not in a state that allows this")
+	ErrPaymentNotFound     = errors.New("payment not found")
+	ErrPaymentUnavailable  = errors.New("payment provider is unavailable")
+	ErrRefundExceedsAmount = errors.New("refund exceeds the captured amount")
+)
+
+// PaymentIntent is one attempt to take a payment, tracked by the provider.
+type PaymentIntent struct {
+	ID       string
+	Amount   models.Money
+	Status   string
+	Captured int64 // Minor units captured so far
+	Refunded int64 // Minor units refunded so far
+}
+
+// PaymentRefund is money returned on a captured intent.
+type PaymentRefund struct {
+	ID       string
+	IntentID string
+	Amount   models.Money
+}
+
+// PaymentProvider moves money through a card processor. A payment is
+// created as an intent, authorized with the donor's payment method, then
+// either captured or voided. Captured payments can be refunded, in part or
+// in full.
+//
+// A payment method can only be charged once unless it is first saved with
+// SaveCard; saved cards are charged with AuthorizeOffSession, without the
+// donor present. Card authorizations lapse after about a week, so anything
+// charged later than that, such as a plan's later cycles or a pledge
+// settled when its campaign closes, goes through a saved card.
+//
+// idempotencyKey makes CreateIntent, SaveCard and Refund safe to retry:
+// the provider returns the original result instead of acting twice.
+type PaymentProvider interface {
+	Name() string
+	CreateIntent(ctx context.Context, amount models.Money, metadata map[string]string, idempotencyKey string) (PaymentIntent, error)
+	Authorize(ctx context.Context, intentID, paymentMethod string) (PaymentIntent, error)
+	// SaveCard keeps the payment method on file for off-session charges
+	// and returns the customer it was saved under.
+	SaveCard(ctx context.Context, paymentMethod, idempotencyKey string) (string, error)
+	// AuthorizeOffSession authorizes an intent with a card saved by
+	// SaveCard.
+	AuthorizeOffSession(ctx context.Context, intentID, customer, paymentMethod string) (PaymentIntent, error)
+	// Capture takes amount minor units of an authorized intent; zero
+	// captures the full amount.
+	Capture(ctx context.Context, intentID string, amount int64) (PaymentIntent, error)
+	Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (PaymentRefund, error)
+	Void(ctx context.Context, intentID string) (PaymentIntent, error)
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFakePaymentProvider tests the intent lifecycle and idempotent retries
+func TestFakePaymentProvider(t *testing.T) {
+	ctx := context.Background()
+	p := NewFakePaymentProvider("")
+	amount := models.Money{Amount: 2500, Currency: "EUR"}
+
+	intent, err := p.CreateIntent(ctx, amount, nil, "key-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "pi_fake_1", intent.ID)
+	again, _ := p.CreateIntent(ctx, amount, nil, "key-1")
+	assert.Equal(t, intent.ID, again.ID)
+
+	_, err = p.Capture(ctx, intent.ID, 0)
+	assert.ErrorIs(t, err, ErrPaymentState)
+
+	intent, err = p.Authorize(ctx, intent.ID, "pm_card_visa")
+	assert.NoError(t, err)
+	assert.Equal(t, PaymentAuthorized, intent.Status)
+	intent, err = p.Capture(ctx, intent.ID, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2500), intent.Captured)
+
+	refund, err := p.Refund(ctx, intent.ID, 1000, "refund-1")
+	assert.NoError(t, err)
+	assert.Equal(t, models.Money{Amount: 1000, Currency: "EUR"}, refund.Amount)
+	retried, _ := p.Refund(ctx, intent.ID, 1000, "refund-1")
+	assert.Equal(t, refund.ID, retried.ID)
+	_, err = p.Refund(ctx, intent.ID, 2000, "refund-2")
+	assert.ErrorIs(t, err, ErrRefundExceedsAmount)
+	refund, err = p.Refund(ctx, intent.ID, 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1500), refund.Amount.Amount)
+
+	declined, _ := p.CreateIntent(ctx, amount, nil, "")
+	_, err = p.Authorize(ctx, declined.ID, FakeCardDeclined)
+	assert.ErrorIs(t, err, ErrPaymentDeclined)
+
+	held, _ := p.CreateIntent(ctx, amount, nil, "")
+	p.Authorize(ctx, held.ID, "pm_card_mastercard")
+	held, err = p.Void(ctx, held.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, PaymentVoided, held.Status)
+	_, err = p.Capture(ctx, held.ID, 0)
+	assert.ErrorIs(t, err, ErrPaymentState)
+
+	// A used card cannot be saved; a saved one is only charged off-session
+	// by its customer.
+	_, err = p.SaveCard(ctx, "pm_card_visa", "")
+	assert.ErrorIs(t, err, ErrPaymentDeclined)
+	customer, err := p.SaveCard(ctx, "pm_card_amex", "card-1")
+	assert.NoError(t, err)
+	again, _ = p.CreateIntent(ctx, amount, nil, "")
+	_, err = p.Authorize(ctx, again.ID, "pm_card_amex")
+	assert.ErrorIs(t, err, ErrPaymentDeclined)
+	saved, err := p.SaveCard(ctx, "pm_card_amex", "card-1")
+	assert.NoError(t, err)
+	assert.Equal(t, customer, saved)
+	for i := 0; i < 2; i++ {
+		offSession, _ := p.CreateIntent(ctx, amount, nil, "")
+		offSession, err = p.AuthorizeOffSession(ctx, offSession.ID, customer, "pm_card_amex")
+		assert.NoError(t, err)
+		assert.Equal(t, PaymentAuthorized, offSession.Status)
+	}
+	stranger, _ := p.CreateIntent(ctx, amount, nil, "")
+	_, err = p.AuthorizeOffSession(ctx, stranger.ID, "cus_fake_missing", "pm_card_amex")
+	assert.ErrorIs(t, err, ErrPaymentDeclined)
+}
+
+// TestStripePaymentProvider tests the requests sent to Stripe and how its errors map
+func TestStripePaymentProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer sk_test", r.Header.Get("Authorization"))
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/v1/payment_intents":
+			assert.Equal(t, "manual", r.PostForm.Get("capture_method"))
+			assert.Equal(t, "jpy", r.PostForm.Get("currency"))
+			assert.Equal(t, "7", r.PostForm.Get("metadata[project_id]"))
+			assert.Equal(t, "donation-1", r.Header.Get("Idempotency-Key"))
+			w.Write([]byte(`{"id": "pi_1", "amount": 1500, "currency": "jpy", "status": "requires_payment_method"}`))
+		case "/v1/payment_intents/pi_1/confirm":
+			if r.PostForm.Get("payment_method") == "pm_card_chargeDeclined" {
+				w.WriteHeader(http.StatusPaymentRequired)
+				w.Write([]byte(`{"error": {"type": "card_error", "code": "card_declined", "message": "Your card was declined."}}`))
+				return
+			}
+			w.Write([]byte(`{"id": "pi_1", "amount": 1500, "currency": "jpy", "status": "requires_capture"}`))
+		case "/v1/payment_intents/pi_1/capture":
+			w.Write([]byte(`{"id": "pi_1", "amount": 1500, "amount_received": 1500, "currency": "jpy", "status": "succeeded"}`))
+		case "/v1/customers":
+			assert.Equal(t, "card-1-customer", r.Header.Get("Idempotency-Key"))
+			w.Write([]byte(`{"id": "cus_1"}`))
+		case "/v1/setup_intents":
+			assert.Equal(t, "card-1-setup", r.Header.Get("Idempotency-Key"))
+			assert.Equal(t, "cus_1", r.PostForm.Get("customer"))
+			assert.Equal(t, "off_session", r.PostForm.Get("usage"))
+			assert.Equal(t, "true", r.PostForm.Get("confirm"))
+			if r.PostForm.Get("payment_method") == "pm_card_authenticationRequired" {
+				w.Write([]byte(`{"id": "seti_2", "status": "requires_action"}`))
+				return
+			}
+			w.Write([]byte(`{"id": "seti_1", "status": "succeeded"}`))
+		case "/v1/payment_intents/pi_2":
+			assert.Equal(t, "cus_1", r.PostForm.Get("customer"))
+			w.Write([]byte(`{"id": "pi_2", "amount": 1500, "currency": "jpy", "status": "requires_payment_method"}`))
+		case "/v1/payment_intents/pi_2/confirm":
+			assert.Equal(t, "true", r.PostForm.Get("off_session"))
+			assert.Equal(t, "pm_card_visa", r.PostForm.Get("payment_method"))
+			w.Write([]byte(`{"id": "pi_2", "amount": 1500, "currency": "jpy", "status": "requires_capture"}`))
+		case "/v1/refunds":
+			assert.Equal(t, "pi_1", r.PostForm.Get("payment_intent"))
+			assert.Equal(t, "500", r.PostForm.Get("amount"))
+			w.Write([]byte(`{"id": "re_1", "amount": 500, "currency": "jpy", "payment_intent": "pi_1"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"type": "invalid_request_error", "code": "resource_missing"}}`))
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	p := NewStripePaymentProvider(server.URL, "sk_test", "")
+	intent, err := p.CreateIntent(ctx, models.Money{Amount: 1500, Currency: "JPY"}, map[string]string{"project_id": "7"}, "donation-1")
+	assert.NoError(t, err)
+	assert.Equal(t, PaymentIntent{ID: "pi_1", Amount: models.Money{Amount: 1500, Currency: "JPY"}, Status: PaymentCreated}, intent)
+
+	_, err = p.Authorize(ctx, "pi_1", "pm_card_chargeDeclined")
+	assert.ErrorIs(t, err, ErrPaymentDeclined)
+	intent, err = p.Authorize(ctx, "pi_1", "pm_card_visa")
+	assert.NoError(t, err)
+	assert.Equal(t, PaymentAuthorized, intent.Status)
+
+	intent, err = p.Capture(ctx, "pi_1", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, PaymentCaptured, intent.Status)
+	assert.Equal(t, int64(1500), intent.Captured)
+
+	refund, err := p.Refund(ctx, "pi_1", 500, "")
+	assert.NoError(t, err)
+	assert.Equal(t, PaymentRefund{ID: "re_1", IntentID: "pi_1", Amount: models.Money{Amount: 500, Currency: "JPY"}}, refund)
+
+	_, err = p.Void(ctx, "pi_missing")
+	assert.ErrorIs(t, err, ErrPaymentNotFound)
+
+	customer, err := p.SaveCard(ctx, "pm_card_visa", "card-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "cus_1", customer)
+	_, err = p.SaveCard(ctx, "pm_card_authenticationRequired", "card-1")
+	assert.ErrorIs(t, err, ErrPaymentDeclined)
+	intent, err = p.AuthorizeOffSession(ctx, "pi_2", customer, "pm_card_visa")
+	assert.NoError(t, err)
+	assert.Equal(t, PaymentAuthorized, intent.Status)
+}
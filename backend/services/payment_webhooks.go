@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/jobs"
+	"crowdfund/backend/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PaymentRefunded is set by webhooks once a payment is refunded in full.
+const PaymentRefunded = "refunded"
+
+// webhookTolerance bounds how old a signed timestamp may be, to stop
+// replays of captured requests.
+const webhookTolerance = 5 * time.Minute
+
+// ApplyPaymentEventJob applies a stored webhook event, by ID, to its
+// donation.
+const ApplyPaymentEventJob jobs.Kind[uint] = "payment_events"
+
+var (
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+	ErrInvalidWebhookPayload   = errors.New("invalid webhook payload")
+	ErrUnknownPaymentProvider  = errors.New("unknown payment provider")
+	errDonationNotYetSaved     = errors.New("no donation for this payment yet")
+)
+
+// WebhookEvent is the part of a provider's webhook we act on.
+type WebhookEvent struct {
+	ID       string
+	Type     string
+	IntentID string
+	Status   string // One of the Payment* states, or empty to ignore
+}
+
+// PaymentWebhookVerifier is implemented by providers that send webhooks. It
+// checks the signature on a delivery and parses it.
+type PaymentWebhookVerifier interface {
+	ParseWebhook(header http.Header, body []byte, now time.Time) (WebhookEvent, error)
+}
+
+// paymentTransitions lists the states a donation's payment may move to from
+// each state. Webhooks can arrive out of order, so anything else is
+// ignored rather than applied.
+var paymentTransitions = map[string][]string{
+	PaymentCreated:    {PaymentAuthorized, PaymentCaptured, PaymentFailed, PaymentVoided},
+	PaymentAuthorized: {PaymentCaptured, PaymentFailed, PaymentVoided},
+	PaymentCaptured:   {PaymentRefunded},
+}
+
+// donationStatusForPayment is the donation status each final payment
+// state leads to.
+var donationStatusForPayment = map[string]string{
+	PaymentCaptured: models.DonationSucceeded,
+	PaymentFailed:   models.DonationFailed,
+	PaymentVoided:   models.DonationFailed,
+	PaymentRefunded: models.DonationRefunded,
+}
+
+func canTransitionPayment(from, to string) bool {
+	for _, allowed := range paymentTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+type PaymentEventService struct {
+	db        *gorm.DB
+	verifiers map[string]PaymentWebhookVerifier
+	queue     jobs.Queue
+}
+
+func NewPaymentEventService(db *gorm.DB, verifiers map[string]PaymentWebhookVerifier, queue jobs.Queue) *PaymentEventService {
+	return &PaymentEventService{db: db, verifiers: verifiers, queue: queue}
+}
+
+// Receive verifies and stores a webhook delivery and queues it for
+// processing. Redeliveries of an event already stored return duplicate and
+// are not queued again.
+func (s *PaymentEventService) Receive(ctx context.Context, provider string, header http.Header, body []byte) (duplicate bool, err error) {
+	verifier, ok := s.verifiers[provider]
+	if !ok {
+		return false, ErrUnknownPaymentProvider
+	}
+	parsed, err := verifier.ParseWebhook(header, body, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	event := models.PaymentEvent{
+		Provider:  provider,
+		EventID:   parsed.ID,
+		EventType: parsed.Type,
+		IntentID:  parsed.IntentID,
+		Status:    parsed.Status,
+		Payload:   string(body),
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&event)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			duplicate = true
+			return nil
+		}
+		return ApplyPaymentEventJob.Enqueue(ctx, s.queue, tx, event.ID)
+	})
+	return duplicate, err
+}
+
+// ApplyHandler returns the handler for ApplyPaymentEventJob. Events whose
+// donation is not saved yet fail and are retried with the queue's backoff.
+func (s *PaymentEventService) ApplyHandler(workers int) jobs.Handler[uint] {
+	return jobs.Handler[uint]{
+		Workers: workers,
+		Run: func(ctx context.Context, eventID uint) error {
+			return applyPaymentEvent(s.db, eventID)
+		},
+	}
+}
+
+// applyPaymentEvent moves the event's donation to the event's payment
+// status. The event row is locked so a retry and a redelivery cannot apply
+// it twice.
+func applyPaymentEvent(db *gorm.DB, eventID uint) error {
+	var applyErr error
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var event models.PaymentEvent
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, eventID).Error
+		if err != nil {
+			// Not found is retried: the Redis queue can deliver the job
+			// before the event commits.
+			return err
+		}
+		if event.ProcessedAt != nil {
+			return nil
+		}
+
+		note, err := transitionDonation(tx, event)
+		if err != nil {
+			// Record the failure and commit it; the job is retried.
+			applyErr = err
+			return tx.Model(&event).Updates(map[string]interface{}{
+				"attempts":   gorm.Expr("attempts + 1"),
+				"last_error": err.Error(),
+			}).Error
+		}
+		return tx.Model(&event).Updates(map[string]interface{}{
+			"processed_at": time.Now(),
+			"attempts":     gorm.Expr("attempts + 1"),
+			"last_error":   note,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	return applyErr
+}
+
+// transitionDonation applies the event to its donation. Events that do not
+// change anything are recorded with a note instead of an error so they are
+// not retried.
+func transitionDonation(tx *gorm.DB, event models.PaymentEvent) (string, error) {
+	if event.Status == "" {
+		return "ignored: event type not handled", nil
+	}
+	var donation models.Donation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("payment_provider = ? AND payment_intent_id = ?", event.Provider, event.IntentID).
+		First(&donation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", errDonationNotYetSaved
+	}
+	if err != nil {
+		return "", err
+	}
+	if donation.PaymentStatus == event.Status {
+		return "", nil
+	}
+	if !canTransitionPayment(donation.PaymentStatus, event.Status) {
+		return fmt.Sprintf("ignored: %s -> %s", donation.PaymentStatus, event.Status), nil
+	}
+	updates := map[string]interface{}{"payment_status": event.Status}
+	// While a worker is charging the donation it records the outcome
+	// itself; afterwards, webhooks are how refunds and late failures
+	// reach the donation's status.
+	if status, ok := donationStatusForPayment[event.Status]; ok &&
+		donation.Status != models.DonationQueued && donation.Status != models.DonationProcessing {
+		updates["status"] = status
+	}
+	return "", tx.Model(&donation).Updates(updates).Error
+}
+
+// verifyHMAC compares a hex HMAC-SHA256 signature in constant time. Nothing
+// verifies against an empty secret, since anyone could sign with it.
+func verifyHMAC(secret, payload []byte, signature string) bool {
+	if len(secret) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
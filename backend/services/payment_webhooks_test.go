@@ -0,0 +1,112 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// TestStripeParseWebhook tests signature checks and event mapping
+func TestStripeParseWebhook(t *testing.T) {
+	p := NewStripePaymentProvider("", "sk_test", "whsec_test")
+	now := time.Unix(1760000000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	body := `{"id":"evt_1","type":"payment_intent.succeeded","data":{"object":{"id":"pi_1"}}}`
+
+	header := http.Header{}
+	header.Set("Stripe-Signature", "t="+ts+",v1=deadbeef,v1="+sign("whsec_test", ts+"."+body))
+	event, err := p.ParseWebhook(header, []byte(body), now)
+	assert.NoError(t, err)
+	assert.Equal(t, WebhookEvent{ID: "evt_1", Type: "payment_intent.succeeded", IntentID: "pi_1", Status: PaymentCaptured}, event)
+
+	// Replayed outside the tolerance window.
+	_, err = p.ParseWebhook(header, []byte(body), now.Add(10*time.Minute))
+	assert.ErrorIs(t, err, ErrInvalidWebhookSignature)
+
+	// Tampered body.
+	_, err = p.ParseWebhook(header, []byte(`{"id":"evt_2"}`), now)
+	assert.ErrorIs(t, err, ErrInvalidWebhookSignature)
+
+	// Refunds name the intent on the charge; partial refunds are ignored.
+	body = `{"id":"evt_3","type":"charge.refunded","data":{"object":{"id":"ch_1","payment_intent":"pi_1","refunded":false}}}`
+	header.Set("Stripe-Signature", "t="+ts+",v1="+sign("whsec_test", ts+"."+body))
+	event, err = p.ParseWebhook(header, []byte(body), now)
+	assert.NoError(t, err)
+	assert.Equal(t, "pi_1", event.IntentID)
+	assert.Equal(t, "", event.Status)
+}
+
+// TestFakeParseWebhook tests the fake provider's signed events
+func TestFakeParseWebhook(t *testing.T) {
+	p := NewFakePaymentProvider("secret")
+	body := `{"id":"evt_1","type":"payment.failed","intent_id":"pi_fake_1","status":"failed"}`
+	header := http.Header{}
+	header.Set("X-Webhook-Signature", sign("secret", body))
+
+	event, err := p.ParseWebhook(header, []byte(body), time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "pi_fake_1", event.IntentID)
+	assert.Equal(t, PaymentFailed, event.Status)
+
+	header.Set("X-Webhook-Signature", sign("other", body))
+	_, err = p.ParseWebhook(header, []byte(body), time.Now())
+	assert.ErrorIs(t, err, ErrInvalidWebhookSignature)
+
+	// Without a secret nothing verifies, not even an empty-key signature.
+	p = NewFakePaymentProvider("")
+	header.Set("X-Webhook-Signature", sign("", body))
+	_, err = p.ParseWebhook(header, []byte(body), time.Now())
+	assert.ErrorIs(t, err, ErrInvalidWebhookSignature)
+}
+
+// TestApplyPaymentEvent_RecordsFailures tests that failed attempts are kept, so the queue's attempt limit is reached
+func TestApplyPaymentEvent_RecordsFailures(t *testing.T) {
+	db := newTestDB(t)
+	event := models.PaymentEvent{Provider: "fake", EventID: "evt_1", IntentID: "pi_late", Status: PaymentCaptured}
+	require.NoError(t, db.Create(&event).Error)
+
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, applyPaymentEvent(db, event.ID), errDonationNotYetSaved)
+	}
+	require.NoError(t, db.First(&event, event.ID).Error)
+	assert.Equal(t, 3, event.Attempts)
+	assert.Equal(t, errDonationNotYetSaved.Error(), event.LastError)
+	assert.Nil(t, event.ProcessedAt)
+
+	owner := createTestUser(t, db, "owner")
+	donation := createTestDonation(t, db, createTestProject(t, db, owner), owner, 500, models.DonationAuthorized)
+	require.NoError(t, db.Model(&donation).Updates(map[string]interface{}{
+		"payment_intent_id": "pi_late",
+		"payment_status":    PaymentAuthorized,
+	}).Error)
+	require.NoError(t, applyPaymentEvent(db, event.ID))
+	require.NoError(t, db.First(&event, event.ID).Error)
+	assert.Equal(t, 4, event.Attempts)
+	assert.NotNil(t, event.ProcessedAt)
+	require.NoError(t, db.First(&donation, donation.ID).Error)
+	assert.Equal(t, PaymentCaptured, donation.PaymentStatus)
+	assert.Equal(t, models.DonationSucceeded, donation.Status)
+}
+
+// TestCanTransitionPayment tests that out-of-order events cannot move a payment backwards
+func TestCanTransitionPayment(t *testing.T) {
+	assert.True(t, canTransitionPayment(PaymentAuthorized, PaymentCaptured))
+	assert.True(t, canTransitionPayment(PaymentCaptured, PaymentRefunded))
+	assert.False(t, canTransitionPayment(PaymentCaptured, PaymentAuthorized))
+	assert.False(t, canTransitionPayment(PaymentRefunded, PaymentCaptured))
+	assert.False(t, canTransitionPayment(PaymentFailed, PaymentCaptured))
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPreviewTTL = 72 * time.Hour
+	MaxPreviewTTL     = 30 * 24 * time.Hour
+)
+
+var ErrInvalidPreviewToken = errors.New("preview link is invalid, expired or revoked")
+
+// ProjectPreviewService issues and checks signed links to draft projects.
+type ProjectPreviewService struct {
+	db     *gorm.DB
+	secret []byte
+}
+
+func NewProjectPreviewService(db *gorm.DB, secret string) *ProjectPreviewService {
+	return &ProjectPreviewService{db: db, secret: []byte(secret)}
+}
+
+// Create issues a preview link for the project that expires after ttl.
+func (s *ProjectPreviewService) Create(projectID, userID uint, ttl time.Duration) (models.ProjectPreview, error) {
+	preview := models.ProjectPreview{
+		ProjectID: projectID,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(ttl).Truncate(time.Second),
+	}
+	if err := s.db.Create(&preview).Error; err != nil {
+		return models.ProjectPreview{}, err
+	}
+	preview.Token = s.token(preview)
+	preview.URL = fmt.Sprintf("/api/projects/%d?preview=%s", projectID, preview.Token)
+	return preview, nil
+}
+
+// List returns the project's preview links, newest first. Tokens are not
+// included.
+func (s *ProjectPreviewService) List(projectID uint64) ([]models.ProjectPreview, error) {
+	var previews []models.ProjectPreview
+	err := s.db.Where("project_id = ?", projectID).Order("created_at DESC").Find(&previews).Error
+	return previews, err
+}
+
+// Revoke disables a preview link immediately.
+func (s *ProjectPreviewService) Revoke(projectID, previewID uint64) error {
+	result := s.db.Model(&models.ProjectPreview{}).
+		Where("id = ? AND project_id = ? AND revoked_at IS NULL", previewID, projectID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Verify checks that token is a live preview link for the project.
+func (s *ProjectPreviewService) Verify(projectID uint64, token string) error {
+	previewID, err := s.checkToken(projectID, token, time.Now())
+	if err != nil {
+		return err
+	}
+	var count int64
+	err = s.db.Model(&models.ProjectPreview{}).
+		Where("id = ? AND project_id = ? AND revoked_at IS NULL", previewID, projectID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrInvalidPreviewToken
+	}
+	return nil
+}
+
+// token has the form "<preview id>.<expiry unix>.<signature>".
+func (s *ProjectPreviewService) token(preview models.ProjectPreview) string {
+	id := strconv.FormatUint(uint64(preview.ID), 10)
+	exp := strconv.FormatInt(preview.ExpiresAt.Unix(), 10)
+	return id + "." + exp + "." + s.sign(uint64(preview.ProjectID), id, exp)
+}
+
+// checkToken verifies the signature and expiry of a token without touching
+// the database, returning the preview ID it was issued for.
+func (s *ProjectPreviewService) checkToken(projectID uint64, token string, now time.Time) (uint64, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, ErrInvalidPreviewToken
+	}
+	previewID, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidPreviewToken
+	}
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() > exp {
+		return 0, ErrInvalidPreviewToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(s.sign(projectID, parts[0], parts[1]))) {
+		return 0, ErrInvalidPreviewToken
+	}
+	return previewID, nil
+}
+
+func (s *ProjectPreviewService) sign(projectID uint64, previewID, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("project-preview\n" + strconv.FormatUint(projectID, 10) + "\n" + previewID + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
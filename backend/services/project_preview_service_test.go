@@ -0,0 +1,35 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPreviewToken tests that preview tokens are bound to their project and expiry
+func TestPreviewToken(t *testing.T) {
+	s := NewProjectPreviewService(nil, "test-secret")
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	token := s.token(models.ProjectPreview{ID: 7, ProjectID: 3, ExpiresAt: now.Add(time.Hour)})
+
+	previewID, err := s.checkToken(3, token, now)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), previewID)
+
+	_, err = s.checkToken(4, token, now)
+	assert.ErrorIs(t, err, ErrInvalidPreviewToken)
+
+	_, err = s.checkToken(3, token, now.Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrInvalidPreviewToken)
+
+	other := NewProjectPreviewService(nil, "other-secret")
+	_, err = other.checkToken(3, token, now)
+	assert.ErrorIs(t, err, ErrInvalidPreviewToken)
+
+	for _, bad := range []string{"", "7", "7.x.sig", token + "0"} {
+		_, err = s.checkToken(3, bad, now)
+		assert.ErrorIs(t, err, ErrInvalidPreviewToken, bad)
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"encoding/json"
+	"log"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// snapshotFields lists ProjectSnapshot's JSON fields in display order.
+var snapshotFields = []string{"title", "description", "goal", "start_date", "end_date", "category_id", "comments_backers_only", "funding_mode"}
+
+// materialFields are changes backers are emailed about because they affect
+// what was pledged to.
+var materialFields = map[string]bool{"goal": true, "end_date": true}
+
+type ProjectRevisionService struct {
+	db              *gorm.DB
+	donationService *DonationService
+	emailService    *EmailService
+}
+
+func NewProjectRevisionService(db *gorm.DB, donationService *DonationService, emailService *EmailService) *ProjectRevisionService {
+	return &ProjectRevisionService{db: db, donationService: donationService, emailService: emailService}
+}
+
+func (s *ProjectRevisionService) ListRevisions(projectID uint64) ([]models.ProjectRevision, error) {
+	var revisions []models.ProjectRevision
+	err := s.db.Where("project_id = ?", projectID).Order("version DESC").Find(&revisions).Error
+	return revisions, err
+}
+
+// Diff compares two stored versions of a project.
+func (s *ProjectRevisionService) Diff(projectID uint64, fromVersion, toVersion int) (models.RevisionDiff, error) {
+	var from, to models.ProjectRevision
+	if err := s.db.Where("project_id = ? AND version = ?", projectID, fromVersion).First(&from).Error; err != nil {
+		return models.RevisionDiff{}, err
+	}
+	if err := s.db.Where("project_id = ? AND version = ?", projectID, toVersion).First(&to).Error; err != nil {
+		return models.RevisionDiff{}, err
+	}
+	return models.RevisionDiff{
+		ProjectID:   uint(projectID),
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+		Changes:     DiffSnapshots(from.Snapshot, to.Snapshot),
+	}, nil
+}
+
+// NotifyMaterialChanges emails every backer if the revision touched a
+// material field such as the goal or end date.
+func (s *ProjectRevisionService) NotifyMaterialChanges(project models.Project, changes []models.FieldChange) {
+	var material []models.FieldChange
+	for _, change := range changes {
+		if materialFields[change.Field] {
+			material = append(material, change)
+		}
+	}
+	if len(material) == 0 {
+		return
+	}
+
+	emails, err := s.donationService.BackerEmails(uint64(project.ID))
+	if err != nil {
+		log.Printf("Error loading backers for project %d: %v", project.ID, err)
+		return
+	}
+	for _, to := range emails {
+		s.emailService.SendProjectChanged(to, project, material)
+	}
+}
+
+// recordRevision stores the project's state at its current version. It is
+// called inside the transaction that writes the project.
+func recordRevision(tx *gorm.DB, project models.Project, editorID uint, changes []models.FieldChange) error {
+	changed := make(models.StringList, len(changes))
+	for i, change := range changes {
+		changed[i] = change.Field
+	}
+	return tx.Create(&models.ProjectRevision{
+		ProjectID:     project.ID,
+		Version:       project.Version,
+		UserID:        editorID,
+		Snapshot:      models.SnapshotOf(project),
+		ChangedFields: changed,
+	}).Error
+}
+
+// DiffSnapshots returns the fields that differ between two snapshots.
+func DiffSnapshots(from, to models.ProjectSnapshot) []models.FieldChange {
+	before, after := snapshotMap(from), snapshotMap(to)
+	changes := []models.FieldChange{}
+	for _, field := range snapshotFields {
+		if !reflect.DeepEqual(before[field], after[field]) {
+			changes = append(changes, models.FieldChange{Field: field, From: before[field], To: after[field]})
+		}
+	}
+	return changes
+}
+
+func snapshotMap(snapshot models.ProjectSnapshot) map[string]interface{} {
+	fields := map[string]interface{}{}
+	b, err := json.Marshal(snapshot)
+	if err == nil {
+		err = json.Unmarshal(b, &fields)
+	}
+	if err != nil {
+		log.Printf("Error converting project snapshot: %v", err)
+	}
+	return fields
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDiffSnapshots tests that only changed fields are reported, in display order
+func TestDiffSnapshots(t *testing.T) {
+	end := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+	from := models.ProjectSnapshot{Title: "Board game", Goal: models.Money{Amount: 100000, Currency: "USD"}, EndDate: end}
+	to := from
+	to.Goal.Amount = 150000
+	to.EndDate = end.In(time.FixedZone("CET", 3600)) // same instant, different zone
+	to.Description = "Now with more dice"
+
+	changes := DiffSnapshots(from, models.SnapshotOf(models.Project{
+		Title: to.Title, Description: to.Description, Goal: to.Goal, EndDate: to.EndDate,
+	}))
+
+	assert.Equal(t, []models.FieldChange{
+		{Field: "description", From: "", To: "Now with more dice"},
+		{Field: "goal",
+			From: map[string]interface{}{"amount": "1000.00", "currency": "USD"},
+			To:   map[string]interface{}{"amount": "1500.00", "currency": "USD"}},
+	}, changes)
+}
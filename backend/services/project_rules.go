@@ -0,0 +1,83 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrVersionConflict = errors.New("project was modified by someone else")
+
+// FieldErrors maps JSON field names to what is wrong with them.
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = field + ": " + e[field]
+	}
+	return strings.Join(parts, "; ")
+}
+
+// EditableProjectFields are the JSON fields a creator may change after a
+// project has been created.
+var EditableProjectFields = map[string]bool{
+	"title":                 true,
+	"slug":                  true,
+	"description":           true,
+	"goal":                  true,
+	"start_date":            true,
+	"end_date":              true,
+	"category_id":           true,
+	"comments_backers_only": true,
+	"funding_mode":          true,
+}
+
+// ValidateProjectChange checks the rules for moving a project from current
+// to next. Once a campaign is live its goal cannot be lowered and its start
+// date and funding mode are fixed, so backers are not misled about what
+// they pledged to.
+func ValidateProjectChange(current, next models.Project, now time.Time) FieldErrors {
+	errs := FieldErrors{}
+	live := !current.StartDate.IsZero() && !now.Before(current.StartDate)
+
+	if strings.TrimSpace(next.Title) == "" {
+		errs["title"] = "must not be empty"
+	}
+	if next.Goal.Amount <= 0 {
+		errs["goal"] = "must be greater than zero"
+	} else if live && next.Goal.Currency != current.Goal.Currency {
+		errs["goal"] = "currency cannot be changed once the campaign is live"
+	} else if live && next.Goal.Amount < current.Goal.Amount {
+		errs["goal"] = "cannot be lowered once the campaign is live"
+	}
+	if live && !next.StartDate.Equal(current.StartDate) {
+		errs["start_date"] = "cannot be changed once the campaign is live"
+	}
+	if !next.EndDate.After(next.StartDate) {
+		errs["end_date"] = "must be after start_date"
+	} else if current.ClosedAt != nil && !next.EndDate.Equal(current.EndDate) {
+		errs["end_date"] = "cannot be changed once the campaign has closed"
+	} else if live && !next.EndDate.Equal(current.EndDate) && next.EndDate.Before(now) {
+		errs["end_date"] = "cannot be moved into the past"
+	}
+	if next.FundingMode != current.FundingMode {
+		if next.FundingMode != models.FundingFlexible && next.FundingMode != models.FundingAllOrNothing {
+			errs["funding_mode"] = "must be one of: flexible, all_or_nothing"
+		} else if live {
+			errs["funding_mode"] = "cannot be changed once the campaign is live"
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
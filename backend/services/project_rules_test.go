@@ -0,0 +1,58 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestValidateProjectChange tests the rules that apply once a campaign is live
+func TestValidateProjectChange(t *testing.T) {
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	current := models.Project{
+		Title:     "Board game",
+		Goal:      models.Money{Amount: 100000, Currency: "USD"},
+		StartDate: now.Add(-24 * time.Hour),
+		EndDate:   now.Add(30 * 24 * time.Hour),
+	}
+
+	next := current
+	next.Description = "Now with more dice"
+	next.Goal.Amount = 150000
+	assert.Nil(t, ValidateProjectChange(current, next, now))
+
+	next = current
+	next.Goal.Amount = 50000
+	next.StartDate = now
+	errs := ValidateProjectChange(current, next, now)
+	assert.Contains(t, errs, "goal")
+	assert.Contains(t, errs, "start_date")
+
+	next = current
+	next.EndDate = now.Add(-time.Hour)
+	assert.Contains(t, ValidateProjectChange(current, next, now), "end_date")
+
+	// Before launch the creator may still lower the goal or move the start.
+	draft := current
+	draft.StartDate = now.Add(24 * time.Hour)
+	next = draft
+	next.Goal.Amount = 50000
+	next.StartDate = now.Add(48 * time.Hour)
+	next.FundingMode = models.FundingAllOrNothing
+	assert.Nil(t, ValidateProjectChange(draft, next, now))
+
+	next = current
+	next.FundingMode = models.FundingAllOrNothing
+	assert.Contains(t, ValidateProjectChange(current, next, now), "funding_mode")
+	next.FundingMode = "sometimes"
+	assert.Contains(t, ValidateProjectChange(draft, next, now), "funding_mode")
+
+	// A closed campaign cannot be reopened by moving its end date.
+	closed := current
+	closed.ClosedAt = &now
+	next = closed
+	next.EndDate = now.Add(60 * 24 * time.Hour)
+	assert.Contains(t, ValidateProjectChange(closed, next, now), "end_date")
+}
@@ -2,10 +2,15 @@ package services
 
 import (
     "crowdfund/backend/models"
+    "errors"
+    "time"
 
     "gorm.io/gorm"
+    "gorm.io/gorm/clause"
 )
 
+var ErrProjectHasDonations = errors.New("project holds donations that have not been refunded")
+
 type ProjectService struct {
     db *gorm.DB
 }
@@ -15,25 +20,169 @@ func NewProjectService(db *gorm.DB) *ProjectService {
 }
 
 func (s *ProjectService) CreateProject(project *models.Project) error {
-    return s.db.Create(project).Error
+    if project.FundingMode == "" {
+        project.FundingMode = models.FundingFlexible
+    }
+    return s.db.Transaction(func(tx *gorm.DB) error {
+        if project.Slug != "" {
+            if err := changeSlug(tx, 0, "", project.Slug); err != nil {
+                return err
+            }
+        } else {
+            slug, err := uniqueSlug(tx, Slugify(project.Title), 0)
+            if err != nil {
+                return err
+            }
+            project.Slug = slug
+        }
+        if err := tx.Create(project).Error; err != nil {
+            return err
+        }
+        if project.Version == 0 {
+            project.Version = 1
+        }
+        return recordRevision(tx, *project, project.UserID, nil)
+    })
 }
 
+// GetProject loads a project whatever its moderation status, so creators
+// and admins can still reach projects taken down by moderators. Handlers
+// serving the public must check the status themselves.
 func (s *ProjectService) GetProject(id uint64) (models.Project, error) {
     var project models.Project
-    err := s.db.First(&project, id).Error
+    err := s.db.Preload("Tags").Preload("Media").First(&project, id).Error
     return project, err
 }
 
-func (s *ProjectService) UpdateProject(project *models.Project) error {
-    return s.db.Save(project).Error
+// UpdateProject writes the editable fields of next over current and records
+// a revision. The write only succeeds if the row is still at
+// current.Version; otherwise someone else saved in between and
+// ErrVersionConflict is returned. It returns the fields that changed.
+func (s *ProjectService) UpdateProject(current models.Project, next *models.Project, editorID uint) ([]models.FieldChange, error) {
+    if next.FundingMode == "" {
+        next.FundingMode = current.FundingMode
+    }
+    if errs := ValidateProjectChange(current, *next, time.Now()); errs != nil {
+        return nil, errs
+    }
+    changes := DiffSnapshots(models.SnapshotOf(current), models.SnapshotOf(*next))
+    if next.Slug == "" {
+        next.Slug = current.Slug
+    }
+
+    err := s.db.Transaction(func(tx *gorm.DB) error {
+        if next.Slug != current.Slug {
+            if err := changeSlug(tx, current.ID, current.Slug, next.Slug); err != nil {
+                return err
+            }
+        }
+        result := tx.Model(&models.Project{}).
+            Where("id = ? AND version = ?", current.ID, current.Version).
+            Updates(map[string]interface{}{
+                "title":                 next.Title,
+                "slug":                  next.Slug,
+                "description":           next.Description,
+                "goal_amount":           next.Goal.Amount,
+                "goal_currency":         next.Goal.Currency,
+                "start_date":            next.StartDate,
+                "end_date":              next.EndDate,
+                "category_id":           next.CategoryID,
+                "comments_backers_only": next.CommentsBackersOnly,
+                "funding_mode":          next.FundingMode,
+                "version":               gorm.Expr("version + 1"),
+            })
+        if result.Error != nil {
+            return result.Error
+        }
+        if result.RowsAffected == 0 {
+            return ErrVersionConflict
+        }
+
+        // Projects created before revisions were tracked have no row for
+        // their starting version; add one so diffs against it work.
+        baseline := models.ProjectRevision{
+            ProjectID: current.ID,
+            Version:   current.Version,
+            UserID:    current.UserID,
+            Snapshot:  models.SnapshotOf(current),
+        }
+        if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&baseline).Error; err != nil {
+            return err
+        }
+
+        next.ID = current.ID
+        next.UserID = current.UserID
+        next.Version = current.Version + 1
+        return recordRevision(tx, *next, editorID, changes)
+    })
+    if err != nil {
+        return nil, err
+    }
+    return changes, nil
+}
+
+// DeleteProject soft-deletes the project. Projects holding donations are
+// kept so the donation records stay attached to them; failed and refunded
+// donations do not count, so a cancelled project can be deleted once its
+// refunds have gone through. Legacy donations, which the platform cannot
+// refund, count too unless allowLegacy is set: only admins may set it,
+// once they have settled those donations by hand.
+func (s *ProjectService) DeleteProject(id uint64, allowLegacy bool) error {
+    return s.db.Transaction(func(tx *gorm.DB) error {
+        var donations int64
+        query := tx.Model(&models.Donation{}).
+            Where("project_id = ? AND status NOT IN ?", id, []string{models.DonationFailed, models.DonationRefunded})
+        if allowLegacy {
+            query = query.Where("payment_provider <> ?", LegacyPaymentProvider)
+        }
+        err := query.Count(&donations).Error
+        if err != nil {
+            return err
+        }
+        if donations > 0 {
+            return ErrProjectHasDonations
+        }
+        result := tx.Delete(&models.Project{}, id)
+        if result.Error == nil && result.RowsAffected == 0 {
+            return gorm.ErrRecordNotFound
+        }
+        return result.Error
+    })
+}
+
+// RestoreProject undoes a soft delete.
+func (s *ProjectService) RestoreProject(id uint64) error {
+    result := s.db.Unscoped().Model(&models.Project{}).
+        Where("id = ? AND deleted_at IS NOT NULL", id).
+        Update("deleted_at", nil)
+    if result.Error == nil && result.RowsAffected == 0 {
+        return gorm.ErrRecordNotFound
+    }
+    return result.Error
 }
 
-func (s *ProjectService) DeleteProject(id uint64) error {
-    return s.db.Delete(&models.Project{}, id).Error
+// listedProjects narrows a projects query to those shown in public
+// listings: launched and not hidden by moderation.
+func listedProjects(now time.Time) func(*gorm.DB) *gorm.DB {
+    return func(db *gorm.DB) *gorm.DB {
+        return db.Where("moderation_status = ? AND start_date <= ?", models.ModerationActive, now)
+    }
 }
 
-func (s *ProjectService) ListProjects() ([]models.Project, error) {
+// ListProjects returns projects matching the filter. filter.CategoryIDs should
+// already include subcategories of the requested category.
+func (s *ProjectService) ListProjects(filter models.ProjectFilter) ([]models.Project, error) {
     var projects []models.Project
-    err := s.db.Find(&projects).Error
+    query := s.db.Preload("Tags").Preload("Media").Scopes(listedProjects(time.Now()))
+    if len(filter.CategoryIDs) > 0 {
+        query = query.Where("category_id IN ?", filter.CategoryIDs)
+    }
+    if filter.Tag != "" {
+        query = query.Where("id IN (?)", s.db.Table("project_tags").
+            Select("project_tags.project_id").
+            Joins("JOIN tags ON tags.id = project_tags.tag_id").
+            Where("tags.name = ?", filter.Tag))
+    }
+    err := query.Find(&projects).Error
     return projects, err
 }
\ No newline at end of file
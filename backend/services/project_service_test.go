@@ -0,0 +1,84 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// TestDeleteProject tests that deleted projects disappear until an admin restores them
+func TestDeleteProject(t *testing.T) {
+	db := newTestDB(t)
+	service := NewProjectService(db)
+	owner := createTestUser(t, db, "owner")
+	project := createTestProject(t, db, owner)
+	kept := createTestProject(t, db, owner)
+	id := uint64(project.ID)
+
+	require.NoError(t, service.DeleteProject(id, false))
+	_, err := service.GetProject(id)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	projects, err := service.ListProjects(models.ProjectFilter{})
+	require.NoError(t, err)
+	require.Len(t, projects, 1)
+	assert.Equal(t, kept.ID, projects[0].ID)
+	// The row is kept, only marked deleted.
+	require.NoError(t, db.Unscoped().First(&project, id).Error)
+	assert.True(t, project.DeletedAt.Valid)
+	assert.ErrorIs(t, service.DeleteProject(id, false), gorm.ErrRecordNotFound)
+
+	require.NoError(t, service.RestoreProject(id))
+	_, err = service.GetProject(id)
+	assert.NoError(t, err)
+	projects, err = service.ListProjects(models.ProjectFilter{})
+	require.NoError(t, err)
+	assert.Len(t, projects, 2)
+	assert.ErrorIs(t, service.RestoreProject(id), gorm.ErrRecordNotFound)
+}
+
+// TestDeleteProject_Donations tests that projects holding donations cannot be deleted
+func TestDeleteProject_Donations(t *testing.T) {
+	db := newTestDB(t)
+	service := NewProjectService(db)
+	owner := createTestUser(t, db, "owner")
+	donor := createTestUser(t, db, "donor")
+
+	for _, status := range []string{models.DonationQueued, models.DonationProcessing, models.DonationAuthorized, models.DonationSucceeded} {
+		project := createTestProject(t, db, owner)
+		createTestDonation(t, db, project, donor, 500, status)
+		assert.ErrorIs(t, service.DeleteProject(uint64(project.ID), false), ErrProjectHasDonations, status)
+		_, err := service.GetProject(uint64(project.ID))
+		assert.NoError(t, err, status)
+	}
+	for _, status := range []string{models.DonationFailed, models.DonationRefunded} {
+		project := createTestProject(t, db, owner)
+		createTestDonation(t, db, project, donor, 500, status)
+		assert.NoError(t, service.DeleteProject(uint64(project.ID), false), status)
+	}
+}
+
+// TestDeleteProject_LegacyDonations tests that legacy donations block deletion unless an admin allows them
+func TestDeleteProject_LegacyDonations(t *testing.T) {
+	db := newTestDB(t)
+	service := NewProjectService(db)
+	owner := createTestUser(t, db, "owner")
+	donor := createTestUser(t, db, "donor")
+	legacyDonation := func(project models.Project) {
+		donation := createTestDonation(t, db, project, donor, 500, models.DonationSucceeded)
+		require.NoError(t, db.Model(&donation).Update("payment_provider", LegacyPaymentProvider).Error)
+	}
+
+	project := createTestProject(t, db, owner)
+	legacyDonation(project)
+	assert.ErrorIs(t, service.DeleteProject(uint64(project.ID), false), ErrProjectHasDonations)
+	assert.NoError(t, service.DeleteProject(uint64(project.ID), true))
+
+	// Allowing legacy donations does not let other donations through.
+	mixed := createTestProject(t, db, owner)
+	legacyDonation(mixed)
+	createTestDonation(t, db, mixed, donor, 500, models.DonationSucceeded)
+	assert.ErrorIs(t, service.DeleteProject(uint64(mixed.ID), true), ErrProjectHasDonations)
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const maxSlugLength = 100
+
+var (
+	ErrInvalidSlug = errors.New("slug may only contain lowercase letters, digits and single hyphens, and cannot be all digits")
+	ErrSlugTaken   = errors.New("slug is already in use")
+
+	validSlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	allDigits = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// ValidateSlug checks a user-chosen slug. All-digit slugs are rejected
+// because /api/projects/:id treats those as numeric IDs.
+func ValidateSlug(slug string) error {
+	if len(slug) > maxSlugLength || !validSlug.MatchString(slug) || allDigits.MatchString(slug) {
+		return ErrInvalidSlug
+	}
+	return nil
+}
+
+// ResolveSlug finds the project a slug refers to, following renamed slugs.
+// It returns the project ID and its current slug, which differs from the
+// argument when the caller should be redirected. Like GetProject, it does
+// not filter on moderation status.
+func (s *ProjectService) ResolveSlug(slug string) (uint64, string, error) {
+	var project models.Project
+	err := s.db.Select("id", "slug").Where("slug = ?", slug).First(&project).Error
+	if err == nil {
+		return uint64(project.ID), project.Slug, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, "", err
+	}
+
+	var history models.ProjectSlugHistory
+	if err := s.db.Where("slug = ?", slug).First(&history).Error; err != nil {
+		return 0, "", err
+	}
+	err = s.db.Select("id", "slug").First(&project, history.ProjectID).Error
+	return uint64(project.ID), project.Slug, err
+}
+
+// uniqueSlug returns base, or base with a numeric suffix, such that it is
+// not used by another project either currently or in slug history.
+func uniqueSlug(tx *gorm.DB, base string, projectID uint) (string, error) {
+	base = slugBase(base)
+	candidate := base
+	for n := 2; ; n++ {
+		taken, err := slugTaken(tx, candidate, projectID)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+		candidate = base + "-" + strconv.Itoa(n)
+	}
+}
+
+// slugBase turns a slugified title into a valid slug, leaving room for a
+// numeric suffix.
+func slugBase(base string) string {
+	if base == "" {
+		return "project"
+	}
+	if allDigits.MatchString(base) {
+		base = "project-" + base
+	}
+	if len(base) > maxSlugLength-8 {
+		// Cutting may leave a hyphen at the end, which would double up
+		// with the suffix's.
+		base = strings.TrimRight(base[:maxSlugLength-8], "-")
+	}
+	return base
+}
+
+func slugTaken(tx *gorm.DB, slug string, projectID uint) (bool, error) {
+	var count int64
+	err := tx.Unscoped().Model(&models.Project{}).Where("slug = ? AND id <> ?", slug, projectID).Count(&count).Error
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+	err = tx.Model(&models.ProjectSlugHistory{}).Where("slug = ? AND project_id <> ?", slug, projectID).Count(&count).Error
+	return count > 0, err
+}
+
+// changeSlug moves the project to a new slug, keeping the old one in the
+// history so existing links redirect.
+func changeSlug(tx *gorm.DB, projectID uint, oldSlug, newSlug string) error {
+	if err := ValidateSlug(newSlug); err != nil {
+		return err
+	}
+	taken, err := slugTaken(tx, newSlug, projectID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrSlugTaken
+	}
+	// The project may be taking back one of its own former slugs.
+	if err := tx.Where("slug = ?", newSlug).Delete(&models.ProjectSlugHistory{}).Error; err != nil {
+		return err
+	}
+	if oldSlug == "" {
+		return nil
+	}
+	return tx.Create(&models.ProjectSlugHistory{Slug: oldSlug, ProjectID: projectID}).Error
+}
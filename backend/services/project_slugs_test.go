@@ -0,0 +1,30 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestValidateSlug tests which user-chosen slugs are accepted
+func TestValidateSlug(t *testing.T) {
+	assert.NoError(t, ValidateSlug("solar-kettle"))
+	assert.NoError(t, ValidateSlug("kettle-2"))
+
+	for _, slug := range []string{"", "42", "Solar-Kettle", "solar--kettle", "-kettle", "kettle-", "solar kettle", strings.Repeat("a", 101)} {
+		assert.ErrorIs(t, ValidateSlug(slug), ErrInvalidSlug, slug)
+	}
+}
+
+// TestSlugBase tests slugs derived from titles are valid and leave room for
+// a suffix
+func TestSlugBase(t *testing.T) {
+	assert.Equal(t, "project", slugBase(""))
+	assert.Equal(t, "project-2024", slugBase("2024"))
+	assert.Equal(t, "solar-kettle", slugBase("solar-kettle"))
+
+	long := slugBase(strings.Repeat("a", 91) + "-bcd")
+	assert.Equal(t, strings.Repeat("a", 91), long)
+	assert.NoError(t, ValidateSlug(long+"-2"))
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ProjectUpdateService struct {
+	db              *gorm.DB
+	projectService  *ProjectService
+	donationService *DonationService
+	emailService    *EmailService
+}
+
+func NewProjectUpdateService(db *gorm.DB, projectService *ProjectService, donationService *DonationService, emailService *EmailService) *ProjectUpdateService {
+	return &ProjectUpdateService{db: db, projectService: projectService, donationService: donationService, emailService: emailService}
+}
+
+func (s *ProjectUpdateService) CreateUpdate(update *models.ProjectUpdate) error {
+	html, err := RenderMarkdown(update.BodyMarkdown)
+	if err != nil {
+		return err
+	}
+	update.BodyHTML = html
+	if update.PublishAt.IsZero() {
+		update.PublishAt = time.Now()
+	}
+	if err := s.db.Create(update).Error; err != nil {
+		return err
+	}
+	if !update.PublishAt.After(time.Now()) {
+		go s.notify(*update)
+	}
+	return nil
+}
+
+// EditUpdate changes the content of an update. Already-notified backers are
+// not emailed again.
+func (s *ProjectUpdateService) EditUpdate(update *models.ProjectUpdate) error {
+	html, err := RenderMarkdown(update.BodyMarkdown)
+	if err != nil {
+		return err
+	}
+	update.BodyHTML = html
+	return s.db.Model(update).Select("title", "body_markdown", "body_html", "backers_only", "publish_at").Updates(update).Error
+}
+
+func (s *ProjectUpdateService) DeleteUpdate(id uint64) error {
+	return s.db.Delete(&models.ProjectUpdate{}, id).Error
+}
+
+func (s *ProjectUpdateService) GetUpdate(projectID, id uint64) (models.ProjectUpdate, error) {
+	var update models.ProjectUpdate
+	err := s.db.Where("project_id = ?", projectID).First(&update, id).Error
+	return update, err
+}
+
+// ListUpdates returns a project's updates, newest first. Scheduled updates
+// are only included when includeScheduled is set.
+func (s *ProjectUpdateService) ListUpdates(projectID uint64, includeScheduled bool) ([]models.ProjectUpdate, error) {
+	var updates []models.ProjectUpdate
+	query := s.db.Where("project_id = ?", projectID)
+	if !includeScheduled {
+		query = query.Where("publish_at <= ?", time.Now())
+	}
+	err := query.Order("publish_at DESC").Find(&updates).Error
+	return updates, err
+}
+
+// RunScheduler periodically sends notifications for scheduled updates whose
+// publish time has arrived. It returns when ctx is cancelled.
+func (s *ProjectUpdateService) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.publishDue()
+		}
+	}
+}
+
+func (s *ProjectUpdateService) publishDue() {
+	var due []models.ProjectUpdate
+	err := s.db.Where("notified_at IS NULL AND publish_at <= ?", time.Now()).Find(&due).Error
+	if err != nil {
+		log.Printf("Error loading scheduled project updates: %v", err)
+		return
+	}
+	for _, update := range due {
+		s.notify(update)
+	}
+}
+
+// notify emails every backer of the project once. The notified_at guard
+// keeps the scheduler and CreateUpdate from both sending the same update.
+func (s *ProjectUpdateService) notify(update models.ProjectUpdate) {
+	result := s.db.Model(&models.ProjectUpdate{}).
+		Where("id = ? AND notified_at IS NULL", update.ID).
+		Update("notified_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Error marking project update %d as notified: %v", update.ID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	project, err := s.projectService.GetProject(uint64(update.ProjectID))
+	if err != nil {
+		log.Printf("Error loading project %d for update notification: %v", update.ProjectID, err)
+		return
+	}
+	if project.ModerationStatus != models.ModerationActive {
+		return
+	}
+	emails, err := s.donationService.BackerEmails(uint64(update.ProjectID))
+	if err != nil {
+		log.Printf("Error loading backers for project %d: %v", update.ProjectID, err)
+		return
+	}
+	for _, to := range emails {
+		s.emailService.SendProjectUpdate(to, project, update)
+	}
+}
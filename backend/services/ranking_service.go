@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"database/sql"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+const (
+	trendingKey = "ranking:trending"
+	featuredKey = "ranking:featured"
+
+	// Donations count towards velocity for a day; new backers and follows
+	// count towards growth for a week.
+	velocityWindow = 24 * time.Hour
+	growthWindow   = 7 * 24 * time.Hour
+
+	// Points per percent of the goal raised in the velocity window, per new
+	// backer and per new follow.
+	velocityWeight = 1.0
+	backerWeight   = 2.0
+	followWeight   = 0.5
+)
+
+var ErrInvalidFeaturedSlot = errors.New("featured slot is out of range")
+
+// RankingService ranks projects for the trending and featured lists. Both
+// rankings are kept in Redis sorted sets of project IDs; project data is
+// always loaded from the database so hidden or ended projects drop out
+// immediately.
+type RankingService struct {
+	db            *gorm.DB
+	cacheService  *CacheService
+	featuredSlots int
+	// trendingTTL is how long a computed ranking stays in Redis. It is longer
+	// than the refresh interval so one failed refresh does not empty it.
+	trendingTTL time.Duration
+}
+
+func NewRankingService(db *gorm.DB, cacheService *CacheService, featuredSlots int, refreshInterval time.Duration) *RankingService {
+	return &RankingService{db: db, cacheService: cacheService, featuredSlots: featuredSlots, trendingTTL: 3 * refreshInterval}
+}
+
+// TrendingScore weighs a project's recent activity. Velocity is measured
+// relative to the goal so small projects can trend alongside large ones.
+func TrendingScore(stats models.TrendingStats) float64 {
+	var velocity float64
+	if stats.Goal > 0 {
+		velocity = float64(stats.RecentAmount) / float64(stats.Goal) * 100
+	}
+	return velocityWeight*velocity + backerWeight*float64(stats.NewBackers) + followWeight*float64(stats.NewFollows)
+}
+
+// RunScheduler recomputes the trending ranking every interval. It returns
+// when ctx is cancelled.
+func (s *RankingService) RunScheduler(ctx context.Context, interval time.Duration) {
+	s.refresh(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refresh(ctx)
+		}
+	}
+}
+
+func (s *RankingService) refresh(ctx context.Context) {
+	if _, err := s.RefreshTrending(ctx); err != nil {
+		log.Printf("Error refreshing trending projects: %v", err)
+	}
+	s.refreshFeaturedOrLog(ctx)
+}
+
+// RefreshTrending computes scores for every running project and stores the
+// ones with any recent activity. It returns the project IDs in rank order.
+func (s *RankingService) RefreshTrending(ctx context.Context) ([]string, error) {
+	stats, err := s.trendingStats(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	scores := map[string]float64{}
+	ids := []string{}
+	for _, stat := range stats {
+		if score := TrendingScore(stat); score > 0 {
+			id := strconv.FormatUint(uint64(stat.ProjectID), 10)
+			scores[id] = score
+			ids = append(ids, id)
+		}
+	}
+	sort.SliceStable(ids, func(i, j int) bool { return scores[ids[i]] > scores[ids[j]] })
+	return ids, s.cacheService.ReplaceSortedSet(ctx, trendingKey, scores, s.trendingTTL)
+}
+
+func (s *RankingService) trendingStats(now time.Time) ([]models.TrendingStats, error) {
+	velocitySince, growthSince := now.Add(-velocityWindow), now.Add(-growthWindow)
+	var stats []models.TrendingStats
+	err := s.db.Model(&models.Project{}).
+		Select(`projects.id AS project_id, projects.goal_amount AS goal,
+			(SELECT COALESCE(SUM(d.base_amount - d.base_refunded_amount), 0) FROM donations d
+				WHERE d.project_id = projects.id AND d.status IN @counted AND d.timestamp >= @velocitySince) AS recent_amount,
+			(SELECT COUNT(DISTINCT d.user_id) FROM donations d
+				WHERE d.project_id = projects.id AND d.status IN @counted AND d.timestamp >= @growthSince
+				AND NOT EXISTS (SELECT 1 FROM donations e
+					WHERE e.project_id = d.project_id AND e.user_id = d.user_id
+					AND e.status IN @counted AND e.timestamp < @growthSince)) AS new_backers,
+			(SELECT COUNT(*) FROM project_follows f
+				WHERE f.project_id = projects.id AND f.created_at >= @growthSince) AS new_follows`,
+			sql.Named("counted", models.CountedDonationStatuses), sql.Named("velocitySince", velocitySince), sql.Named("growthSince", growthSince)).
+		Where("moderation_status = ? AND start_date <= ? AND end_date > ?", models.ModerationActive, now, now).
+		Scan(&stats).Error
+	return stats, err
+}
+
+// Trending returns a page of running projects ordered by trending score.
+// If the ranking is missing from Redis it is recomputed on the spot.
+func (s *RankingService) Trending(ctx context.Context, page, perPage int) ([]models.Project, error) {
+	start := int64((page - 1) * perPage)
+	ids, err := s.cacheService.SortedSetRange(ctx, trendingKey, start, start+int64(perPage)-1)
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Error reading trending projects from cache: %v", err)
+		}
+		all, err := s.RefreshTrending(ctx)
+		if all == nil {
+			return nil, err
+		}
+		if err != nil {
+			log.Printf("Error storing trending projects: %v", err)
+		}
+		ids = pageOf(all, int(start), perPage)
+	}
+	return s.loadRanked(ids, true)
+}
+
+// Featured returns the projects in the featured slots, in slot order.
+func (s *RankingService) Featured(ctx context.Context) ([]models.Project, error) {
+	ids, err := s.cacheService.SortedSetRange(ctx, featuredKey, 0, -1)
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Error reading featured projects from cache: %v", err)
+		}
+		// Slots are few; read them straight from the database and let
+		// the next refresh repopulate Redis.
+		if ids, err = s.activeFeaturedIDs(); err != nil {
+			return nil, err
+		}
+	}
+	return s.loadRanked(ids, false)
+}
+
+// FeaturedSlots lists the configured slots for admins, including expired
+// ones.
+func (s *RankingService) FeaturedSlots() ([]models.FeaturedProject, error) {
+	var slots []models.FeaturedProject
+	err := s.db.Order("slot").Find(&slots).Error
+	return slots, err
+}
+
+// SetFeatured puts the project in the slot, replacing whatever was there.
+// A project can only hold one slot, so it is moved if already featured.
+func (s *RankingService) SetFeatured(ctx context.Context, slot int, adminID uint, input models.SetFeaturedProject) (models.FeaturedProject, error) {
+	if slot < 1 || slot > s.featuredSlots {
+		return models.FeaturedProject{}, ErrInvalidFeaturedSlot
+	}
+	featured := models.FeaturedProject{Slot: slot, ProjectID: input.ProjectID, UserID: adminID, EndsAt: input.EndsAt}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Model(&models.Project{}).Where("id = ? AND moderation_status = ?", input.ProjectID, models.ModerationActive).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		err = tx.Where("slot = ? OR project_id = ?", slot, input.ProjectID).Delete(&models.FeaturedProject{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&featured).Error
+	})
+	if err != nil {
+		return models.FeaturedProject{}, err
+	}
+	s.refreshFeaturedOrLog(ctx)
+	return featured, nil
+}
+
+func (s *RankingService) ClearFeatured(ctx context.Context, slot int) error {
+	result := s.db.Where("slot = ?", slot).Delete(&models.FeaturedProject{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	s.refreshFeaturedOrLog(ctx)
+	return nil
+}
+
+// refreshFeatured copies the current slots into Redis. Slots are scored
+// negatively so the descending range puts slot 1 first.
+func (s *RankingService) refreshFeatured(ctx context.Context) error {
+	ids, err := s.activeFeaturedIDs()
+	if err != nil {
+		return err
+	}
+	scores := make(map[string]float64, len(ids))
+	for i, id := range ids {
+		scores[id] = -float64(i + 1)
+	}
+	// Refreshed with trending, so expired slots drop out within a cycle.
+	return s.cacheService.ReplaceSortedSet(ctx, featuredKey, scores, s.trendingTTL)
+}
+
+// refreshFeaturedOrLog is used after the slots change. The database is
+// already up to date, so a Redis failure only delays the public list until
+// the next scheduled refresh.
+func (s *RankingService) refreshFeaturedOrLog(ctx context.Context) {
+	if err := s.refreshFeatured(ctx); err != nil {
+		log.Printf("Error refreshing featured projects: %v", err)
+	}
+}
+
+// activeFeaturedIDs returns the IDs of projects in unexpired slots, in slot
+// order.
+func (s *RankingService) activeFeaturedIDs() ([]string, error) {
+	var projectIDs []uint
+	err := s.db.Model(&models.FeaturedProject{}).
+		Where("ends_at IS NULL OR ends_at > ?", time.Now()).
+		Order("slot").Pluck("project_id", &projectIDs).Error
+	ids := make([]string, len(projectIDs))
+	for i, id := range projectIDs {
+		ids[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return ids, err
+}
+
+// loadRanked loads the projects with the given IDs, keeping their order and
+// skipping any that are no longer visible. Trending lists only show
+// projects that are still running.
+func (s *RankingService) loadRanked(ids []string, runningOnly bool) ([]models.Project, error) {
+	projects := []models.Project{}
+	if len(ids) == 0 {
+		return projects, nil
+	}
+	now := time.Now()
+	query := s.db.Preload("Tags").Preload("Media").Scopes(listedProjects(now)).Where("id IN ?", ids)
+	if runningOnly {
+		query = query.Where("end_date > ?", now)
+	}
+	var found []models.Project
+	if err := query.Find(&found).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[string]models.Project, len(found))
+	for _, project := range found {
+		byID[strconv.FormatUint(uint64(project.ID), 10)] = project
+	}
+	for _, id := range ids {
+		if project, ok := byID[id]; ok {
+			projects = append(projects, project)
+		}
+	}
+	return projects, nil
+}
+
+func pageOf(ids []string, offset, limit int) []string {
+	if offset >= len(ids) {
+		return nil
+	}
+	end := offset + limit
+	if end > len(ids) {
+		end = len(ids)
+	}
+	return ids[offset:end]
+}
@@ -0,0 +1,24 @@
+package services
+
+import (
+	"crowdfund/backend/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTrendingScore tests that velocity is relative to the goal and growth adds on top
+func TestTrendingScore(t *testing.T) {
+	small := models.TrendingStats{Goal: 1000, RecentAmount: 100}
+	large := models.TrendingStats{Goal: 100000, RecentAmount: 1000}
+	assert.Greater(t, TrendingScore(small), TrendingScore(large))
+
+	growing := large
+	growing.NewBackers = 10
+	growing.NewFollows = 4
+	assert.InDelta(t, TrendingScore(large)+2*10+0.5*4, TrendingScore(growing), 1e-9)
+
+	assert.Zero(t, TrendingScore(models.TrendingStats{}))
+	assert.Equal(t, []string{"3", "4"}, pageOf([]string{"1", "2", "3", "4", "5"}, 2, 2))
+	assert.Nil(t, pageOf([]string{"1"}, 5, 2))
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoExchangeRate     = errors.New("no exchange rate for currency pair")
+	ErrRatesUnavailable   = errors.New("exchange rates are unavailable")
+	errInvalidRateDecimal = errors.New("invalid exchange rate")
+)
+
+// ExchangeRate says how many units of To one unit of From buys. Rate is a
+// decimal string so the exact value used can be stored and replayed.
+type ExchangeRate struct {
+	From      string
+	To        string
+	Rate      string
+	Source    string
+	FetchedAt time.Time
+}
+
+// RateProvider looks up exchange rates between ISO 4217 currencies.
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (ExchangeRate, error)
+}
+
+// StaticRateProvider serves rates from a fixed table quoted against one
+// base currency. Cross rates are derived through the base.
+type StaticRateProvider struct {
+	base      string
+	rates     map[string]*big.Rat
+	source    string
+	fetchedAt time.Time
+}
+
+// ratesFile is the format read by NewStaticRateProviderFromFile, e.g.
+// {"base": "USD", "rates": {"EUR": "0.92", "JPY": "151.3"}}.
+type ratesFile struct {
+	Base  string                 `json:"base"`
+	Rates map[string]json.Number `json:"rates"`
+}
+
+func NewStaticRateProvider(base string, rates map[string]string) (*StaticRateProvider, error) {
+	p := &StaticRateProvider{base: base, rates: map[string]*big.Rat{base: big.NewRat(1, 1)}, source: "static", fetchedAt: time.Now()}
+	for code, text := range rates {
+		rate, ok := new(big.Rat).SetString(text)
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("%w for %s: %q", errInvalidRateDecimal, code, text)
+		}
+		p.rates[strings.ToUpper(code)] = rate
+	}
+	return p, nil
+}
+
+func NewStaticRateProviderFromFile(path string) (*StaticRateProvider, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file ratesFile
+	decoder := json.NewDecoder(strings.NewReader(string(b)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	rates := make(map[string]string, len(file.Rates))
+	for code, rate := range file.Rates {
+		rates[code] = rate.String()
+	}
+	p, err := NewStaticRateProvider(strings.ToUpper(file.Base), rates)
+	if err != nil {
+		return nil, err
+	}
+	p.source = "file:" + path
+	return p, nil
+}
+
+func (p *StaticRateProvider) Rate(ctx context.Context, from, to string) (ExchangeRate, error) {
+	if from == to {
+		return identityRate(from), nil
+	}
+	fromRate, ok := p.rates[from]
+	toRate, ok2 := p.rates[to]
+	if !ok || !ok2 {
+		return ExchangeRate{}, fmt.Errorf("%w: %s to %s", ErrNoExchangeRate, from, to)
+	}
+	rate := new(big.Rat).Quo(toRate, fromRate)
+	return ExchangeRate{From: from, To: to, Rate: formatRate(rate), Source: p.source, FetchedAt: p.fetchedAt}, nil
+}
+
+// HTTPRateProvider fetches rates from a Frankfurter-compatible API
+// (GET <url>?from=EUR&to=USD returning {"rates": {"USD": 1.08}}) and caches
+// each pair for a while.
+type HTTPRateProvider struct {
+	url    string
+	client *http.Client
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]ExchangeRate
+}
+
+func NewHTTPRateProvider(url string, ttl time.Duration) *HTTPRateProvider {
+	return &HTTPRateProvider{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		ttl:    ttl,
+		cache:  map[string]ExchangeRate{},
+	}
+}
+
+func (p *HTTPRateProvider) Rate(ctx context.Context, from, to string) (ExchangeRate, error) {
+	if from == to {
+		return identityRate(from), nil
+	}
+	key := from + "/" + to
+	p.mu.Lock()
+	cached, ok := p.cache[key]
+	p.mu.Unlock()
+	if ok && time.Since(cached.FetchedAt) < p.ttl {
+		return cached, nil
+	}
+
+	rate, err := p.fetch(ctx, from, to)
+	if err != nil {
+		return ExchangeRate{}, err
+	}
+	p.mu.Lock()
+	p.cache[key] = rate
+	p.mu.Unlock()
+	return rate, nil
+}
+
+func (p *HTTPRateProvider) fetch(ctx context.Context, from, to string) (ExchangeRate, error) {
+	q := url.Values{}
+	q.Set("from", from)
+	q.Set("to", to)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url+"?"+q.Encode(), nil)
+	if err != nil {
+		return ExchangeRate{}, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return ExchangeRate{}, fmt.Errorf("%w: %v", ErrRatesUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnprocessableEntity {
+		return ExchangeRate{}, fmt.Errorf("%w: %s to %s", ErrNoExchangeRate, from, to)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ExchangeRate{}, fmt.Errorf("%w: rate API returned %s", ErrRatesUnavailable, resp.Status)
+	}
+
+	var body struct {
+		Rates map[string]json.Number `json:"rates"`
+	}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		return ExchangeRate{}, fmt.Errorf("%w: %v", ErrRatesUnavailable, err)
+	}
+	text, ok := body.Rates[to]
+	if !ok {
+		return ExchangeRate{}, fmt.Errorf("%w: %s to %s", ErrNoExchangeRate, from, to)
+	}
+	rate, ok := new(big.Rat).SetString(text.String())
+	if !ok || rate.Sign() <= 0 {
+		return ExchangeRate{}, fmt.Errorf("%w: %s", errInvalidRateDecimal, text)
+	}
+	return ExchangeRate{From: from, To: to, Rate: formatRate(rate), Source: p.url, FetchedAt: time.Now()}, nil
+}
+
+func identityRate(currency string) ExchangeRate {
+	return ExchangeRate{From: currency, To: currency, Rate: "1", Source: "identity", FetchedAt: time.Now()}
+}
+
+// formatRate rounds a rate to 12 decimal places. Conversions always use this
+// text rather than the exact value, so replaying a stored rate reproduces
+// the stored amount.
+func formatRate(rate *big.Rat) string {
+	text := strings.TrimRight(rate.FloatString(12), "0")
+	return strings.TrimSuffix(text, ".")
+}
+
+// ConvertMoney converts an amount at the given rate into the target
+// currency, rounding half away from zero to the target's minor unit.
+func ConvertMoney(amount models.Money, rate ExchangeRate) (models.Money, error) {
+	from, ok := models.Currencies[amount.Currency]
+	if !ok || amount.Currency != rate.From {
+		return models.Money{}, fmt.Errorf("%w: %s", models.ErrUnsupportedCurrency, amount.Currency)
+	}
+	to, ok := models.Currencies[rate.To]
+	if !ok {
+		return models.Money{}, fmt.Errorf("%w: %s", models.ErrUnsupportedCurrency, rate.To)
+	}
+	r, ok := new(big.Rat).SetString(rate.Rate)
+	if !ok {
+		return models.Money{}, fmt.Errorf("%w: %q", errInvalidRateDecimal, rate.Rate)
+	}
+
+	// minor_to = minor_from * rate * 10^(exp_to - exp_from)
+	value := new(big.Rat).Mul(new(big.Rat).SetInt64(amount.Amount), r)
+	shift := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(to.Exponent-from.Exponent))), nil)
+	if to.Exponent >= from.Exponent {
+		value.Mul(value, new(big.Rat).SetInt(shift))
+	} else {
+		value.Quo(value, new(big.Rat).SetInt(shift))
+	}
+	return models.Money{Amount: roundHalfAway(value), Currency: rate.To}, nil
+}
+
+func roundHalfAway(value *big.Rat) int64 {
+	num, den := new(big.Int).Set(value.Num()), value.Denom()
+	negative := num.Sign() < 0
+	num.Abs(num)
+	// (2*num + den) / (2*den) rounds halves up on the magnitude.
+	num.Mul(num, big.NewInt(2)).Add(num, den)
+	q := new(big.Int).Quo(num, new(big.Int).Mul(den, big.NewInt(2)))
+	if negative {
+		q.Neg(q)
+	}
+	return q.Int64()
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
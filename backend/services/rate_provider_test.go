@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStaticRateProvider tests direct and cross rates from a rates file
+func TestStaticRateProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"base": "USD", "rates": {"EUR": 0.8, "JPY": "150"}}`), 0o644))
+	provider, err := NewStaticRateProviderFromFile(path)
+	assert.NoError(t, err)
+
+	rate, err := provider.Rate(context.Background(), "USD", "EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.8", rate.Rate)
+	assert.Equal(t, "file:"+path, rate.Source)
+
+	rate, err = provider.Rate(context.Background(), "EUR", "JPY")
+	assert.NoError(t, err)
+	assert.Equal(t, "187.5", rate.Rate)
+
+	rate, err = provider.Rate(context.Background(), "GBP", "GBP")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", rate.Rate)
+
+	_, err = provider.Rate(context.Background(), "GBP", "USD")
+	assert.ErrorIs(t, err, ErrNoExchangeRate)
+}
+
+// TestConvertMoney tests rounding and currencies with different minor units
+func TestConvertMoney(t *testing.T) {
+	converted, err := ConvertMoney(models.Money{Amount: 1000, Currency: "EUR"}, ExchangeRate{From: "EUR", To: "USD", Rate: "1.0845"})
+	assert.NoError(t, err)
+	assert.Equal(t, models.Money{Amount: 1085, Currency: "USD"}, converted) // 10.845 rounds up
+
+	converted, err = ConvertMoney(models.Money{Amount: 1050, Currency: "USD"}, ExchangeRate{From: "USD", To: "JPY", Rate: "151.3"})
+	assert.NoError(t, err)
+	assert.Equal(t, models.Money{Amount: 1589, Currency: "JPY"}, converted) // $10.50 = ¥1588.65
+
+	converted, err = ConvertMoney(models.Money{Amount: 1589, Currency: "JPY"}, ExchangeRate{From: "JPY", To: "KWD", Rate: "0.002"})
+	assert.NoError(t, err)
+	assert.Equal(t, models.Money{Amount: 3178, Currency: "KWD"}, converted)
+
+	converted, err = ConvertMoney(models.Money{Amount: -1000, Currency: "EUR"}, ExchangeRate{From: "EUR", To: "USD", Rate: "1.0845"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1085), converted.Amount)
+
+	_, err = ConvertMoney(models.Money{Amount: 1, Currency: "GBP"}, ExchangeRate{From: "EUR", To: "USD", Rate: "1"})
+	assert.Error(t, err)
+}
+
+// TestHTTPRateProvider tests fetching and caching rates from the rate API
+func TestHTTPRateProvider(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Query().Get("from") != "EUR" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"amount": 1.0, "base": "EUR", "rates": {"USD": 1.08450000000000001}}`))
+	}))
+	defer server.Close()
+
+	provider := NewHTTPRateProvider(server.URL, time.Hour)
+	rate, err := provider.Rate(context.Background(), "EUR", "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0845", rate.Rate)
+	_, err = provider.Rate(context.Background(), "EUR", "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requests)
+
+	_, err = provider.Rate(context.Background(), "XXX", "USD")
+	assert.ErrorIs(t, err, ErrNoExchangeRate)
+
+	server.Close()
+	_, err = provider.Rate(context.Background(), "EUR", "GBP")
+	assert.ErrorIs(t, err, ErrRatesUnavailable)
+}
@@ -0,0 +1,372 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/jobs"
+	"crowdfund/backend/models"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultRefundWindow is how long after a donation its project's creator
+// may refund it. Admins may refund at any time.
+const DefaultRefundWindow = 30 * 24 * time.Hour
+
+var (
+	ErrRefundForbidden       = errors.New("only the project's creator or an admin may refund its donations")
+	ErrRefundWindowClosed    = errors.New("the refund window for this donation has closed")
+	ErrDonationNotRefundable = errors.New("only successful donations can be refunded")
+	ErrProjectCancelled      = errors.New("project has been cancelled")
+	ErrProjectClosed         = errors.New("project's campaign has already closed")
+	errLegacyDonation        = errors.New("donation predates payment providers; refund it by hand")
+)
+
+// RefundDonationJob sends a pending refund, by ID, to the payment provider.
+const RefundDonationJob jobs.Kind[uint] = "refunds"
+
+type RefundService struct {
+	db              *gorm.DB
+	emailService    *EmailService
+	paymentProvider PaymentProvider
+	queue           jobs.Queue
+	ownerWindow     time.Duration
+}
+
+// NewRefundService returns a service that lets project creators refund
+// donations for ownerWindow after they were made.
+func NewRefundService(db *gorm.DB, emailService *EmailService, paymentProvider PaymentProvider, queue jobs.Queue, ownerWindow time.Duration) *RefundService {
+	return &RefundService{db: db, emailService: emailService, paymentProvider: paymentProvider, queue: queue, ownerWindow: ownerWindow}
+}
+
+// CanRefund reports whether the user may refund a donation to the project:
+// admins always may, the project's creator only within window of the
+// donation.
+func CanRefund(user models.User, project models.Project, donation models.Donation, window time.Duration, now time.Time) error {
+	if user.IsAdmin {
+		return nil
+	}
+	if project.UserID != user.ID {
+		return ErrRefundForbidden
+	}
+	if now.Sub(donation.Timestamp) > window {
+		return ErrRefundWindowClosed
+	}
+	return nil
+}
+
+// RequestRefund queues a refund of the donation with the given reference.
+// Without an amount, everything not yet refunded is refunded. The amount is
+// reserved on the donation straight away so concurrent requests cannot
+// refund more than was donated.
+func (s *RefundService) RequestRefund(ctx context.Context, user models.User, reference string, input models.CreateRefund) (models.Refund, error) {
+	var refund models.Refund
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var donation models.Donation
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("reference = ?", reference).First(&donation).Error
+		if err != nil {
+			return err
+		}
+		var project models.Project
+		if err := tx.Unscoped().Select("id", "user_id").First(&project, donation.ProjectID).Error; err != nil {
+			return err
+		}
+		if err := CanRefund(user, project, donation, s.ownerWindow, time.Now()); err != nil {
+			return err
+		}
+		if donation.Status != models.DonationSucceeded {
+			return ErrDonationNotRefundable
+		}
+
+		amount := donation.Amount.Amount - donation.RefundedAmount
+		if input.Amount != nil {
+			if input.Amount.Currency != donation.Amount.Currency {
+				return FieldErrors{"amount": "must be in the donation's currency, " + donation.Amount.Currency}
+			}
+			if input.Amount.Amount <= 0 {
+				return FieldErrors{"amount": "must be greater than 0"}
+			}
+			amount = input.Amount.Amount
+		}
+		refund, err = queueRefund(ctx, tx, s.queue, donation, amount, input.Reason, &user.ID)
+		return err
+	})
+	return refund, err
+}
+
+// ListRefunds returns the refunds of a donation, oldest first.
+func (s *RefundService) ListRefunds(donationID uint) ([]models.Refund, error) {
+	var refunds []models.Refund
+	err := s.db.Where("donation_id = ?", donationID).Order("created_at, id").Find(&refunds).Error
+	return refunds, err
+}
+
+// CancelProject calls off a project's campaign, refunds every successful
+// donation to it in full and releases authorized pledges. It returns how
+// many donations are being refunded. Donations still waiting to be charged
+// fail instead, and legacy donations are left to be refunded by hand.
+func (s *RefundService) CancelProject(ctx context.Context, projectID uint, reason string) (int, error) {
+	var count int
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var project models.Project
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "cancelled_at", "closed_at").First(&project, projectID).Error
+		if err != nil {
+			return err
+		}
+		if project.CancelledAt != nil {
+			return ErrProjectCancelled
+		}
+		if project.ClosedAt != nil {
+			return ErrProjectClosed
+		}
+		if err := tx.Model(&project).Update("cancelled_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := settleDonations(ctx, tx, s.queue, projectID); err != nil {
+			return err
+		}
+		count, err = s.RefundProject(ctx, tx, projectID, reason)
+		return err
+	})
+	return count, err
+}
+
+// RefundProject queues a full refund of every successful donation to the
+// project, for campaigns that were cancelled or did not succeed. Legacy
+// donations are skipped since there is no payment to refund them against.
+// Pass tx to refund as part of a wider change. It returns how many
+// donations are being refunded.
+func (s *RefundService) RefundProject(ctx context.Context, tx *gorm.DB, projectID uint, reason string) (int, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	var donations []models.Donation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("project_id = ? AND status = ? AND refunded_amount < amount", projectID, models.DonationSucceeded).
+		Where("payment_provider <> ?", LegacyPaymentProvider).
+		Find(&donations).Error
+	if err != nil {
+		return 0, err
+	}
+	for _, donation := range donations {
+		amount := donation.Amount.Amount - donation.RefundedAmount
+		if _, err := queueRefund(ctx, tx, s.queue, donation, amount, reason, nil); err != nil {
+			return 0, err
+		}
+	}
+	return len(donations), nil
+}
+
+// queueRefund saves a pending refund of amount on a donation locked by tx,
+// reserves it on the donation and enqueues a job to send it.
+func queueRefund(ctx context.Context, tx *gorm.DB, queue jobs.Queue, donation models.Donation, amount int64, reason string, requestedBy *uint) (models.Refund, error) {
+	refund := models.Refund{
+		DonationID:  donation.ID,
+		Amount:      models.Money{Amount: amount, Currency: donation.Amount.Currency},
+		Reason:      reason,
+		RequestedBy: requestedBy,
+		Status:      models.RefundPending,
+	}
+	remaining := donation.Amount.Amount - donation.RefundedAmount
+	if amount <= 0 || amount > remaining {
+		return refund, FieldErrors{"amount": fmt.Sprintf("%s: %s left to refund", ErrRefundExceedsAmount, models.Money{Amount: remaining, Currency: donation.Amount.Currency})}
+	}
+	var err error
+	if refund.BaseAmount, err = refundBaseAmount(donation, amount); err != nil {
+		return refund, err
+	}
+
+	if err := tx.Create(&refund).Error; err != nil {
+		return refund, err
+	}
+	err = tx.Model(&donation).Updates(map[string]interface{}{
+		"refunded_amount":      gorm.Expr("refunded_amount + ?", refund.Amount.Amount),
+		"base_refunded_amount": gorm.Expr("base_refunded_amount + ?", refund.BaseAmount),
+	}).Error
+	if err != nil {
+		return refund, err
+	}
+	return refund, RefundDonationJob.Enqueue(ctx, queue, tx, refund.ID)
+}
+
+// refundBaseAmount converts a refund to the project's base currency at the
+// donation's rate. Refunding the whole remainder takes the whole remaining
+// base amount, so rounding never leaves a few cents counted.
+func refundBaseAmount(donation models.Donation, amount int64) (int64, error) {
+	remaining := donation.BaseAmount.Amount - donation.BaseRefundedAmount
+	if amount == donation.Amount.Amount-donation.RefundedAmount {
+		return remaining, nil
+	}
+	rate := ExchangeRate{From: donation.Amount.Currency, To: donation.BaseAmount.Currency, Rate: donation.ExchangeRate}
+	base, err := ConvertMoney(models.Money{Amount: amount, Currency: donation.Amount.Currency}, rate)
+	if err != nil {
+		return 0, err
+	}
+	return min(base.Amount, remaining), nil
+}
+
+// RefundHandler returns the handler for RefundDonationJob. Donors are
+// emailed a confirmation once their refund goes through.
+func (s *RefundService) RefundHandler(workers int) jobs.Handler[uint] {
+	return jobs.Handler[uint]{
+		Workers: workers,
+		Run: func(ctx context.Context, refundID uint) error {
+			refund, err := processRefund(ctx, s.db, s.paymentProvider, refundID)
+			if err == nil && refund.Status == models.RefundSucceeded {
+				s.sendConfirmation(refund)
+			}
+			return err
+		},
+		OnDead: func(tx *gorm.DB, refundID uint, cause error) error {
+			return failRefund(tx, refundID, cause.Error())
+		},
+		OnReplay: requeueRefund,
+	}
+}
+
+// processRefund sends a pending refund to the payment provider and records
+// the outcome. The refund's ID is the idempotency key, so a retry never
+// refunds twice. Refusals are outcomes; other errors are returned so the
+// job is retried.
+func processRefund(ctx context.Context, db *gorm.DB, provider PaymentProvider, refundID uint) (models.Refund, error) {
+	var refund models.Refund
+	if err := db.First(&refund, refundID).Error; err != nil {
+		// The Redis queue can deliver the job before the refund commits.
+		return refund, err
+	}
+	if refund.Status != models.RefundPending {
+		return refund, nil
+	}
+	var donation models.Donation
+	if err := db.First(&donation, refund.DonationID).Error; err != nil {
+		return refund, err
+	}
+
+	var result PaymentRefund
+	var err error
+	if donation.PaymentProvider == LegacyPaymentProvider {
+		err = errLegacyDonation
+	} else {
+		result, err = provider.Refund(ctx, donation.PaymentIntentID, refund.Amount.Amount, "refund-"+strconv.FormatUint(uint64(refund.ID), 10))
+	}
+	if errors.Is(err, errLegacyDonation) || errors.Is(err, ErrRefundExceedsAmount) || errors.Is(err, ErrPaymentState) || errors.Is(err, ErrPaymentNotFound) {
+		refund.Status = models.RefundFailed
+		return refund, db.Transaction(func(tx *gorm.DB) error {
+			return failRefund(tx, refund.ID, err.Error())
+		})
+	}
+	if err != nil {
+		return refund, err
+	}
+
+	now := time.Now()
+	refund.Status = models.RefundSucceeded
+	refund.ProviderRefundID = result.ID
+	refund.ProcessedAt = &now
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&refund).Updates(map[string]interface{}{
+			"status":             refund.Status,
+			"provider_refund_id": refund.ProviderRefundID,
+			"processed_at":       refund.ProcessedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+		// The donation is refunded once all of it has gone back.
+		return tx.Model(&models.Donation{}).
+			Where("id = ? AND refunded_amount >= amount", donation.ID).
+			Where("NOT EXISTS (SELECT 1 FROM refunds WHERE refunds.donation_id = donations.id AND refunds.status = ?)", models.RefundPending).
+			Updates(map[string]interface{}{"status": models.DonationRefunded, "payment_status": PaymentRefunded}).Error
+	})
+	return refund, err
+}
+
+// failRefund marks a pending refund failed and releases its amount on the
+// donation.
+func failRefund(tx *gorm.DB, refundID uint, reason string) error {
+	var refund models.Refund
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("status = ?", models.RefundPending).First(&refund, refundID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&refund).Updates(map[string]interface{}{
+		"status":         models.RefundFailed,
+		"failure_reason": reason,
+		"processed_at":   time.Now(),
+	}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&models.Donation{}).Where("id = ?", refund.DonationID).Updates(map[string]interface{}{
+		"refunded_amount":      gorm.Expr("refunded_amount - ?", refund.Amount.Amount),
+		"base_refunded_amount": gorm.Expr("base_refunded_amount - ?", refund.BaseAmount),
+	}).Error
+}
+
+// requeueRefund makes a refund failed by its dead job pending again when
+// the job is replayed, reserving its amount again if the donation still
+// has that much left to refund.
+func requeueRefund(tx *gorm.DB, refundID uint) error {
+	var refund models.Refund
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("status = ?", models.RefundFailed).First(&refund, refundID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	result := tx.Model(&models.Donation{}).
+		Where("id = ? AND status = ? AND amount - refunded_amount >= ?", refund.DonationID, models.DonationSucceeded, refund.Amount.Amount).
+		Updates(map[string]interface{}{
+			"refunded_amount":      gorm.Expr("refunded_amount + ?", refund.Amount.Amount),
+			"base_refunded_amount": gorm.Expr("base_refunded_amount + ?", refund.BaseAmount),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRefundExceedsAmount
+	}
+	return tx.Model(&refund).Updates(map[string]interface{}{
+		"status":         models.RefundPending,
+		"failure_reason": "",
+		"processed_at":   nil,
+	}).Error
+}
+
+func (s *RefundService) sendConfirmation(refund models.Refund) {
+	to, project, err := donationRecipient(s.db, refund.DonationID)
+	if err != nil {
+		log.Printf("Error loading donor for refund %d: %v", refund.ID, err)
+		return
+	}
+	s.emailService.SendRefundConfirmation(to, project, refund)
+}
+
+// donationRecipient returns the donor's email address and the title of the
+// project they donated to, for emails about a donation.
+func donationRecipient(db *gorm.DB, donationID uint) (string, string, error) {
+	var recipient struct {
+		Email string
+		Title string
+	}
+	err := db.Table("donations").
+		Select("users.email, projects.title").
+		Joins("JOIN users ON users.id = donations.user_id").
+		Joins("JOIN projects ON projects.id = donations.project_id").
+		Where("donations.id = ?", donationID).
+		Take(&recipient).Error
+	return recipient.Email, recipient.Title, err
+}
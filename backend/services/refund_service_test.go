@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCanRefund tests who may refund a donation and when
+func TestCanRefund(t *testing.T) {
+	now := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	project := models.Project{ID: 1, UserID: 7}
+	donation := models.Donation{ProjectID: 1, UserID: 9, Timestamp: now.Add(-10 * 24 * time.Hour)}
+	window := 30 * 24 * time.Hour
+
+	assert.NoError(t, CanRefund(models.User{ID: 7}, project, donation, window, now))
+	assert.ErrorIs(t, CanRefund(models.User{ID: 9}, project, donation, window, now), ErrRefundForbidden)
+
+	old := donation
+	old.Timestamp = now.Add(-31 * 24 * time.Hour)
+	assert.ErrorIs(t, CanRefund(models.User{ID: 7}, project, old, window, now), ErrRefundWindowClosed)
+	assert.NoError(t, CanRefund(models.User{ID: 2, IsAdmin: true}, project, old, window, now))
+}
+
+// TestRefundBaseAmount tests refunds are converted at the donation's rate
+func TestRefundBaseAmount(t *testing.T) {
+	donation := models.Donation{
+		Amount:       models.Money{Amount: 1000, Currency: "EUR"},
+		BaseAmount:   models.Money{Amount: 1083, Currency: "USD"},
+		ExchangeRate: "1.0825",
+	}
+
+	base, err := refundBaseAmount(donation, 500)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(541), base)
+
+	// The rest of the donation takes the rest of the base amount, so no
+	// rounding is left over.
+	donation.RefundedAmount, donation.BaseRefundedAmount = 500, 541
+	base, err = refundBaseAmount(donation, 500)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(542), base)
+}
+
+// TestCancelAndDeleteProject_LegacyDonations tests that legacy donations are not sent for refund and only an admin can delete past them
+func TestCancelAndDeleteProject_LegacyDonations(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	queue := &recordingQueue{}
+	provider := NewFakePaymentProvider("")
+	refunds := NewRefundService(db, NewEmailService(queue), provider, queue, DefaultRefundWindow)
+	projects := NewProjectService(db)
+	owner := createTestUser(t, db, "owner")
+	donor := createTestUser(t, db, "donor")
+	project := createTestProject(t, db, owner)
+
+	legacy := createTestDonation(t, db, project, donor, 1000, models.DonationSucceeded)
+	require.NoError(t, db.Model(&legacy).Updates(map[string]interface{}{"payment_provider": LegacyPaymentProvider, "payment_intent_id": ""}).Error)
+	donation := createTestDonation(t, db, project, donor, 2500, models.DonationSucceeded)
+	donation.PaymentMethod = "pm_card_visa"
+	intent, err := chargeDonation(ctx, provider, donation)
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&donation).Update("payment_intent_id", intent.ID).Error)
+
+	count, err := refunds.CancelProject(ctx, project.ID, "Supplier went bust")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.ErrorIs(t, projects.DeleteProject(uint64(project.ID), true), ErrProjectHasDonations)
+
+	for _, payload := range queue.payloads(string(RefundDonationJob)) {
+		refundID, err := strconv.ParseUint(string(payload), 10, 64)
+		require.NoError(t, err)
+		require.NoError(t, refunds.RefundHandler(1).Run(ctx, uint(refundID)))
+	}
+	require.NoError(t, db.First(&donation, donation.ID).Error)
+	assert.Equal(t, models.DonationRefunded, donation.Status)
+	require.NoError(t, db.First(&legacy, legacy.ID).Error)
+	assert.Equal(t, models.DonationSucceeded, legacy.Status)
+	assert.ErrorIs(t, projects.DeleteProject(uint64(project.ID), false), ErrProjectHasDonations)
+	assert.NoError(t, projects.DeleteProject(uint64(project.ID), true))
+}
+
+// TestProcessRefund_LegacyDonation tests that refunding a legacy donation fails straight away instead of retrying
+func TestProcessRefund_LegacyDonation(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	queue := &recordingQueue{}
+	owner := createTestUser(t, db, "owner")
+	admin := models.User{ID: owner.ID, IsAdmin: true}
+	project := createTestProject(t, db, owner)
+	legacy := createTestDonation(t, db, project, owner, 1000, models.DonationSucceeded)
+	require.NoError(t, db.Model(&legacy).Updates(map[string]interface{}{"payment_provider": LegacyPaymentProvider, "payment_intent_id": ""}).Error)
+	refunds := NewRefundService(db, NewEmailService(queue), NewFakePaymentProvider(""), queue, DefaultRefundWindow)
+
+	refund, err := refunds.RequestRefund(ctx, admin, legacy.Reference, models.CreateRefund{})
+	require.NoError(t, err)
+	refund, err = processRefund(ctx, db, NewFakePaymentProvider(""), refund.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RefundFailed, refund.Status)
+	require.NoError(t, db.First(&legacy, legacy.ID).Error)
+	assert.Zero(t, legacy.RefundedAmount)
+}
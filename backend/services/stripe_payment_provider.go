@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StripePaymentProvider talks to the Stripe PaymentIntents API. Intents are
+// created with manual capture so authorization and capture are separate
+// steps, matching PaymentProvider.
+type StripePaymentProvider struct {
+	baseURL       string
+	secretKey     string
+	webhookSecret string
+	client        *http.Client
+}
+
+func NewStripePaymentProvider(baseURL, secretKey, webhookSecret string) *StripePaymentProvider {
+	return &StripePaymentProvider{
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+		client:        &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type stripeIntent struct {
+	ID             string `json:"id"`
+	Amount         int64  `json:"amount"`
+	AmountReceived int64  `json:"amount_received"`
+	Currency       string `json:"currency"`
+	Status         string `json:"status"`
+}
+
+type stripeRefund struct {
+	ID            string `json:"id"`
+	Amount        int64  `json:"amount"`
+	Currency      string `json:"currency"`
+	PaymentIntent string `json:"payment_intent"`
+}
+
+type stripeError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *StripePaymentProvider) Name() string {
+	return "stripe"
+}
+
+func (p *StripePaymentProvider) CreateIntent(ctx context.Context, amount models.Money, metadata map[string]string, idempotencyKey string) (PaymentIntent, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(amount.Amount, 10))
+	form.Set("currency", strings.ToLower(amount.Currency))
+	form.Set("capture_method", "manual")
+	form.Add("payment_method_types[]", "card")
+	for key, value := range metadata {
+		form.Set("metadata["+key+"]", value)
+	}
+	var intent stripeIntent
+	header, err := p.send(ctx, http.MethodPost, "/v1/payment_intents", form, idempotencyKey, &intent)
+	if err == nil && header.Get("Idempotent-Replayed") == "true" {
+		// Stripe answers a retry with the first response, which may be
+		// stale by now; fetch the intent's current state.
+		_, err = p.send(ctx, http.MethodGet, "/v1/payment_intents/"+url.PathEscape(intent.ID), nil, "", &intent)
+	}
+	return intent.toPaymentIntent(), err
+}
+
+func (p *StripePaymentProvider) Authorize(ctx context.Context, intentID, paymentMethod string) (PaymentIntent, error) {
+	form := url.Values{}
+	form.Set("payment_method", paymentMethod)
+	return p.confirm(ctx, intentID, form)
+}
+
+// SaveCard creates a customer and attaches the payment method to it
+// through a confirmed off-session SetupIntent, which is what lets Stripe
+// charge it again later without the donor.
+func (p *StripePaymentProvider) SaveCard(ctx context.Context, paymentMethod, idempotencyKey string) (string, error) {
+	customerKey, setupKey := "", ""
+	if idempotencyKey != "" {
+		customerKey, setupKey = idempotencyKey+"-customer", idempotencyKey+"-setup"
+	}
+	var customer struct {
+		ID string `json:"id"`
+	}
+	if err := p.post(ctx, "/v1/customers", url.Values{}, customerKey, &customer); err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("customer", customer.ID)
+	form.Set("payment_method", paymentMethod)
+	form.Set("usage", "off_session")
+	form.Set("confirm", "true")
+	form.Add("payment_method_types[]", "card")
+	var setup struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	if err := p.post(ctx, "/v1/setup_intents", form, setupKey, &setup); err != nil {
+		return "", err
+	}
+	if setup.Status != "succeeded" {
+		return "", fmt.Errorf("%w: card needs further action (%s)", ErrPaymentDeclined, setup.Status)
+	}
+	return customer.ID, nil
+}
+
+// AuthorizeOffSession sets the card's customer on the intent, which Stripe
+// requires for saved cards, then confirms it with off_session so Stripe
+// does not expect the donor to be there.
+func (p *StripePaymentProvider) AuthorizeOffSession(ctx context.Context, intentID, customer, paymentMethod string) (PaymentIntent, error) {
+	form := url.Values{}
+	form.Set("customer", customer)
+	var intent stripeIntent
+	if err := p.post(ctx, "/v1/payment_intents/"+url.PathEscape(intentID), form, "", &intent); err != nil {
+		return intent.toPaymentIntent(), err
+	}
+	form = url.Values{}
+	form.Set("payment_method", paymentMethod)
+	form.Set("off_session", "true")
+	return p.confirm(ctx, intentID, form)
+}
+
+func (p *StripePaymentProvider) confirm(ctx context.Context, intentID string, form url.Values) (PaymentIntent, error) {
+	var intent stripeIntent
+	err := p.post(ctx, "/v1/payment_intents/"+url.PathEscape(intentID)+"/confirm", form, "", &intent)
+	if err == nil && intent.Status != "requires_capture" && intent.Status != "succeeded" {
+		// 3-D Secure and similar flows need the donor; we cannot finish
+		// them server-side.
+		return intent.toPaymentIntent(), fmt.Errorf("%w: payment needs further action (%s)", ErrPaymentDeclined, intent.Status)
+	}
+	return intent.toPaymentIntent(), err
+}
+
+func (p *StripePaymentProvider) Capture(ctx context.Context, intentID string, amount int64) (PaymentIntent, error) {
+	form := url.Values{}
+	if amount > 0 {
+		form.Set("amount_to_capture", strconv.FormatInt(amount, 10))
+	}
+	var intent stripeIntent
+	err := p.post(ctx, "/v1/payment_intents/"+url.PathEscape(intentID)+"/capture", form, "", &intent)
+	return intent.toPaymentIntent(), err
+}
+
+func (p *StripePaymentProvider) Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (PaymentRefund, error) {
+	form := url.Values{}
+	form.Set("payment_intent", intentID)
+	if amount > 0 {
+		form.Set("amount", strconv.FormatInt(amount, 10))
+	}
+	var refund stripeRefund
+	if err := p.post(ctx, "/v1/refunds", form, idempotencyKey, &refund); err != nil {
+		return PaymentRefund{}, err
+	}
+	return PaymentRefund{
+		ID:       refund.ID,
+		IntentID: refund.PaymentIntent,
+		Amount:   models.Money{Amount: refund.Amount, Currency: strings.ToUpper(refund.Currency)},
+	}, nil
+}
+
+func (p *StripePaymentProvider) Void(ctx context.Context, intentID string) (PaymentIntent, error) {
+	var intent stripeIntent
+	err := p.post(ctx, "/v1/payment_intents/"+url.PathEscape(intentID)+"/cancel", url.Values{}, "", &intent)
+	return intent.toPaymentIntent(), err
+}
+
+func (p *StripePaymentProvider) post(ctx context.Context, path string, form url.Values, idempotencyKey string, out interface{}) error {
+	_, err := p.send(ctx, http.MethodPost, path, form, idempotencyKey, out)
+	return err
+}
+
+func (p *StripePaymentProvider) send(ctx context.Context, method, path string, form url.Values, idempotencyKey string, out interface{}) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.secretKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPaymentUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var body stripeError
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.Header, stripeErr(resp.StatusCode, body)
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(out)
+}
+
+func stripeErr(status int, body stripeError) error {
+	message := body.Error.Message
+	switch {
+	case status == http.StatusPaymentRequired || body.Error.Type == "card_error":
+		return fmt.Errorf("%w: %s", ErrPaymentDeclined, message)
+	case status == http.StatusNotFound || body.Error.Code == "resource_missing":
+		return ErrPaymentNotFound
+	case body.Error.Code == "payment_intent_unexpected_state":
+		return fmt.Errorf("%w: %s", ErrPaymentState, message)
+	case body.Error.Code == "charge_already_refunded" || body.Error.Code == "amount_too_large":
+		return fmt.Errorf("%w: %s", ErrRefundExceedsAmount, message)
+	case status == http.StatusTooManyRequests || status >= 500:
+		return fmt.Errorf("%w: stripe returned %d", ErrPaymentUnavailable, status)
+	}
+	return fmt.Errorf("stripe returned %d: %s", status, message)
+}
+
+func (i stripeIntent) toPaymentIntent() PaymentIntent {
+	status := PaymentCreated
+	switch i.Status {
+	case "requires_capture", "processing":
+		status = PaymentAuthorized
+	case "succeeded":
+		status = PaymentCaptured
+	case "canceled":
+		status = PaymentVoided
+	}
+	return PaymentIntent{
+		ID:       i.ID,
+		Amount:   models.Money{Amount: i.Amount, Currency: strings.ToUpper(i.Currency)},
+		Status:   status,
+		Captured: i.AmountReceived,
+	}
+}
+
+// stripeEventStatuses maps the Stripe events we handle to payment states.
+var stripeEventStatuses = map[string]string{
+	"payment_intent.amount_capturable_updated": PaymentAuthorized,
+	"payment_intent.succeeded":                 PaymentCaptured,
+	"payment_intent.payment_failed":            PaymentFailed,
+	"payment_intent.canceled":                  PaymentVoided,
+	"charge.refunded":                          PaymentRefunded,
+}
+
+// ParseWebhook checks the Stripe-Signature header, which signs
+// "<timestamp>.<body>" with the endpoint's webhook secret.
+func (p *StripePaymentProvider) ParseWebhook(header http.Header, body []byte, now time.Time) (WebhookEvent, error) {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header.Get("Stripe-Signature"), ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || now.Sub(time.Unix(t, 0)).Abs() > webhookTolerance {
+		return WebhookEvent{}, ErrInvalidWebhookSignature
+	}
+	payload := append([]byte(timestamp+"."), body...)
+	valid := false
+	for _, signature := range signatures {
+		valid = valid || verifyHMAC([]byte(p.webhookSecret), payload, signature)
+	}
+	if !valid {
+		return WebhookEvent{}, ErrInvalidWebhookSignature
+	}
+
+	var event struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Data struct {
+			Object struct {
+				ID            string `json:"id"`
+				PaymentIntent string `json:"payment_intent"`
+				Refunded      bool   `json:"refunded"`
+			} `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" {
+		return WebhookEvent{}, ErrInvalidWebhookPayload
+	}
+	parsed := WebhookEvent{ID: event.ID, Type: event.Type, IntentID: event.Data.Object.ID, Status: stripeEventStatuses[event.Type]}
+	if event.Type == "charge.refunded" {
+		parsed.IntentID = event.Data.Object.PaymentIntent
+		if !event.Data.Object.Refunded {
+			parsed.Status = "" // Partial refund; the payment stays captured.
+		}
+	}
+	return parsed, nil
+}
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRecurringNotSupported = errors.New("all-or-nothing projects do not take recurring donations")
+	ErrSubscriptionState     = errors.New("subscription cannot be changed from its current status")
+)
+
+// dunningSchedule is how long after each failed charge a plan is charged
+// again. Once the retries are used up the plan is cancelled.
+var dunningSchedule = []time.Duration{24 * time.Hour, 3 * 24 * time.Hour, 7 * 24 * time.Hour}
+
+type SubscriptionService struct {
+	db              *gorm.DB
+	donationService *DonationService
+	emailService    *EmailService
+}
+
+func NewSubscriptionService(db *gorm.DB, donationService *DonationService, emailService *EmailService) *SubscriptionService {
+	return &SubscriptionService{db: db, donationService: donationService, emailService: emailService}
+}
+
+// CycleDate returns when cycle n of a plan starting at start is billed: n
+// months or years later, on the same day of the month or the last day of
+// a shorter month.
+func CycleDate(start time.Time, interval string, n int) time.Time {
+	months := n
+	if interval == models.IntervalYearly {
+		months = 12 * n
+	}
+	year, month, day := start.Date()
+	first := time.Date(year, month+time.Month(months), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, lastDay)-1)
+}
+
+// CreateSubscription starts a recurring donation to a running flexible
+// project. The donor's card is saved with the payment provider so every
+// cycle, the first included, can be charged off-session. The first charge
+// is made at the start date.
+func (s *SubscriptionService) CreateSubscription(ctx context.Context, userID, projectID uint, input models.CreateSubscription) (models.Subscription, error) {
+	now := time.Now()
+	project, err := s.donationService.acceptingProject(s.db, projectID, now)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+	if project.FundingMode == models.FundingAllOrNothing {
+		return models.Subscription{}, ErrRecurringNotSupported
+	}
+	start := now
+	if input.StartDate != nil {
+		start = *input.StartDate
+		if start.Before(now.Add(-time.Minute)) {
+			return models.Subscription{}, FieldErrors{"start_date": "must not be in the past"}
+		}
+		if !start.Before(project.EndDate) {
+			return models.Subscription{}, FieldErrors{"start_date": "must be before the project's end date"}
+		}
+	}
+
+	customer, err := s.donationService.paymentProvider.SaveCard(ctx, input.PaymentMethod, "")
+	if errors.Is(err, ErrPaymentDeclined) {
+		return models.Subscription{}, FieldErrors{"payment_method": err.Error()}
+	}
+	if err != nil {
+		return models.Subscription{}, err
+	}
+
+	subscription := models.Subscription{
+		UserID:          userID,
+		ProjectID:       projectID,
+		Amount:          input.Amount,
+		Interval:        input.Interval,
+		StartDate:       start,
+		NextBillingAt:   start,
+		Status:          models.SubscriptionActive,
+		PaymentMethod:   input.PaymentMethod,
+		PaymentCustomer: customer,
+	}
+	err = s.db.Create(&subscription).Error
+	return subscription, err
+}
+
+// ListSubscriptions returns a page of the user's plans, newest first.
+func (s *SubscriptionService) ListSubscriptions(userID uint, page, perPage int) ([]models.Subscription, error) {
+	subscriptions := []models.Subscription{}
+	err := s.db.Where("user_id = ?", userID).Order("created_at DESC, id DESC").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// PauseSubscription stops billing until the plan is resumed. Pending
+// retries are dropped.
+func (s *SubscriptionService) PauseSubscription(userID, id uint) (models.Subscription, error) {
+	return s.change(userID, id, func(subscription *models.Subscription) (map[string]interface{}, error) {
+		if subscription.Status != models.SubscriptionActive && subscription.Status != models.SubscriptionPastDue {
+			return nil, ErrSubscriptionState
+		}
+		return map[string]interface{}{"status": models.SubscriptionPaused, "retry_at": nil, "failed_attempts": 0}, nil
+	})
+}
+
+// ResumeSubscription restarts billing from the next cycle after now; the
+// cycles that fell while the plan was paused are skipped.
+func (s *SubscriptionService) ResumeSubscription(userID, id uint) (models.Subscription, error) {
+	return s.change(userID, id, func(subscription *models.Subscription) (map[string]interface{}, error) {
+		if subscription.Status != models.SubscriptionPaused {
+			return nil, ErrSubscriptionState
+		}
+		now := time.Now()
+		cycle := subscription.Cycle
+		for CycleDate(subscription.StartDate, subscription.Interval, cycle).Before(now) {
+			cycle++
+		}
+		return map[string]interface{}{
+			"status":          models.SubscriptionActive,
+			"cycle":           cycle,
+			"next_billing_at": CycleDate(subscription.StartDate, subscription.Interval, cycle),
+		}, nil
+	})
+}
+
+// CancelSubscription stops the plan for good. A charge already under way
+// still completes.
+func (s *SubscriptionService) CancelSubscription(userID, id uint) (models.Subscription, error) {
+	return s.change(userID, id, func(subscription *models.Subscription) (map[string]interface{}, error) {
+		if subscription.Status == models.SubscriptionCancelled {
+			return nil, ErrSubscriptionState
+		}
+		return map[string]interface{}{"status": models.SubscriptionCancelled, "cancelled_at": time.Now(), "retry_at": nil}, nil
+	})
+}
+
+// change applies the updates fn returns to one of the user's plans, with
+// the row locked against the billing scheduler.
+func (s *SubscriptionService) change(userID, id uint, fn func(*models.Subscription) (map[string]interface{}, error)) (models.Subscription, error) {
+	var subscription models.Subscription
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", userID).First(&subscription, id).Error
+		if err != nil {
+			return err
+		}
+		updates, err := fn(&subscription)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&subscription).Updates(updates).Error
+	})
+	return subscription, err
+}
+
+// RunScheduler bills plans that are due and records the outcome of their
+// charges. It returns when ctx is cancelled.
+func (s *SubscriptionService) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			s.settleCharges(now)
+			s.billDue(ctx, now)
+		}
+	}
+}
+
+// billDue creates a donation for every plan whose cycle or retry is due.
+func (s *SubscriptionService) billDue(ctx context.Context, now time.Time) {
+	var ids []uint
+	err := s.db.Model(&models.Subscription{}).
+		Where("status IN ? AND pending_donation_id IS NULL", []string{models.SubscriptionActive, models.SubscriptionPastDue}).
+		Where("(retry_at IS NULL AND next_billing_at <= ?) OR retry_at <= ?", now, now).
+		Pluck("id", &ids).Error
+	if err != nil {
+		log.Printf("Error loading due subscriptions: %v", err)
+		return
+	}
+	for _, id := range ids {
+		if err := s.bill(ctx, id, now); err != nil {
+			log.Printf("Error billing subscription %d: %v", id, err)
+		}
+	}
+}
+
+// bill creates the donation for a plan's due cycle or retry, which the
+// donation workers then charge. A plan that fell several cycles behind,
+// e.g. while the scheduler was down, is charged once and moved on to its
+// first cycle after now; like cycles that fall while a plan is paused, the
+// ones missed are not charged. Plans whose project no longer takes
+// donations are cancelled.
+func (s *SubscriptionService) bill(ctx context.Context, id uint, now time.Time) error {
+	var subscription models.Subscription
+	var cancelReason string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Another instance may be billing it, or have billed it already.
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND pending_donation_id IS NULL", []string{models.SubscriptionActive, models.SubscriptionPastDue}).
+			Where("(retry_at IS NULL AND next_billing_at <= ?) OR retry_at <= ?", now, now).
+			First(&subscription, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		donation := models.Donation{
+			ProjectID:       subscription.ProjectID,
+			UserID:          subscription.UserID,
+			Amount:          subscription.Amount,
+			SubscriptionID:  &subscription.ID,
+			PaymentCustomer: subscription.PaymentCustomer,
+		}
+		donation, err = s.donationService.createDonation(ctx, tx, donation, subscription.PaymentMethod)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, ErrProjectNotAcceptingDonations):
+			cancelReason = "the project is no longer accepting donations"
+		case errors.Is(err, ErrNoExchangeRate):
+			cancelReason = subscription.Amount.Currency + " can no longer be converted to the project's currency"
+		case err != nil:
+			return err
+		}
+		if cancelReason != "" {
+			return tx.Model(&subscription).Updates(map[string]interface{}{
+				"status":       models.SubscriptionCancelled,
+				"cancelled_at": now,
+				"retry_at":     nil,
+				"last_failure": cancelReason,
+			}).Error
+		}
+
+		updates := map[string]interface{}{"pending_donation_id": donation.ID}
+		if subscription.RetryAt != nil {
+			updates["retry_at"] = nil
+		} else {
+			next := subscription.Cycle + 1
+			for !CycleDate(subscription.StartDate, subscription.Interval, next).After(now) {
+				next++
+			}
+			updates["cycle"] = next
+			updates["next_billing_at"] = CycleDate(subscription.StartDate, subscription.Interval, next)
+		}
+		return tx.Model(&subscription).Updates(updates).Error
+	})
+	if err == nil && cancelReason != "" {
+		s.notify(subscription, func(to, project string) {
+			s.emailService.SendSubscriptionCancelled(to, project, subscription, cancelReason)
+		})
+	}
+	return err
+}
+
+// settleCharges records the outcome of plans' charges once their donations
+// have succeeded or failed.
+func (s *SubscriptionService) settleCharges(now time.Time) {
+	var ids []uint
+	err := s.db.Model(&models.Subscription{}).
+		Joins("JOIN donations ON donations.id = subscriptions.pending_donation_id").
+		Where("donations.status IN ?", []string{models.DonationSucceeded, models.DonationFailed, models.DonationRefunded}).
+		Pluck("subscriptions.id", &ids).Error
+	if err != nil {
+		log.Printf("Error loading settled subscription charges: %v", err)
+		return
+	}
+	for _, id := range ids {
+		if err := s.settleCharge(id, now); err != nil {
+			log.Printf("Error settling charge for subscription %d: %v", id, err)
+		}
+	}
+}
+
+// settleCharge clears a plan's pending charge. A success brings a past due
+// plan back to active. A failure schedules the next retry from
+// dunningSchedule, or cancels the plan once they are used up, and emails
+// the donor either way.
+func (s *SubscriptionService) settleCharge(id uint, now time.Time) error {
+	var subscription models.Subscription
+	var donation models.Donation
+	var notify func(to, project string)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("pending_donation_id IS NOT NULL").First(&subscription, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&donation, *subscription.PendingDonationID).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"pending_donation_id": nil}
+		switch {
+		case donation.Status == models.DonationSucceeded || donation.Status == models.DonationRefunded:
+			updates["failed_attempts"] = 0
+			updates["last_failure"] = ""
+			if subscription.Status == models.SubscriptionPastDue {
+				updates["status"] = models.SubscriptionActive
+			}
+		case donation.Status == models.DonationFailed:
+			attempts := subscription.FailedAttempts + 1
+			updates["failed_attempts"] = attempts
+			updates["last_failure"] = donation.FailureReason
+			if subscription.Status != models.SubscriptionActive && subscription.Status != models.SubscriptionPastDue {
+				break // Paused or cancelled while the charge ran
+			}
+			if attempts <= len(dunningSchedule) {
+				retryAt := now.Add(dunningSchedule[attempts-1])
+				updates["status"] = models.SubscriptionPastDue
+				updates["retry_at"] = retryAt
+				notify = func(to, project string) {
+					s.emailService.SendSubscriptionPaymentFailed(to, project, subscription, donation.FailureReason, retryAt)
+				}
+			} else {
+				updates["status"] = models.SubscriptionCancelled
+				updates["cancelled_at"] = now
+				notify = func(to, project string) {
+					s.emailService.SendSubscriptionCancelled(to, project, subscription, fmt.Sprintf("the last %d payments failed", attempts))
+				}
+			}
+		default:
+			return nil // Still being charged
+		}
+		return tx.Model(&subscription).Updates(updates).Error
+	})
+	if err == nil && notify != nil {
+		s.notify(subscription, notify)
+	}
+	return err
+}
+
+// notify calls send with the plan owner's email address and the project's
+// title.
+func (s *SubscriptionService) notify(subscription models.Subscription, send func(to, project string)) {
+	var recipient struct {
+		Email string
+		Title string
+	}
+	err := s.db.Table("subscriptions").
+		Select("users.email, projects.title").
+		Joins("JOIN users ON users.id = subscriptions.user_id").
+		Joins("JOIN projects ON projects.id = subscriptions.project_id").
+		Where("subscriptions.id = ?", subscription.ID).
+		Take(&recipient).Error
+	if err != nil {
+		log.Printf("Error loading owner of subscription %d: %v", subscription.ID, err)
+		return
+	}
+	send(recipient.Email, recipient.Title)
+}
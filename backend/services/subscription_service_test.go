@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSubscriptionService returns a subscription service billing
+// through the fake payment provider.
+func newTestSubscriptionService(t *testing.T, queue *recordingQueue) (*SubscriptionService, *DonationService) {
+	t.Helper()
+	db := newTestDB(t)
+	donationService := newTestDonationService(t, db, queue, NewFakePaymentProvider(""))
+	return NewSubscriptionService(db, donationService, NewEmailService(queue)), donationService
+}
+
+// chargePending runs the charge job of the plan's pending donation, as the
+// donation workers would, and returns the donation.
+func chargePending(t *testing.T, s *SubscriptionService, queue *recordingQueue, id uint) models.Donation {
+	t.Helper()
+	var subscription models.Subscription
+	require.NoError(t, s.db.First(&subscription, id).Error)
+	require.NotNil(t, subscription.PendingDonationID)
+	require.Len(t, queue.payloads(string(ChargeDonationJob)), 1)
+	require.NoError(t, s.donationService.ChargeHandler(1).Run(context.Background(), DonationTask{DonationID: *subscription.PendingDonationID}))
+	var donation models.Donation
+	require.NoError(t, s.db.First(&donation, *subscription.PendingDonationID).Error)
+	return donation
+}
+
+// subjects returns the subject of each email, in order.
+func subjects(messages []EmailMessage) []string {
+	var subjects []string
+	for _, message := range messages {
+		subjects = append(subjects, message.Subject)
+	}
+	return subjects
+}
+
+// TestCycleDate tests billing dates keep the start day, clamped to short months
+func TestCycleDate(t *testing.T) {
+	start := time.Date(2024, 1, 31, 9, 30, 0, 0, time.UTC)
+	assert.Equal(t, start, CycleDate(start, models.IntervalMonthly, 0))
+	assert.Equal(t, time.Date(2024, 2, 29, 9, 30, 0, 0, time.UTC), CycleDate(start, models.IntervalMonthly, 1))
+	assert.Equal(t, time.Date(2024, 3, 31, 9, 30, 0, 0, time.UTC), CycleDate(start, models.IntervalMonthly, 2))
+	assert.Equal(t, time.Date(2025, 2, 28, 9, 30, 0, 0, time.UTC), CycleDate(start, models.IntervalMonthly, 13))
+
+	leap := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC), CycleDate(leap, models.IntervalYearly, 1))
+	assert.Equal(t, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC), CycleDate(leap, models.IntervalYearly, 4))
+}
+
+// TestCreateSubscription_ChargesSavedCard tests that the card is saved up front and charged off-session every cycle
+func TestCreateSubscription_ChargesSavedCard(t *testing.T) {
+	ctx := context.Background()
+	queue := &recordingQueue{}
+	s, _ := newTestSubscriptionService(t, queue)
+	owner := createTestUser(t, s.db, "owner")
+	donor := createTestUser(t, s.db, "donor")
+	project := createTestProject(t, s.db, owner)
+	input := models.CreateSubscription{
+		Amount:        models.Money{Amount: 500, Currency: "EUR"},
+		Interval:      models.IntervalMonthly,
+		PaymentMethod: FakeCardDeclined,
+	}
+
+	_, err := s.CreateSubscription(ctx, donor.ID, project.ID, input)
+	var fieldErrors FieldErrors
+	require.ErrorAs(t, err, &fieldErrors)
+	assert.Contains(t, fieldErrors, "payment_method")
+
+	input.PaymentMethod = "pm_card_visa"
+	subscription, err := s.CreateSubscription(ctx, donor.ID, project.ID, input)
+	require.NoError(t, err)
+	assert.NotEmpty(t, subscription.PaymentCustomer)
+
+	when := subscription.NextBillingAt
+	for cycle := 1; cycle <= 2; cycle++ {
+		s.billDue(ctx, when)
+		donation := chargePending(t, s, queue, subscription.ID)
+		assert.Equal(t, models.DonationSucceeded, donation.Status, "cycle %d", cycle)
+		assert.Equal(t, subscription.PaymentCustomer, donation.PaymentCustomer)
+		s.settleCharges(when)
+
+		require.NoError(t, s.db.First(&subscription, subscription.ID).Error)
+		assert.Nil(t, subscription.PendingDonationID)
+		assert.Equal(t, models.SubscriptionActive, subscription.Status)
+		assert.Equal(t, cycle, subscription.Cycle)
+		when = subscription.NextBillingAt
+	}
+}
+
+// TestSettleCharge_Dunning tests that failed charges are retried on the dunning schedule before the plan is cancelled
+func TestSettleCharge_Dunning(t *testing.T) {
+	ctx := context.Background()
+	queue := &recordingQueue{}
+	s, _ := newTestSubscriptionService(t, queue)
+	owner := createTestUser(t, s.db, "owner")
+	donor := createTestUser(t, s.db, "donor")
+	project := createTestProject(t, s.db, owner)
+	subscription, err := s.CreateSubscription(ctx, donor.ID, project.ID, models.CreateSubscription{
+		Amount:        models.Money{Amount: 500, Currency: "EUR"},
+		Interval:      models.IntervalMonthly,
+		PaymentMethod: FakeCardDeclinedOffSession,
+	})
+	require.NoError(t, err)
+	nextBillingAt := CycleDate(subscription.StartDate, subscription.Interval, 1)
+
+	when := subscription.NextBillingAt
+	for attempt := 1; attempt <= len(dunningSchedule); attempt++ {
+		s.billDue(ctx, when)
+		assert.Equal(t, models.DonationFailed, chargePending(t, s, queue, subscription.ID).Status)
+		queue.emails(t)
+		require.NoError(t, s.settleCharge(subscription.ID, when))
+
+		require.NoError(t, s.db.First(&subscription, subscription.ID).Error)
+		assert.Equal(t, models.SubscriptionPastDue, subscription.Status)
+		assert.Equal(t, attempt, subscription.FailedAttempts)
+		assert.NotEmpty(t, subscription.LastFailure)
+		require.NotNil(t, subscription.RetryAt)
+		assert.WithinDuration(t, when.Add(dunningSchedule[attempt-1]), *subscription.RetryAt, time.Second)
+		// Retries do not move the plan on to its next cycle.
+		assert.Equal(t, 1, subscription.Cycle)
+		assert.WithinDuration(t, nextBillingAt, subscription.NextBillingAt, time.Second)
+		emails := queue.emails(t)
+		assert.Equal(t, []string{"donor@example.com"}, recipients(emails))
+		assert.Equal(t, []string{"Your recurring donation could not be charged"}, subjects(emails))
+
+		// Nothing is billed before the retry is due.
+		s.billDue(ctx, subscription.RetryAt.Add(-time.Minute))
+		assert.Empty(t, queue.payloads(string(ChargeDonationJob)))
+		when = *subscription.RetryAt
+	}
+
+	s.billDue(ctx, when)
+	assert.Equal(t, models.DonationFailed, chargePending(t, s, queue, subscription.ID).Status)
+	queue.emails(t)
+	require.NoError(t, s.settleCharge(subscription.ID, when))
+	require.NoError(t, s.db.First(&subscription, subscription.ID).Error)
+	assert.Equal(t, models.SubscriptionCancelled, subscription.Status)
+	assert.Equal(t, len(dunningSchedule)+1, subscription.FailedAttempts)
+	assert.NotNil(t, subscription.CancelledAt)
+	assert.Nil(t, subscription.PendingDonationID)
+	assert.Equal(t, []string{"Your recurring donation has been cancelled"}, subjects(queue.emails(t)))
+
+	s.billDue(ctx, when.Add(365*24*time.Hour))
+	assert.Empty(t, queue.payloads(string(ChargeDonationJob)))
+}
+
+// TestSettleCharge_RecoversPastDue tests that a successful retry brings a past due plan back to active
+func TestSettleCharge_RecoversPastDue(t *testing.T) {
+	queue := &recordingQueue{}
+	s, _ := newTestSubscriptionService(t, queue)
+	owner := createTestUser(t, s.db, "owner")
+	donor := createTestUser(t, s.db, "donor")
+	project := createTestProject(t, s.db, owner)
+	donation := createTestDonation(t, s.db, project, donor, 500, models.DonationSucceeded)
+	now := time.Now()
+	subscription := models.Subscription{
+		UserID:            donor.ID,
+		ProjectID:         project.ID,
+		Amount:            models.Money{Amount: 500, Currency: "EUR"},
+		Interval:          models.IntervalMonthly,
+		StartDate:         now,
+		NextBillingAt:     CycleDate(now, models.IntervalMonthly, 1),
+		Cycle:             1,
+		Status:            models.SubscriptionPastDue,
+		PendingDonationID: &donation.ID,
+		FailedAttempts:    2,
+		LastFailure:       "card declined",
+	}
+	require.NoError(t, s.db.Create(&subscription).Error)
+
+	require.NoError(t, s.settleCharge(subscription.ID, now))
+	require.NoError(t, s.db.First(&subscription, subscription.ID).Error)
+	assert.Equal(t, models.SubscriptionActive, subscription.Status)
+	assert.Zero(t, subscription.FailedAttempts)
+	assert.Empty(t, subscription.LastFailure)
+	assert.Nil(t, subscription.PendingDonationID)
+	assert.Empty(t, queue.emails(t))
+}
+
+// TestBill_OnePendingCharge tests that a plan is not billed again while its charge is pending
+func TestBill_OnePendingCharge(t *testing.T) {
+	ctx := context.Background()
+	queue := &recordingQueue{}
+	s, _ := newTestSubscriptionService(t, queue)
+	owner := createTestUser(t, s.db, "owner")
+	donor := createTestUser(t, s.db, "donor")
+	project := createTestProject(t, s.db, owner)
+	subscription, err := s.CreateSubscription(ctx, donor.ID, project.ID, models.CreateSubscription{
+		Amount:        models.Money{Amount: 500, Currency: "EUR"},
+		Interval:      models.IntervalMonthly,
+		PaymentMethod: "pm_card_visa",
+	})
+	require.NoError(t, err)
+
+	now := subscription.NextBillingAt
+	require.NoError(t, s.bill(ctx, subscription.ID, now))
+	// Billed again, even once the next cycle is due, before the charge
+	// settles.
+	require.NoError(t, s.bill(ctx, subscription.ID, now))
+	require.NoError(t, s.bill(ctx, subscription.ID, now.AddDate(0, 2, 0)))
+
+	var count int64
+	require.NoError(t, s.db.Model(&models.Donation{}).Where("subscription_id = ?", subscription.ID).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+	assert.Len(t, queue.payloads(string(ChargeDonationJob)), 1)
+	require.NoError(t, s.db.First(&subscription, subscription.ID).Error)
+	assert.NotNil(t, subscription.PendingDonationID)
+	assert.Equal(t, 1, subscription.Cycle)
+}
+
+// TestBill_CancelsWhenProjectClosed tests that plans are cancelled once their project stops taking donations
+func TestBill_CancelsWhenProjectClosed(t *testing.T) {
+	ctx := context.Background()
+	for name, closed := range map[string]map[string]interface{}{
+		"ended":     {"end_date": time.Now().Add(-time.Hour)},
+		"cancelled": {"cancelled_at": time.Now()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			queue := &recordingQueue{}
+			s, _ := newTestSubscriptionService(t, queue)
+			owner := createTestUser(t, s.db, "owner")
+			donor := createTestUser(t, s.db, "donor")
+			project := createTestProject(t, s.db, owner)
+			subscription, err := s.CreateSubscription(ctx, donor.ID, project.ID, models.CreateSubscription{
+				Amount:        models.Money{Amount: 500, Currency: "EUR"},
+				Interval:      models.IntervalMonthly,
+				PaymentMethod: "pm_card_visa",
+			})
+			require.NoError(t, err)
+			require.NoError(t, s.db.Model(&project).Updates(closed).Error)
+
+			require.NoError(t, s.bill(ctx, subscription.ID, time.Now()))
+			require.NoError(t, s.db.First(&subscription, subscription.ID).Error)
+			assert.Equal(t, models.SubscriptionCancelled, subscription.Status)
+			assert.NotNil(t, subscription.CancelledAt)
+			assert.Equal(t, "the project is no longer accepting donations", subscription.LastFailure)
+			assert.Nil(t, subscription.PendingDonationID)
+			assert.Empty(t, queue.payloads(string(ChargeDonationJob)))
+			emails := queue.emails(t)
+			assert.Equal(t, []string{"donor@example.com"}, recipients(emails))
+			assert.Equal(t, []string{"Your recurring donation has been cancelled"}, subjects(emails))
+		})
+	}
+}
+
+// TestResumeSubscription_SkipsPausedCycles tests that cycles falling while a plan is paused are never billed
+func TestResumeSubscription_SkipsPausedCycles(t *testing.T) {
+	ctx := context.Background()
+	queue := &recordingQueue{}
+	s, _ := newTestSubscriptionService(t, queue)
+	owner := createTestUser(t, s.db, "owner")
+	donor := createTestUser(t, s.db, "donor")
+	project := createTestProject(t, s.db, owner)
+	start := time.Now().AddDate(0, -3, -1)
+	subscription := models.Subscription{
+		UserID:          donor.ID,
+		ProjectID:       project.ID,
+		Amount:          models.Money{Amount: 500, Currency: "EUR"},
+		Interval:        models.IntervalMonthly,
+		StartDate:       start,
+		NextBillingAt:   CycleDate(start, models.IntervalMonthly, 1),
+		Cycle:           1,
+		Status:          models.SubscriptionActive,
+		PaymentMethod:   "pm_card_visa",
+		PaymentCustomer: "cus_test",
+	}
+	require.NoError(t, s.db.Create(&subscription).Error)
+
+	_, err := s.PauseSubscription(donor.ID, subscription.ID)
+	require.NoError(t, err)
+	s.billDue(ctx, time.Now())
+	assert.Empty(t, queue.payloads(string(ChargeDonationJob)))
+	_, err = s.PauseSubscription(donor.ID, subscription.ID)
+	assert.ErrorIs(t, err, ErrSubscriptionState)
+
+	subscription, err = s.ResumeSubscription(donor.ID, subscription.ID)
+	require.NoError(t, err)
+	require.NoError(t, s.db.First(&subscription, subscription.ID).Error)
+	assert.Equal(t, models.SubscriptionActive, subscription.Status)
+	assert.Equal(t, 4, subscription.Cycle)
+	assert.WithinDuration(t, CycleDate(start, models.IntervalMonthly, 4), subscription.NextBillingAt, time.Second)
+	assert.True(t, subscription.NextBillingAt.After(time.Now()))
+
+	// Nothing is owed for the cycles skipped while paused.
+	s.billDue(ctx, time.Now())
+	assert.Empty(t, queue.payloads(string(ChargeDonationJob)))
+	s.billDue(ctx, subscription.NextBillingAt)
+	assert.Len(t, queue.payloads(string(ChargeDonationJob)), 1)
+}
+
+// TestBill_MissedCycles tests that a plan several cycles behind is charged once and moved past now
+func TestBill_MissedCycles(t *testing.T) {
+	ctx := context.Background()
+	queue := &recordingQueue{}
+	s, _ := newTestSubscriptionService(t, queue)
+	owner := createTestUser(t, s.db, "owner")
+	donor := createTestUser(t, s.db, "donor")
+	project := createTestProject(t, s.db, owner)
+	now := time.Now()
+	start := now.AddDate(0, -5, -1)
+	subscription := models.Subscription{
+		UserID:          donor.ID,
+		ProjectID:       project.ID,
+		Amount:          models.Money{Amount: 500, Currency: "EUR"},
+		Interval:        models.IntervalMonthly,
+		StartDate:       start,
+		NextBillingAt:   CycleDate(start, models.IntervalMonthly, 1),
+		Cycle:           1,
+		Status:          models.SubscriptionActive,
+		PaymentMethod:   "pm_card_visa",
+		PaymentCustomer: "cus_test",
+	}
+	require.NoError(t, s.db.Create(&subscription).Error)
+
+	// Cycles 1 to 5 are all due; only one charge is made.
+	s.billDue(ctx, now)
+	assert.Len(t, queue.payloads(string(ChargeDonationJob)), 1)
+	require.NoError(t, s.db.First(&subscription, subscription.ID).Error)
+	assert.Equal(t, 6, subscription.Cycle)
+	assert.WithinDuration(t, CycleDate(start, models.IntervalMonthly, 6), subscription.NextBillingAt, time.Second)
+	assert.True(t, subscription.NextBillingAt.After(now))
+
+	require.NoError(t, s.db.Model(&models.Donation{}).Where("id = ?", *subscription.PendingDonationID).Update("status", models.DonationSucceeded).Error)
+	s.settleCharges(now)
+	s.billDue(ctx, now)
+	assert.Empty(t, queue.payloads(string(ChargeDonationJob)))
+	var count int64
+	require.NoError(t, s.db.Model(&models.Donation{}).Where("subscription_id = ?", subscription.ID).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
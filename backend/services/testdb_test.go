@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"crowdfund/backend/jobs"
+	"crowdfund/backend/models"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testDBCount atomic.Int64
+
+// newTestDB opens a private in-memory SQLite database with tables built
+// from the models. The SQLite dialect drops row-locking clauses, so tests
+// using it cover what the services do, not how they behave under
+// concurrency.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:test%d?mode=memory&cache=shared&_pragma=busy_timeout(5000)", testDBCount.Add(1))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	require.NoError(t, db.AutoMigrate(
+		&models.User{},
+		&models.Project{},
+		&models.Category{},
+		&models.Tag{},
+		&models.ProjectMedia{},
+		&models.Donation{},
+		&models.Refund{},
+		&models.Subscription{},
+		&models.ProjectFollow{},
+		&models.NotificationPreferences{},
+		&models.IdempotencyKey{},
+		&models.PaymentEvent{},
+		&models.Report{},
+		&models.ModerationAction{},
+	))
+	return db
+}
+
+// recordingQueue keeps enqueued jobs instead of running them. Unlike the
+// Postgres queue it keeps jobs enqueued in a transaction that rolls back.
+type recordingQueue struct {
+	mu   sync.Mutex
+	jobs map[string][][]byte
+}
+
+func (q *recordingQueue) Enqueue(ctx context.Context, tx *gorm.DB, queue string, payload []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.jobs == nil {
+		q.jobs = map[string][][]byte{}
+	}
+	q.jobs[queue] = append(q.jobs[queue], payload)
+	return nil
+}
+
+func (q *recordingQueue) Handle(queue string, handler jobs.RawHandler) {}
+
+func (q *recordingQueue) Handler(queue string) (jobs.RawHandler, bool) {
+	return jobs.RawHandler{}, false
+}
+
+func (q *recordingQueue) Pending(ctx context.Context, queue string, page, perPage int) ([]jobs.Entry, error) {
+	return nil, nil
+}
+
+func (q *recordingQueue) Run(ctx context.Context) {}
+
+// emails returns the messages handed to SendEmailJob so far and forgets
+// them.
+func (q *recordingQueue) emails(t *testing.T) []EmailMessage {
+	t.Helper()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var messages []EmailMessage
+	for _, payload := range q.jobs[string(SendEmailJob)] {
+		var message EmailMessage
+		require.NoError(t, json.Unmarshal(payload, &message))
+		messages = append(messages, message)
+	}
+	delete(q.jobs, string(SendEmailJob))
+	return messages
+}
+
+// payloads returns the payloads enqueued on a queue so far and forgets
+// them.
+func (q *recordingQueue) payloads(queue string) [][]byte {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	payloads := q.jobs[queue]
+	delete(q.jobs, queue)
+	return payloads
+}
+
+// newTestDonationService returns a donation service charging through
+// provider, with rates only between identical currencies.
+func newTestDonationService(t *testing.T, db *gorm.DB, queue jobs.Queue, provider PaymentProvider) *DonationService {
+	t.Helper()
+	rates, err := NewStaticRateProvider(models.DefaultCurrency, nil)
+	require.NoError(t, err)
+	return NewDonationService(db, NewEmailService(queue), rates, provider, queue)
+}
+
+// recipients returns who each email went to, in order.
+func recipients(messages []EmailMessage) []string {
+	var to []string
+	for _, message := range messages {
+		to = append(to, message.To...)
+	}
+	return to
+}
+
+func createTestUser(t *testing.T, db *gorm.DB, username string) models.User {
+	t.Helper()
+	user := models.User{Username: username, Email: username + "@example.com"}
+	require.NoError(t, db.Create(&user).Error)
+	return user
+}
+
+// createTestProject creates a running flexible project with a goal of
+// 1000.00 EUR, ending in a week.
+func createTestProject(t *testing.T, db *gorm.DB, owner models.User) models.Project {
+	t.Helper()
+	now := time.Now()
+	project := models.Project{
+		Title:            "Solar kettle",
+		Slug:             fmt.Sprintf("solar-kettle-%d", testDBCount.Add(1)),
+		Goal:             models.Money{Amount: 100000, Currency: "EUR"},
+		StartDate:        now.Add(-24 * time.Hour),
+		EndDate:          now.Add(7 * 24 * time.Hour),
+		UserID:           owner.ID,
+		FundingMode:      models.FundingFlexible,
+		ModerationStatus: models.ModerationActive,
+	}
+	require.NoError(t, db.Create(&project).Error)
+	return project
+}
+
+// createTestDonation records a donation of amount minor units of EUR.
+func createTestDonation(t *testing.T, db *gorm.DB, project models.Project, donor models.User, amount int64, status string) models.Donation {
+	t.Helper()
+	donation := models.Donation{
+		Reference:        fmt.Sprintf("don_test%d", testDBCount.Add(1)),
+		ProjectID:        project.ID,
+		UserID:           donor.ID,
+		Amount:           models.Money{Amount: amount, Currency: "EUR"},
+		BaseAmount:       models.Money{Amount: amount, Currency: "EUR"},
+		ExchangeRate:     "1",
+		Status:           status,
+		PaymentProvider:  "fake",
+		PaymentIntentID:  fmt.Sprintf("pi_test%d", testDBCount.Add(1)),
+		ModerationStatus: models.ModerationActive,
+	}
+	require.NoError(t, db.Create(&donation).Error)
+	return donation
+}
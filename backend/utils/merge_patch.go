@@ -0,0 +1,40 @@
+package utils
+
+import "encoding/json"
+
+// MergePatch applies an RFC 7396 JSON Merge Patch to a JSON document: keys
+// in the patch replace those in the document, null removes a key, and
+// nested objects are merged recursively. A patch that is not an object
+// replaces the document entirely.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var patchValue interface{}
+	if err := json.Unmarshal(patch, &patchValue); err != nil {
+		return nil, err
+	}
+	var docValue interface{}
+	if len(doc) > 0 {
+		if err := json.Unmarshal(doc, &docValue); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(mergeValue(docValue, patchValue))
+}
+
+func mergeValue(doc, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	docObj, ok := doc.(map[string]interface{})
+	if !ok {
+		docObj = map[string]interface{}{}
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(docObj, key)
+			continue
+		}
+		docObj[key] = mergeValue(docObj[key], value)
+	}
+	return docObj
+}